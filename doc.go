@@ -1,8 +1,104 @@
 /*
-go-tetris is a simple console-based tetris game written in Go. It takes no options to run. Simply type:
+go-tetris is a simple console-based tetris game written in Go. To play, simply type:
 
 	$ go-tetris
 
-after installing.
+after installing. Flags:
+
+	-mode name         The game mode: classic (the default), sprint (clear 40 lines as fast as possible),
+	                   ultra (score as much as possible in 3 minutes), marathon (clear 150 lines as the
+	                   levels go up), cheese (dig through 10 garbage rows as fast as possible), survival
+	                   (last as long as possible while garbage rises faster and faster), master (reach
+	                   level 999 at up to 20G and earn a grade), puzzle (reach a goal with a given board
+	                   and pieces), drill (practice openers by placing pieces on a target shape), or zen
+	                   (practice at a slow, steady speed, undoing and redoing pieces as you like).
+	-lines n           The number of lines to clear in sprint, marathon, or cheese mode.
+	-time duration     The time limit in ultra mode.
+	-level n           The starting level in marathon mode.
+	-endless           Play marathon mode until topping out, with no line cap.
+	-messiness n       The percentage chance that each garbage row's hole moves column in cheese or survival
+	                   mode.
+	-stack visibility  How locked blocks are shown: visible (the default), fading, or invisible.
+	-flash             Outline blocks for a moment as they disappear.
+	-clear-gravity g   How blocks fall after a line clear: naive (the default; rows move down as they are),
+	                   sticky (connected blocks of the same color fall together), or cascade (every block
+	                   falls on its own). Blocks that fall can complete more rows in a chain.
+	-finesse f         Count finesse faults (pieces placed with more moves and rotations than they need): off
+	                   (the default), track, or strict (the game starts over after a fault).
+	-pack pack         The puzzles to play in puzzle or drill mode: a built-in pack (basics or openers) or a
+	                   puzzle pack file.
+	-puzzle n          The puzzle to play in puzzle or drill mode. Without it, a list of puzzles to choose
+	                   from is shown.
+	-replay file       Save a replay of the game to file when it ends.
+	-fumen data        Start a practice game from the first page of fumen (v115) data.
+	-fumen-out file    The file that the board is appended to, as fumen, when 'f' is pressed.
+	-spectators addr   Let spectators watch the game with 'go-tetris watch', listening on the given address
+	                   (e.g. :4413). The versus, host, join, and connect commands take it too.
+	-spectator-delay d How far behind the game spectators are kept (at most 10s).
+
+Commands:
+
+	$ go-tetris verify [-score n] [-lines n] [-time duration] [-winner n] [-pack file] replay-file
+
+Re-simulate a saved replay and check that it produces the claimed score, lines, and time (by default, the
+ones recorded in the replay), or for a replay of a network match, the claimed winner. The exit status is
+non-zero if they don't match or the replay is invalid. Replays of puzzles from a puzzle pack file need the
+same file passed with -pack.
+
+	$ go-tetris export [-format cast|gif|fumen] [-fps n] [-cell pixels] [-pack file] replay-file output-file
+
+Render a replay as an asciicast (v2) recording or an animated GIF, or convert it to fumen data with a page per
+piece placed. The format defaults to the output file's extension.
+
+	$ go-tetris versus [-stack visibility] [-flash] [-clear-gravity g]
+
+Play a two-player match in the same terminal. Clearing lines sends garbage to the other player (more for
+tetrises, T-spins, combos, back-to-back clears, and perfect clears), which cancels any garbage waiting to
+rise into your own board first. The first player to top out loses.
+
+	$ go-tetris host [-addr address] [-lan=false] [-lan-port port] [-replay file] [-stack visibility]
+	                 [-flash] [-clear-gravity g]
+	$ go-tetris join [-name name] [-lan-port port] [-list] [-replay file] [host[:port]]
+
+Play a versus match over the network (TCP). One player hosts the match, on port 4411 by default, and chooses
+its options; the other joins it. Only inputs are sent, and both players simulate the whole match, checking
+that their simulations agree; if they don't, the match is abandoned and a diagnostic dump is saved. Hosts and
+servers announce themselves on the local network (with UDP broadcasts on port 4412), and join without an
+address shows a list of the games found to choose from (or prints it, with -list). -replay saves a replay of
+the match, with every player's inputs, which verify can check.
+
+	$ go-tetris server [-addr address] [-players n] [-wait duration] [-lan=false] [-lan-port port]
+	                   [-stack visibility] [-flash] [-clear-gravity g]
+	$ go-tetris connect [-name name] [-spectate] [-room n] [-replay file] host[:port]
+
+Run a server for matches of up to 16 players, or connect to one to play or watch. The server puts players into
+rooms of up to -players (8 by default), and starts a room's match once it's full or has had at least two
+players for the -wait time. Garbage goes to each player's target, chosen by their targeting strategy (random,
+attackers, KOs, or even; 't' switches between them), and the last player standing wins. After a match,
+players press 'r' for a rematch. With -replay, connect saves a replay of each match (numbered if there's more
+than one).
+
+	$ go-tetris serve-ssh [-addr address] [-host-key file] [-mode name] [mode flags]
+
+Accept SSH connections (on port 2222 by default) and play a game in each session's terminal, with the mode
+and options given by the same flags as a local game. Any user name is accepted, without a password. The host
+key is read from a private key file, or else a new one is made every time the server starts.
+
+	$ go-tetris serve-web [-addr address] [-mode name] [mode flags]
+
+Serve a web page (on port 8080 by default) which plays games in the browser. The page draws the game on a
+canvas and sends key presses over a WebSocket to a game running on the server, which sends back the cells of
+the screen that change.
+
+	$ go-tetris watch host[:port]
+
+Watch a game that was started with -spectators (on port 4413 by default), seeing its screen as the player
+does. Spectators never slow the game down; a spectator who falls behind skips ahead to the current screen.
+
+The game can also be compiled to WebAssembly (see the wasm directory) to play in a browser without a server:
+
+	$ GOOS=js GOARCH=wasm go build -o wasm/go-tetris.wasm ./wasm
+
+Games played this way are drawn on a canvas, and their replays can be downloaded.
 */
 package documentation
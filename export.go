@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"os"
+	"path/filepath"
+)
+
+// The export command renders a replay as an asciicast recording or an animated GIF, or converts it to fumen
+// data.
+func export(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-tetris export [flags] replay-file output-file")
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", "", "Output format: 'cast', 'gif', or 'fumen' (default: file extension)")
+	fps := flags.Int("fps", 10, "Frames per second of game time")
+	cellSize := flags.Int("cell", 8, "The size of a board cell in pixels (GIF only)")
+	packFile := flags.String("pack", "", "Load the puzzle pack in this file, for replays of its puzzles")
+	flags.Parse(args)
+	if *packFile != "" {
+		loadPuzzlePack(*packFile)
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *format == "" {
+		*format = filepath.Ext(flags.Arg(1))
+		if len(*format) > 0 {
+			*format = (*format)[1:]
+		}
+	}
+	if *fps < 1 || *cellSize < 1 {
+		fmt.Fprintln(os.Stderr, "-fps and -cell must be positive")
+		os.Exit(2)
+	}
+
+	replay, err := loadReplay(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid replay:", err)
+		os.Exit(1)
+	}
+	out, err := os.Create(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	switch *format {
+	case "cast":
+		err = tetris.WriteAsciicast(out, replay, *fps)
+	case "gif":
+		err = tetris.WriteGIF(out, replay, *cellSize, *fps)
+	case "fumen":
+		var fumen string
+		if fumen, err = replay.EncodeFumen(); err == nil {
+			_, err = fmt.Fprintln(out, fumen)
+		}
+	default:
+		err = fmt.Errorf("unknown format %q (expected 'cast', 'gif', or 'fumen')", *format)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(flags.Arg(1))
+		fmt.Fprintln(os.Stderr, "Error exporting replay:", err)
+		os.Exit(1)
+	}
+}
@@ -1,24 +1,203 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/cespare/go-tetris/tetris"
 	"github.com/nsf/termbox-go"
-	"math/rand"
+	"os"
+	"strings"
 	"time"
 )
 
+// Subcommands, run as "go-tetris <command> [args]". Without a command, go-tetris just plays a game.
+var commands = map[string]func(args []string){
+	"verify":    verify,
+	"export":    export,
+	"versus":    versus,
+	"host":      host,
+	"join":      join,
+	"server":    server,
+	"connect":   connect,
+	"serve-ssh": serveSSH,
+	"serve-web": serveWeb,
+	"watch":     watch,
+}
+
 func main() {
-	rand.Seed(time.Now().UnixNano())
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
+	gameMode := modeFlags(flag.CommandLine)
+	replayFile := flag.String("replay", "", "Save a replay of the game to this file")
+	fumen := flag.String("fumen", "", "Start a practice game from the first page of this fumen data")
+	fumenFile := flag.String("fumen-out", "go-tetris.fumen", "The file that 'f' saves the board to (as fumen)")
+	spectators := spectatorFlags(flag.CommandLine)
+	flag.Parse()
+
+	mode, choose := gameMode()
+	records, err := tetris.LoadRecords()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading personal bests (they won't be updated):", err)
+	}
+	options := tetris.PlayOptions{FumenFile: *fumenFile, Records: records}
+	if choose {
+		if mode = choosePuzzle(mode, options.Records); mode == nil {
+			fmt.Println("Bye!")
+			return
+		}
+	}
+
+	game := tetris.NewGame(time.Now().UnixNano(), mode)
+	if *fumen != "" {
+		if err := game.LoadFumen(*fumen); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid fumen:", err)
+			os.Exit(1)
+		}
+		// Practice games don't count for personal bests.
+		options.Records = nil
+	}
 
-	err := termbox.Init()
+	console, stopBroadcast := spectators(termboxConsole{})
+	err = termbox.Init()
 	if err != nil {
 		panic(err)
 	}
 
-	game := tetris.NewGame()
-	game.Start()
+	game.Start(console, options)
 
 	termbox.Close()
+	stopBroadcast()
+
+	if *replayFile != "" {
+		if err := saveReplay(*replayFile, game.Replay()); err != nil {
+			fmt.Fprintln(os.Stderr, "Error saving replay:", err)
+			os.Exit(1)
+		}
+	}
 	fmt.Println("Bye!")
 }
+
+// Show the level select screen for a puzzle or drill mode's pack, returning the chosen puzzle's mode, or nil
+// if the player quit instead.
+func choosePuzzle(mode tetris.Mode, records *tetris.Records) tetris.Mode {
+	if err := termbox.Init(); err != nil {
+		panic(err)
+	}
+	mode, err := tetris.SelectPuzzle(termboxConsole{}, mode, records)
+	termbox.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return mode
+}
+
+// Find a built-in puzzle pack by name, or else load one from a file so that its puzzles can be played or
+// replayed, exiting if it's invalid.
+func findPuzzlePack(name string) *tetris.PuzzlePack {
+	if pack, ok := tetris.PuzzlePackNamed(name); ok {
+		return pack
+	}
+	return loadPuzzlePack(name)
+}
+
+// Load a puzzle pack so that its puzzles can be played or replayed, exiting if it's invalid.
+func loadPuzzlePack(filename string) *tetris.PuzzlePack {
+	pack, err := tetris.LoadPuzzlePack(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid puzzle pack:", err)
+		os.Exit(1)
+	}
+	return pack
+}
+
+func loadReplay(filename string) (*tetris.Replay, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return tetris.ReadReplay(f)
+}
+
+func saveReplay(filename string, replay *tetris.Replay) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := replay.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Add the flags for a single game's mode and its options to a flag set, returning a function which makes the
+// mode once the flags have been parsed (exiting if any of them are invalid). It also reports whether the
+// player should choose a puzzle, because the mode is a puzzle or drill mode and no -puzzle was given.
+func modeFlags(flags *flag.FlagSet) func() (mode tetris.Mode, choosePuzzle bool) {
+	modeName := flags.String("mode", "classic", "The game mode: "+strings.Join(tetris.ModeNames(), ", "))
+	lines := flags.Int("lines", 0,
+		"The number of lines to clear in sprint (default 40), marathon (default 150), or cheese mode (default 10)")
+	timeLimit := flags.Duration("time", 0, "The time limit in ultra mode (default 3m)")
+	level := flags.Int("level", 0, "The starting level in marathon mode (default 1)")
+	endless := flags.Bool("endless", false, "Play marathon mode without a line cap")
+	messiness := flags.Int("messiness", 100,
+		"The percentage chance that each garbage row's hole moves column (cheese and survival modes)")
+	stack := flags.String("stack", "visible",
+		"How locked blocks are shown: visible, fading (they disappear after a few seconds), or invisible")
+	flash := flags.Bool("flash", false,
+		"Briefly outline blocks when they disappear (with -stack fading or invisible)")
+	clearGravity := flags.String("clear-gravity", "naive",
+		"How blocks fall after a line clear: naive, sticky (connected blocks fall together), or cascade")
+	finesse := flags.String("finesse", "off",
+		"Count finesse faults (pieces placed with extra moves or rotations): off, track, or strict (start over)")
+	packName := flags.String("pack", "",
+		"The puzzles to play in puzzle or drill mode: the name of a built-in pack, or a puzzle pack file")
+	puzzle := flags.Int("puzzle", 0, "The puzzle to play in puzzle or drill mode (default: choose one)")
+	return func() (tetris.Mode, bool) {
+		stackVisibility, err := tetris.ParseStackVisibility(*stack)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		gravity, err := tetris.ParseClearGravity(*clearGravity)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		finesseTracking, err := tetris.ParseFinesseTracking(*finesse)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		var pack string
+		if *packName != "" {
+			pack = findPuzzlePack(*packName).Name
+		}
+		modeOptions := tetris.ModeOptions{
+			Lines:        *lines,
+			TimeLimit:    int(*timeLimit / time.Millisecond),
+			Level:        *level,
+			Endless:      *endless,
+			Messiness:    *messiness,
+			Stack:        stackVisibility,
+			Flash:        *flash,
+			ClearGravity: gravity,
+			Pack:         pack,
+			Puzzle:       *puzzle,
+			Finesse:      finesseTracking,
+		}
+		mode, err := tetris.NewMode(*modeName, modeOptions)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return mode, mode.Options().Pack != "" && *puzzle == 0
+	}
+}
@@ -2,9 +2,16 @@ module github.com/cespare/go-tetris
 
 go 1.17
 
-require github.com/nsf/termbox-go v1.1.1
+require (
+	github.com/gliderlabs/ssh v0.3.5
+	github.com/gorilla/websocket v1.5.0
+	github.com/nsf/termbox-go v1.1.1
+)
 
 require (
+	github.com/anmitsu/go-shlex v0.0.0-20200514113438-38f4b401e2be // indirect
 	github.com/mattn/go-runewidth v0.0.13 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
+	golang.org/x/crypto v0.8.0 // indirect
+	golang.org/x/sys v0.7.0 // indirect
 )
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"github.com/cespare/go-tetris/tetris/netplay"
+	"github.com/nsf/termbox-go"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Add the flags for announcing a game on the local network to a flag set, returning a function which starts
+// announcing it (if the flags say to) once they've been parsed. The function returns another one which stops.
+func announceFlags(flags *flag.FlagSet) func(describe func() netplay.LANGame) (stop func()) {
+	lan := flags.Bool("lan", true, "Announce the game on the local network, for 'go-tetris join' to list")
+	lanPort := flags.Int("lan-port", netplay.LANPort, "The UDP port to announce the game on")
+	return func(describe func() netplay.LANGame) func() {
+		if !*lan {
+			return func() {}
+		}
+		stop, err := netplay.AnnounceLAN(*lanPort, describe)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Couldn't announce the game on the local network:", err)
+			return func() {}
+		}
+		return stop
+	}
+}
+
+// Find the games announced on the local network (see announceFlags), and either print them (if list is true)
+// or let the player choose one to join. Servers are joined as a player called name, spectators can watch the
+// game as spectatorFlags says, and the matches played are saved as matchReplayFlag says.
+func joinLAN(name string, lanPort int, list bool,
+	spectators func(console tetris.Console) (tetris.Console, func()),
+	saveReplays func(replays ...*tetris.MatchReplay)) {
+	browser, err := netplay.BrowseLAN(lanPort)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't listen for games on the local network:", err)
+		os.Exit(1)
+	}
+	if list {
+		// Games are announced every second, so by now everybody has had a chance.
+		time.Sleep(1500 * time.Millisecond)
+		for _, game := range browser.Games() {
+			rules := strings.TrimSpace(fmt.Sprintf("%s %s", game.Ruleset, game.Options))
+			fmt.Printf("%-21s  %-12s  %s (%s)\n", game.Addr, game.Name, game, rules)
+		}
+		browser.Close()
+		return
+	}
+
+	console, stopBroadcast := spectators(termboxConsole{})
+	if err := termbox.Init(); err != nil {
+		panic(err)
+	}
+	fail := func(message string, err error) {
+		termbox.Close()
+		fmt.Fprintln(os.Stderr, message, err)
+		os.Exit(1)
+	}
+	game, ok, err := netplay.SelectLANGame(termboxConsole{}, browser)
+	browser.Close()
+	if err != nil {
+		fail("Couldn't choose a game:", err)
+	}
+	if !ok {
+		termbox.Close()
+		fmt.Println("Bye!")
+		return
+	}
+	conn, err := net.DialTimeout("tcp", game.Addr, 10*time.Second)
+	if err != nil {
+		fail("Couldn't connect:", err)
+	}
+	var replays []*tetris.MatchReplay
+	if game.Server {
+		client, err := netplay.JoinServer(conn, name)
+		if err != nil {
+			conn.Close()
+			fail("Couldn't join the server:", err)
+		}
+		client.Start(console)
+		replays = client.Replays()
+	} else {
+		match, err := netplay.JoinMatch(conn)
+		if err != nil {
+			conn.Close()
+			fail("Couldn't join the match:", err)
+		}
+		match.Start(console)
+		replays = append(replays, match.Replay())
+	}
+	termbox.Close()
+	stopBroadcast()
+	fmt.Println("Bye!")
+	saveReplays(replays...)
+}
+
+// The name to play under on a server: the given one, or "player" if there isn't one.
+func playerName(name string) string {
+	if name == "" {
+		return "player"
+	}
+	return name
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/cespare/go-tetris/tetris/netplay"
+	"github.com/nsf/termbox-go"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+// The server command hosts rooms of players who connect to it (see connect) and plays versus matches between
+// them, until it's killed.
+func server(args []string) {
+	flags := flag.NewFlagSet("server", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-tetris server [flags]")
+		flags.PrintDefaults()
+	}
+	address := flags.String("addr", ":"+defaultPort, "The address to listen on")
+	players := flags.Int("players", 8,
+		fmt.Sprintf("The most players in a room (2 to %d)", netplay.MaxRoomSize))
+	wait := flags.Duration("wait", 20*time.Second,
+		"How long a room with at least two players waits for more before its match starts")
+	matchOptions := versusFlags(flags)
+	announce := announceFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	options := matchOptions()
+	s, err := netplay.NewServer(options, *players, *wait, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	listener, err := net.Listen("tcp", *address)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	announce(func() netplay.LANGame {
+		return netplay.LANGame{
+			Addr:    listener.Addr().String(),
+			Server:  true,
+			Players: s.Players(),
+			Size:    s.RoomSize(),
+			Options: options,
+		}
+	})
+	logger.Printf("Serving on %s", listener.Addr())
+	logger.Fatal(s.Serve(listener))
+}
+
+// The connect command joins a server (see server) to play in one of its rooms, or to watch one.
+func connect(args []string) {
+	flags := flag.NewFlagSet("connect", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-tetris connect [flags] host[:port]")
+		flags.PrintDefaults()
+	}
+	name := flags.String("name", os.Getenv("USER"), "The name to play under")
+	spectate := flags.Bool("spectate", false, "Watch a room's matches instead of playing")
+	room := flags.Int("room", 0, "The room to watch with -spectate (default: one with a match on)")
+	spectators := spectatorFlags(flags)
+	saveReplays := matchReplayFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	address := flags.Arg(0)
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultPort)
+	}
+
+	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var client *netplay.ServerClient
+	if *spectate {
+		client, err = netplay.SpectateServer(conn, *room)
+	} else {
+		client, err = netplay.JoinServer(conn, playerName(*name))
+	}
+	if err != nil {
+		conn.Close()
+		fmt.Fprintln(os.Stderr, "Couldn't join the server:", err)
+		os.Exit(1)
+	}
+
+	console, stopBroadcast := spectators(termboxConsole{})
+	if err := termbox.Init(); err != nil {
+		panic(err)
+	}
+	client.Start(console)
+	termbox.Close()
+	stopBroadcast()
+	fmt.Println("Bye!")
+	saveReplays(client.Replays()...)
+}
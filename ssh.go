@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"github.com/gliderlabs/ssh"
+	"log"
+	"os"
+	"time"
+)
+
+// The serve-ssh command accepts SSH connections and plays a game in each session's terminal, until it's
+// killed.
+func serveSSH(args []string) {
+	flags := flag.NewFlagSet("serve-ssh", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-tetris serve-ssh [flags]")
+		flags.PrintDefaults()
+	}
+	address := flags.String("addr", ":2222", "The address to listen on")
+	hostKey := flags.String("host-key", "",
+		"A private key file (e.g. made by ssh-keygen) for the server's host key (default: a new one)")
+	gameMode := modeFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	// Check the mode's flags before anybody connects.
+	gameMode()
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	s := &ssh.Server{
+		Addr: *address,
+		Handler: func(session ssh.Session) {
+			mode, _ := gameMode()
+			playSSH(session, mode, logger)
+		},
+	}
+	if *hostKey != "" {
+		if err := s.SetOption(ssh.HostKeyFile(*hostKey)); err != nil {
+			fmt.Fprintln(os.Stderr, "Error loading the host key:", err)
+			os.Exit(1)
+		}
+	}
+	logger.Printf("Serving SSH on %s", *address)
+	logger.Fatal(s.ListenAndServe())
+}
+
+// Play a game in an SSH session's terminal, until the player quits or disconnects. Sessions without a
+// terminal are turned away.
+func playSSH(session ssh.Session, mode tetris.Mode, logger *log.Logger) {
+	pty, windows, ok := session.Pty()
+	if !ok {
+		fmt.Fprintln(session, "go-tetris needs a terminal to play in (try ssh -t).")
+		session.Exit(1)
+		return
+	}
+	logger.Printf("%s connected from %s", session.User(), session.RemoteAddr())
+	terminal := tetris.NewTerminal(session, pty.Window.Width, pty.Window.Height)
+	go func() {
+		// The channel is closed when the session ends.
+		for window := range windows {
+			terminal.Resize(window.Width, window.Height)
+		}
+	}()
+
+	game := tetris.NewGame(time.Now().UnixNano(), mode)
+	game.Start(terminal, tetris.PlayOptions{})
+	terminal.Close()
+	fmt.Fprint(session, "Bye!\r\n")
+	logger.Printf("%s left", session.User())
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/cespare/go-tetris/tetris"
+	"github.com/nsf/termbox-go"
+)
+
+// The tetris.Console for the terminal that termbox manages. termbox must be initialized to use it.
+type termboxConsole struct{}
+
+func (termboxConsole) SetCell(x, y int, ch rune, fg, bg tetris.Color) {
+	// tetris's colors have the same values as termbox's.
+	termbox.SetCell(x, y, ch, termbox.Attribute(fg), termbox.Attribute(bg))
+}
+
+func (termboxConsole) Flush() error {
+	return termbox.Flush()
+}
+
+func (termboxConsole) Clear(fg, bg tetris.Color) {
+	termbox.Clear(termbox.Attribute(fg), termbox.Attribute(bg))
+}
+
+// termbox's arrow keys, as tetris.Keys.
+var termboxArrowKeys = map[termbox.Key]tetris.Key{
+	termbox.KeyArrowUp:    tetris.KeyArrowUp,
+	termbox.KeyArrowDown:  tetris.KeyArrowDown,
+	termbox.KeyArrowLeft:  tetris.KeyArrowLeft,
+	termbox.KeyArrowRight: tetris.KeyArrowRight,
+}
+
+func (termboxConsole) PollEvent() tetris.Event {
+	switch event := termbox.PollEvent(); event.Type {
+	case termbox.EventKey:
+		if event.Ch != 0 {
+			return tetris.Event{Type: tetris.EventKey, Ch: event.Ch}
+		}
+		if key, ok := termboxArrowKeys[event.Key]; ok {
+			return tetris.Event{Type: tetris.EventKey, Key: key}
+		}
+		// Control keys (and space) are the characters they send, for both.
+		if event.Key < 0x80 {
+			return tetris.Event{Type: tetris.EventKey, Key: tetris.Key(event.Key)}
+		}
+	case termbox.EventResize:
+		return tetris.Event{Type: tetris.EventResize}
+	case termbox.EventError:
+		return tetris.Event{Type: tetris.EventError, Err: event.Err}
+	case termbox.EventInterrupt:
+		return tetris.Event{Type: tetris.EventInterrupt}
+	}
+	return tetris.Event{Type: tetris.EventNone}
+}
+
+func (termboxConsole) Interrupt() {
+	termbox.Interrupt()
+}
@@ -0,0 +1,170 @@
+package tetris
+
+import (
+	"fmt"
+)
+
+// A pieceClear describes the rows completed by a piece as it locked, which decide the attack that it sends.
+type pieceClear struct {
+	lines        int
+	tSpin        bool
+	perfectClear bool
+}
+
+// Describe the rows completed by the piece that just locked. It's only meaningful straight after a piece
+// locks: rows completed later on by a chain (see ClearGravity) don't count towards any piece.
+func (game *Game) lastClear() pieceClear {
+	lines := len(game.clearingRows)
+	return pieceClear{
+		lines:        lines,
+		tSpin:        game.tSpin,
+		perfectClear: lines > 0 && len(game.board.cells) == lines*width,
+	}
+}
+
+// Whether a clear is difficult: a tetris, or a T-spin that clears lines. Consecutive difficult clears are
+// back-to-back, and send an extra row.
+func (c pieceClear) difficult() bool {
+	return c.lines == 4 || c.tSpin && c.lines > 0
+}
+
+// An attackTable holds the number of garbage rows sent by each kind of clear.
+type attackTable struct {
+	// The rows sent for clearing 0 to 4 lines at once, and for clearing 0 to 3 lines with a T-spin.
+	lines []int
+	tSpin []int
+	// The extra rows sent for each combo (clearing lines with several pieces in a row): combo[n] is the bonus
+	// for the clear n pieces after the first one. The last bonus carries on for longer combos.
+	combo []int
+	// The extra rows sent for a back-to-back difficult clear, and for clearing every block from the board.
+	backToBack   int
+	perfectClear int
+}
+
+// The attack table of the tetris guideline.
+var guidelineAttackTable = &attackTable{
+	lines:        []int{0, 0, 1, 2, 4},
+	tSpin:        []int{0, 2, 4, 6},
+	combo:        []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4, 5},
+	backToBack:   1,
+	perfectClear: 10,
+}
+
+// An attacker works out the attack sent by each piece that a player places, keeping track of their combo and
+// back-to-back streak.
+type attacker struct {
+	table *attackTable
+	// The number of pieces in a row which have cleared lines, and whether the last clear was difficult.
+	combo      int
+	backToBack bool
+}
+
+// Work out the number of garbage rows sent by a piece as it locks, and update the combo and back-to-back
+// streak. The description of the attack is for showing to the player (e.g. "T-spin double, 2 combo"), and is
+// empty if the piece didn't clear any lines.
+func (attacker *attacker) attack(c pieceClear) (int, string) {
+	if c.lines == 0 {
+		attacker.combo = 0
+		return 0, ""
+	}
+	table := attacker.table
+	rows := table.lines[c.lines]
+	name := clearNames[c.lines]
+	if c.tSpin {
+		rows = table.tSpin[c.lines]
+		name = "T-spin " + name
+	}
+	if attacker.combo > 0 {
+		bonus := table.combo[len(table.combo)-1]
+		if attacker.combo < len(table.combo) {
+			bonus = table.combo[attacker.combo]
+		}
+		rows += bonus
+		name += fmt.Sprintf(", %d combo", attacker.combo)
+	}
+	attacker.combo++
+	if c.difficult() {
+		if attacker.backToBack {
+			rows += table.backToBack
+			name = "Back-to-back " + name
+		}
+		attacker.backToBack = true
+	} else {
+		attacker.backToBack = false
+	}
+	if c.perfectClear {
+		rows += table.perfectClear
+		name += ", perfect clear"
+	}
+	return rows, name
+}
+
+// The names of clears by the number of lines.
+var clearNames = []string{"", "single", "double", "triple", "tetris"}
+
+// A garbageQueue holds the garbage which has been sent to a player but hasn't risen into their board yet.
+// Garbage waits for a delay after it arrives before it can rise, giving the player a chance to cancel it by
+// attacking back.
+type garbageQueue struct {
+	// How long garbage waits after it arrives, in milliseconds.
+	delay   int
+	batches []garbageBatch
+}
+
+// A garbageBatch is the garbage sent by one attack.
+type garbageBatch struct {
+	rows int
+	// The clock time at which it arrived.
+	arrived int
+}
+
+// Add an attack to the queue, arriving at the given clock time.
+func (queue *garbageQueue) receive(rows, clock int) {
+	if rows > 0 {
+		queue.batches = append(queue.batches, garbageBatch{rows, clock})
+	}
+}
+
+// Cancel queued garbage with an attack, oldest first, returning the number of rows of the attack left over
+// to send to the opponent.
+func (queue *garbageQueue) cancel(rows int) int {
+	for rows > 0 && len(queue.batches) > 0 {
+		batch := &queue.batches[0]
+		if batch.rows > rows {
+			batch.rows -= rows
+			return 0
+		}
+		rows -= batch.rows
+		queue.batches = queue.batches[1:]
+	}
+	return rows
+}
+
+// Remove the batches which have waited out the delay at the given clock time, returning the number of rows in
+// each one.
+func (queue *garbageQueue) take(clock int) []int {
+	var ready []int
+	for len(queue.batches) > 0 && clock >= queue.batches[0].arrived+queue.delay {
+		ready = append(ready, queue.batches[0].rows)
+		queue.batches = queue.batches[1:]
+	}
+	return ready
+}
+
+// The number of queued rows which have waited out the delay at the given clock time (and will rise when the
+// player next places a piece without clearing lines), and the number still waiting.
+func (queue *garbageQueue) count(clock int) (ready, waiting int) {
+	for _, batch := range queue.batches {
+		if clock >= batch.arrived+queue.delay {
+			ready += batch.rows
+		} else {
+			waiting += batch.rows
+		}
+	}
+	return ready, waiting
+}
+
+// Modes can implement garbageMeter to have a bar beside the board showing the garbage waiting to rise.
+type garbageMeter interface {
+	incomingGarbage(game *Game) (ready, waiting int)
+}
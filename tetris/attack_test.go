@@ -0,0 +1,79 @@
+package tetris
+
+import (
+	"testing"
+)
+
+func TestAttackTable(t *testing.T) {
+	attacker := attacker{table: guidelineAttackTable}
+	for i, test := range []struct {
+		clear pieceClear
+		rows  int
+		name  string
+	}{
+		{pieceClear{lines: 4}, 4, "tetris"},
+		{pieceClear{lines: 2, tSpin: true}, 4 + 1 + 1, "Back-to-back T-spin double, 1 combo"},
+		{pieceClear{lines: 1}, 0 + 1, "single, 2 combo"},
+		{pieceClear{lines: 4}, 4 + 2, "tetris, 3 combo"},
+		{pieceClear{}, 0, ""},
+		{pieceClear{lines: 4, perfectClear: true}, 4 + 1 + 10, "Back-to-back tetris, perfect clear"},
+	} {
+		rows, name := attacker.attack(test.clear)
+		if rows != test.rows || name != test.name {
+			t.Errorf("attack %d sent %d rows (%q); want %d (%q)", i+1, rows, name, test.rows, test.name)
+		}
+	}
+}
+
+func TestGarbageQueue(t *testing.T) {
+	queue := garbageQueue{delay: 500}
+	queue.receive(2, 0)
+	queue.receive(3, 100)
+	queue.receive(0, 200)
+	if ready, waiting := queue.count(499); ready != 0 || waiting != 5 {
+		t.Fatalf("at 499 ms, %d rows are ready and %d waiting; want 0, 5", ready, waiting)
+	}
+	if ready, waiting := queue.count(500); ready != 2 || waiting != 3 {
+		t.Fatalf("at 500 ms, %d rows are ready and %d waiting; want 2, 3", ready, waiting)
+	}
+	// Attacks cancel the oldest garbage first.
+	if left := queue.cancel(1); left != 0 {
+		t.Fatalf("cancelling with 1 row left %d to send; want 0", left)
+	}
+	if taken := queue.take(600); len(taken) != 2 || taken[0] != 1 || taken[1] != 3 {
+		t.Fatalf("at 600 ms, the batches %v are taken; want [1 3]", taken)
+	}
+	queue.receive(2, 600)
+	if left := queue.cancel(5); left != 3 || len(queue.batches) != 0 {
+		t.Fatalf("cancelling 2 rows with 5 left %d to send (and %d batches); want 3 (and 0)", left,
+			len(queue.batches))
+	}
+}
+
+func TestVersusGarbage(t *testing.T) {
+	match := NewMatch(7, 2, ModeOptions{})
+	match.Advance(10)
+	match.modes[0].outgoing = 3
+	match.update()
+	if ready, waiting := match.modes[1].garbage.count(match.Game(1).clock); ready != 0 || waiting != 3 {
+		t.Fatalf("%d rows are ready and %d waiting; want 0, 3", ready, waiting)
+	}
+	// Garbage which hasn't waited out the delay doesn't rise.
+	match.Handle(1, QuickDrop)
+	match.Advance(100)
+	if match.modes[1].received != 0 {
+		t.Fatalf("the garbage rose before the delay was up")
+	}
+	match.Advance(versusGarbageDelay)
+	b := &Buffer{Width: 2 * ScreenWidth, Height: ScreenHeight}
+	b.cells = make([]Cell, b.Width*b.Height)
+	match.draw(b, true)
+	if cell := b.Cell(ScreenWidth, headerHeight+height+1); cell.Bg != ColorRed {
+		t.Errorf("the bottom of the garbage meter is %v; want red for garbage which is ready", cell.Bg)
+	}
+	match.Handle(1, QuickDrop)
+	match.Advance(1000)
+	if match.modes[1].received != 3 || match.Game(1).board.garbageRows() != 3 {
+		t.Fatalf("%d rows of garbage rose; want 3\n%s", match.modes[1].received, dumpBoard(match.Game(1)))
+	}
+}
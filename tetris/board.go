@@ -1,11 +1,7 @@
 package tetris
 
-import (
-	"github.com/nsf/termbox-go"
-)
-
 // A map from a point on a board to the color of that cell.
-type ColorMap map[Vector]termbox.Attribute
+type ColorMap map[Vector]Color
 
 // Returns whether a vector is a member of the color map.
 func (cm ColorMap) contains(v Vector) bool {
@@ -16,7 +12,10 @@ func (cm ColorMap) contains(v Vector) bool {
 // A Board represents the state of a tetris game board, including the current piece that is descending and the
 // blocks which already exist on the board.
 type Board struct {
-	cells           ColorMap
+	cells ColorMap
+	// The game clock time at which each block was locked into place by a piece. Blocks that got onto the board
+	// some other way (garbage, or blocks loaded from a fumen) aren't in here.
+	lockTimes       map[Vector]int
 	currentPiece    *Piece
 	currentPosition Vector
 }
@@ -25,6 +24,7 @@ type Board struct {
 func newBoard() *Board {
 	board := new(Board)
 	board.cells = make(ColorMap)
+	board.lockTimes = make(map[Vector]int)
 	return board
 }
 
@@ -33,16 +33,19 @@ func newBoard() *Board {
 // seeing if it collides, and moving back.
 func (board *Board) currentPieceInCollision() bool {
 	for _, point := range board.currentPiece.instance() {
-		attemptedPoint := point.plus(board.currentPosition)
-		if attemptedPoint.x < 0 || attemptedPoint.x >= width ||
-			attemptedPoint.y < 0 || attemptedPoint.y >= height ||
-			board.cells.contains(attemptedPoint) {
+		if board.overlaps(point.plus(board.currentPosition)) {
 			return true
 		}
 	}
 	return false
 }
 
+// Finds whether a block at the given point would collide with something: the edges of the board, or an
+// occupied cell.
+func (board *Board) overlaps(point Vector) bool {
+	return point.x < 0 || point.x >= width || point.y < 0 || point.y >= height || board.cells.contains(point)
+}
+
 // Moves the current piece to another location, if possible. The current piece is updated if this is
 // successful; otherwise, the piece is left unmoved. This method returns a boolean indicating whether the move
 // was successful.
@@ -56,14 +59,56 @@ func (board *Board) moveIfPossible(translation Vector) bool {
 	return true
 }
 
-// Merge the blocks of the current piece into the game board and remove the current piece.
-func (board *Board) mergeCurrentPiece() {
+// Rotates the current piece, if possible. If the rotated piece would overlap something, it's moved by the
+// first of the offsets given by kicks (see rotationSystem) which it fits at instead, and if there aren't any,
+// it isn't rotated. Returns whether the piece was rotated.
+func (board *Board) rotateIfPossible(kicks func(board *Board) []Vector) bool {
+	board.currentPiece.rotate()
+	if !board.currentPieceInCollision() {
+		return true
+	}
+	for _, offset := range kicks(board) {
+		if board.moveIfPossible(offset) {
+			return true
+		}
+	}
+	board.currentPiece.unrotate()
+	return false
+}
+
+// Finds whether the current piece is resting on the floor or on occupied blocks, so that it can't move down.
+func (board *Board) currentPieceResting() bool {
+	if !board.moveIfPossible(Vector{0, 1}) {
+		return true
+	}
+	board.currentPosition = board.currentPosition.plus(Vector{0, -1})
+	return false
+}
+
+// Merge the blocks of the current piece into the game board at the given clock time and remove the current
+// piece.
+func (board *Board) mergeCurrentPiece(clock int) {
 	for _, point := range board.currentPiece.instance() {
 		board.cells[point.plus(board.currentPosition)] = board.currentPiece.color
+		board.lockTimes[point.plus(board.currentPosition)] = clock
 	}
 	board.currentPiece = nil
 }
 
+// Move the contents of a cell (which may be empty) to another cell, replacing what was there.
+func (board *Board) moveCell(from, to Vector) {
+	if color, ok := board.cells[from]; ok {
+		board.cells[to] = color
+	} else {
+		delete(board.cells, to)
+	}
+	if lockTime, ok := board.lockTimes[from]; ok {
+		board.lockTimes[to] = lockTime
+	} else {
+		delete(board.lockTimes, to)
+	}
+}
+
 // Check whether a horizontal row is complete.
 func (board *Board) rowComplete(y int) bool {
 	for x := 0; x < width; x++ {
@@ -78,21 +123,29 @@ func (board *Board) rowComplete(y int) bool {
 func (board *Board) collapseRow(rowY int) {
 	for y := rowY - 1; y >= 0; y-- {
 		for x := 0; x < width; x++ {
-			if color, ok := board.cells[Vector{x, y}]; ok {
-				board.cells[Vector{x, y + 1}] = color
-			} else {
-				delete(board.cells, Vector{x, y + 1})
-			}
+			board.moveCell(Vector{x, y}, Vector{x, y + 1})
 		}
 	}
 	// Clear the top row completely
 	for x := 0; x < width; x++ {
 		delete(board.cells, Vector{x, 0})
+		delete(board.lockTimes, Vector{x, 0})
 	}
 }
 
-// Clear any complete rows and move the above blocks down. Returns the number of cleared rows.
-func (board *Board) clearRows() {
+// Clear any complete rows and let the blocks above them fall, according to the given gravity. With sticky or
+// cascade gravity, the fallen blocks might complete more rows (see Game.finishClear).
+func (board *Board) clearRows(gravity ClearGravity) {
+	switch gravity {
+	case ClearSticky:
+		board.removeCompleteRows()
+		board.settleBlobs()
+		return
+	case ClearCascade:
+		board.removeCompleteRows()
+		board.settleCells()
+		return
+	}
 	rowsCleared := 0
 	y := height - 1
 	for y >= 0 {
@@ -115,18 +168,29 @@ func (board *Board) clearedRows() []int {
 	return cleared
 }
 
+// The height of the stack of blocks on the board: the number of rows from the bottom up to the highest block.
+func (board *Board) stackHeight() int {
+	stack := 0
+	for position := range board.cells {
+		if height-position.y > stack {
+			stack = height - position.y
+		}
+	}
+	return stack
+}
+
 // Finds the color of a particular board cell. It returns the background color if the cell is empty.
-func (board *Board) CellColor(position Vector) termbox.Attribute {
+func (board *Board) CellColor(position Vector) Color {
 	if color, ok := board.cells[position]; ok {
 		return color
 	}
 	if board.currentPiece == nil {
-		return backgroundColor
+		return BackgroundColor
 	}
 	for _, point := range board.currentPiece.instance() {
 		if point.plus(board.currentPosition).equals(position) {
 			return board.currentPiece.color
 		}
 	}
-	return backgroundColor
+	return BackgroundColor
 }
@@ -0,0 +1,141 @@
+package tetris
+
+import (
+	"strings"
+)
+
+// A simple bot for playing games in tests. For the current piece, it tries every rotation and column,
+// dropping the piece there and scoring the resulting stack (see evaluateStack), and returns the inputs which
+// place it in the best spot it found.
+func botMoves(game *Game) []GameEvent {
+	board := game.board
+	piece := board.currentPiece
+	if piece == nil {
+		return nil
+	}
+	bestScore := -1 << 30
+	var best []GameEvent
+	for rotations := 0; rotations < len(piece.rotations); rotations++ {
+		for dx := -6; dx <= 6; dx++ {
+			rotation, position := piece.currentRotation, board.currentPosition
+			var events []GameEvent
+			// Moving down first gives pieces which spawn above the board some room to rotate.
+			for i := 0; i < 2; i++ {
+				if board.moveIfPossible(Vector{0, 1}) {
+					events = append(events, MoveDown)
+				}
+			}
+			ok := true
+			for i := 0; i < rotations; i++ {
+				piece.rotate()
+				if board.currentPieceInCollision() {
+					ok = false
+				}
+				events = append(events, Rotate)
+			}
+			step, event := Vector{1, 0}, MoveRight
+			if dx < 0 {
+				step, event = Vector{-1, 0}, MoveLeft
+			}
+			for i := 0; i < abs(dx) && ok; i++ {
+				ok = board.moveIfPossible(step)
+				events = append(events, event)
+			}
+			if ok {
+				for board.moveIfPossible(Vector{0, 1}) {
+				}
+				cells := make(map[Vector]bool)
+				for v := range board.cells {
+					cells[v] = true
+				}
+				for _, v := range piece.instance() {
+					cells[v.plus(board.currentPosition)] = true
+				}
+				if score := evaluateStack(cells); score > bestScore {
+					bestScore = score
+					best = append(events, QuickDrop)
+				}
+			}
+			piece.currentRotation, board.currentPosition = rotation, position
+		}
+	}
+	return best
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Score a stack for the bot: complete lines are good, and height, holes, and bumpiness are bad.
+func evaluateStack(cells map[Vector]bool) int {
+	lines := 0
+	for y := 0; y < height; y++ {
+		full := true
+		for x := 0; x < width; x++ {
+			if !cells[Vector{x, y}] {
+				full = false
+			}
+		}
+		if full {
+			lines++
+		}
+	}
+	holes, totalHeight, bumpiness := 0, 0, 0
+	previous := -1
+	for x := 0; x < width; x++ {
+		columnHeight := 0
+		for y := 0; y < height; y++ {
+			if cells[Vector{x, y}] {
+				if columnHeight == 0 {
+					columnHeight = height - y
+				}
+			} else if columnHeight > 0 {
+				holes++
+			}
+		}
+		totalHeight += columnHeight
+		if previous >= 0 {
+			bumpiness += abs(columnHeight - previous)
+		}
+		previous = columnHeight
+	}
+	return lines*76 - totalHeight*51 - holes*36 - bumpiness*18
+}
+
+// Play a game with the bot until it's over or its clock reaches maxMillis, waiting delay milliseconds
+// between inputs.
+func botPlay(game *Game, maxMillis, delay int) {
+	for !game.Over() && game.clock < maxMillis {
+		game.Advance(delay)
+		events := botMoves(game)
+		if events == nil {
+			// The next piece hasn't appeared yet.
+			game.Advance(1)
+			continue
+		}
+		for _, event := range events {
+			game.Advance(delay)
+			game.Handle(event)
+		}
+		game.Advance(delay)
+	}
+}
+
+// Draw the stack as text, for failure messages.
+func dumpBoard(game *Game) string {
+	var b strings.Builder
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if game.board.cells.contains(Vector{x, y}) {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
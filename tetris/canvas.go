@@ -0,0 +1,110 @@
+//go:build js && wasm
+
+package tetris
+
+import (
+	"errors"
+	"strings"
+	"syscall/js"
+)
+
+// The size of a cell on a Canvas, in pixels.
+const (
+	canvasCellWidth  = 10
+	canvasCellHeight = 20
+)
+
+// A Canvas is a Console on an HTML canvas, for playing games in a browser with WebAssembly (without a
+// server). It draws the cells like the page of a WebConsole does, and reads key presses from the
+// document.
+type Canvas struct {
+	element, context js.Value
+	// The cells drawn since the last Flush, and those which the canvas is showing.
+	back, front *Buffer
+	keydown     js.Func
+	*consoleEvents
+}
+
+// Make a Console which draws on a canvas element (resizing it to fit the screen), and listens for keys
+// pressed in the document until it's closed.
+func NewCanvas(element js.Value) *Canvas {
+	canvas := &Canvas{
+		element:       element,
+		context:       element.Call("getContext", "2d"),
+		back:          NewBuffer(ScreenWidth, ScreenHeight),
+		front:         NewBuffer(ScreenWidth, ScreenHeight),
+		consoleEvents: newConsoleEvents(),
+	}
+	canvas.keydown = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		canvas.keyPressed(args[0])
+		return nil
+	})
+	js.Global().Get("document").Call("addEventListener", "keydown", canvas.keydown)
+	element.Set("width", canvas.back.Width*canvasCellWidth)
+	element.Set("height", canvas.back.Height*canvasCellHeight)
+	return canvas
+}
+
+// Queue a keydown event's key, named as the web page names them (see webKeyEvent). It's called by the
+// browser's event loop, so it mustn't block: keys are dropped if too many are waiting.
+func (canvas *Canvas) keyPressed(event js.Value) {
+	if event.Get("altKey").Bool() || event.Get("metaKey").Bool() {
+		return
+	}
+	name := event.Get("key").String()
+	if event.Get("ctrlKey").Bool() {
+		if len(name) != 1 {
+			return
+		}
+		name = "ctrl-" + strings.ToLower(name)
+	}
+	key, ok := webKeyEvent(name)
+	if !ok {
+		return
+	}
+	select {
+	case canvas.keys <- key:
+	default:
+	}
+	// The game's keys shouldn't also scroll the page (or reload it, for ctrl-r).
+	if key.Ch == 0 && key.Key != KeyEnter && key.Key != KeyCtrlC {
+		event.Call("preventDefault")
+	}
+}
+
+func (canvas *Canvas) SetCell(x, y int, ch rune, fg, bg Color) {
+	canvas.back.SetCell(x, y, ch, fg, bg)
+}
+
+func (canvas *Canvas) Clear(fg, bg Color) {
+	canvas.back.Clear(fg, bg)
+}
+
+// Draw the cells which have changed since the last Flush.
+func (canvas *Canvas) Flush() error {
+	context := canvas.context
+	context.Set("font", "16px monospace")
+	context.Set("textAlign", "center")
+	context.Set("textBaseline", "middle")
+	canvas.back.Update(canvas.front, func(x, y int, cell Cell) {
+		left := x * canvasCellWidth
+		top := y * canvasCellHeight
+		context.Set("fillStyle", webColor(cell.Bg, imageColors[BackgroundColor]))
+		context.Call("fillRect", left, top, canvasCellWidth, canvasCellHeight)
+		context.Set("fillStyle", webColor(cell.Fg, imageTextColor))
+		if cell.Ch == '▀' {
+			// Half blocks are drawn as rectangles, so that they line up exactly.
+			context.Call("fillRect", left, top, canvasCellWidth, canvasCellHeight/2)
+		} else if cell.Ch != ' ' {
+			context.Call("fillText", string(cell.Ch), left+canvasCellWidth/2, top+canvasCellHeight/2)
+		}
+	})
+	return nil
+}
+
+// Stop listening for keys. PollEvent returns EventError from then on.
+func (canvas *Canvas) Close() {
+	js.Global().Get("document").Call("removeEventListener", "keydown", canvas.keydown)
+	canvas.keydown.Release()
+	canvas.end(errors.New("the canvas was closed"))
+}
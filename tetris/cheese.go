@@ -0,0 +1,86 @@
+package tetris
+
+import (
+	"fmt"
+)
+
+// The most garbage rows that are on the board at once in cheese mode. As they're cleared, more rise from the
+// bottom until the target is in sight.
+const cheeseRows = 10
+
+// In cheese mode (also known as a dig race) the board starts with garbage rows, each with a single hole, and
+// the goal is to clear a number of them (10, normally) as quickly as possible. The timer starts with the
+// player's first input. The Messiness option controls how often the holes move between columns.
+type cheeseMode struct {
+	modeBase
+	// The column of the hole in the most recently raised garbage row, or -1 if none have been raised yet.
+	hole int
+}
+
+func (mode *cheeseMode) Setup(game *Game) {
+	mode.refill(game)
+}
+
+// Raise enough garbage rows that there are as many on the board as there should be.
+func (mode *cheeseMode) refill(game *Game) {
+	want := mode.options.Lines - game.garbageCleared
+	if want > cheeseRows {
+		want = cheeseRows
+	}
+	var holes []int
+	for i := game.board.garbageRows(); i < want; i++ {
+		mode.hole = game.garbageHole(mode.hole, mode.options.Messiness)
+		holes = append(holes, mode.hole)
+	}
+	game.raiseGarbage(holes)
+}
+
+// The number of garbage lines cleared so far, counting those that are being cleared right now.
+func (mode *cheeseMode) cleared(game *Game) int {
+	cleared := game.garbageCleared
+	for _, y := range game.clearingRows {
+		if game.board.rowHasGarbage(y) {
+			cleared++
+		}
+	}
+	return cleared
+}
+
+func (mode *cheeseMode) Update(game *Game) {
+	// The game ends as soon as the last garbage line is completed, like in sprint mode.
+	if mode.cleared(game) >= mode.options.Lines {
+		game.finish()
+		return
+	}
+	if game.clearingRows == nil {
+		mode.refill(game)
+	}
+}
+
+func (mode *cheeseMode) Status(game *Game) []string {
+	millis := game.TimeSinceFirstInput()
+	return []string{
+		fmt.Sprintf("Dug    %d/%d", game.garbageCleared, mode.options.Lines),
+		fmt.Sprintf("Time   %s", FormatMillis(millis)),
+		fmt.Sprintf("PPS    %s", piecesPerSecond(game.piecesPlaced, millis)),
+	}
+}
+
+func (mode *cheeseMode) Results(game *Game) []string {
+	millis := game.TimeSinceFirstInput()
+	results := []string{
+		fmt.Sprintf("Time    %s", FormatMillis(millis)),
+		fmt.Sprintf("Pieces  %d", game.piecesPlaced),
+		fmt.Sprintf("PPS     %s", piecesPerSecond(game.piecesPlaced, millis)),
+	}
+	if !game.finished {
+		dug := fmt.Sprintf("Dug     %d/%d", game.garbageCleared, mode.options.Lines)
+		results = append([]string{dug}, results...)
+	}
+	return results
+}
+
+func (mode *cheeseMode) Result(game *Game) (Result, bool) {
+	millis := game.TimeSinceFirstInput()
+	return Result{Value: millis, LowerIsBetter: true, Display: FormatMillis(millis)}, game.finished
+}
@@ -0,0 +1,91 @@
+package tetris
+
+import (
+	"testing"
+)
+
+// The column of the hole in each row of garbage on the board, from the bottom up.
+func garbageHoles(board *Board) []int {
+	var holes []int
+	for y := height - 1; y >= 0 && board.rowHasGarbage(y); y-- {
+		for x := 0; x < width; x++ {
+			if !board.cells.contains(Vector{x, y}) {
+				holes = append(holes, x)
+				break
+			}
+		}
+	}
+	return holes
+}
+
+func TestCheeseMessiness(t *testing.T) {
+	for _, messiness := range []int{0, 100} {
+		mode, err := NewMode("cheese", ModeOptions{Lines: 18, Messiness: messiness})
+		if err != nil {
+			t.Fatal(err)
+		}
+		game := NewGame(3, mode)
+		holes := garbageHoles(game.board)
+		if len(holes) != cheeseRows {
+			t.Fatalf("the game starts with %d garbage rows; want %d\n%s", len(holes), cheeseRows,
+				dumpBoard(game))
+		}
+		for i := 1; i < len(holes); i++ {
+			if same := holes[i] == holes[i-1]; same != (messiness == 0) {
+				t.Errorf("with messiness %d, the holes are in columns %v", messiness, holes)
+				break
+			}
+		}
+	}
+}
+
+func TestCheese(t *testing.T) {
+	for _, messiness := range []int{0, 30} {
+		mode, err := NewMode("cheese", ModeOptions{Lines: 3, Messiness: messiness})
+		if err != nil {
+			t.Fatal(err)
+		}
+		game := NewGame(3, mode)
+		botPlay(game, 600000, 5)
+		if !game.Finished() {
+			t.Errorf("with messiness %d, the bot topped out after digging %d rows\n%s",
+				messiness, game.garbageCleared, dumpBoard(game))
+			continue
+		}
+		if dug := mode.(*cheeseMode).cleared(game); dug < 3 {
+			t.Errorf("with messiness %d, the game finished after digging %d rows", messiness, dug)
+		}
+		replayed, err := game.Replay().Simulate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !replayed.Finished() || replayed.clock != game.clock ||
+			replayed.piecesPlaced != game.piecesPlaced {
+			t.Errorf("with messiness %d, the replay doesn't match the game", messiness)
+		}
+	}
+}
+
+func TestCheeseRefill(t *testing.T) {
+	mode, err := NewMode("cheese", ModeOptions{Lines: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(3, mode)
+	// Dig out the top garbage row by hand.
+	y := height - cheeseRows
+	for x := 0; x < width; x++ {
+		delete(game.board.cells, Vector{x, y})
+	}
+	game.garbageCleared++
+	mode.Update(game)
+	if rows := game.board.garbageRows(); rows != cheeseRows {
+		t.Fatalf("after digging 1 of 12 rows there are %d garbage rows; want %d", rows, cheeseRows)
+	}
+	// Once every row left to dig is on the board, no more rise.
+	game.garbageCleared = 5
+	mode.Update(game)
+	if rows := game.board.garbageRows(); rows != cheeseRows {
+		t.Fatalf("after digging 5 of 12 rows there are %d garbage rows; want %d", rows, cheeseRows)
+	}
+}
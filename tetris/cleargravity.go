@@ -0,0 +1,153 @@
+package tetris
+
+import (
+	"fmt"
+)
+
+// ClearGravity is how the blocks above cleared rows fall once the rows are removed.
+type ClearGravity int
+
+const (
+	// Every row above a cleared row moves down by one, keeping its shape, even if that leaves blocks hanging
+	// over holes. This is how tetris has always worked.
+	ClearNaive ClearGravity = iota
+	// Connected blocks of the same color stick together and fall as a unit until they land on something.
+	ClearSticky
+	// Every block falls on its own until it lands on something, filling any holes below it.
+	ClearCascade
+)
+
+var clearGravityNames = map[ClearGravity]string{
+	ClearNaive:   "naive",
+	ClearSticky:  "sticky",
+	ClearCascade: "cascade",
+}
+
+func (gravity ClearGravity) String() string {
+	if name, ok := clearGravityNames[gravity]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Find the line clear gravity with the given name (as returned by ClearGravity.String).
+func ParseClearGravity(name string) (ClearGravity, error) {
+	for gravity, gravityName := range clearGravityNames {
+		if gravityName == name {
+			return gravity, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown line clear gravity %q", name)
+}
+
+// Remove the cells of every complete row without moving anything else.
+func (board *Board) removeCompleteRows() {
+	for _, y := range board.clearedRows() {
+		for x := 0; x < width; x++ {
+			delete(board.cells, Vector{x, y})
+			delete(board.lockTimes, Vector{x, y})
+		}
+	}
+}
+
+// Let every block fall as far as it can on its own, column by column.
+func (board *Board) settleCells() {
+	for x := 0; x < width; x++ {
+		target := height - 1
+		for y := height - 1; y >= 0; y-- {
+			from := Vector{x, y}
+			if !board.cells.contains(from) {
+				continue
+			}
+			if to := (Vector{x, target}); to != from {
+				board.moveCell(from, to)
+				delete(board.cells, from)
+				delete(board.lockTimes, from)
+			}
+			target--
+		}
+	}
+}
+
+// Find the groups of connected blocks of the same color. Groups are found starting from the bottom of the
+// board, so a group comes before any group whose lowest block is higher up.
+func (board *Board) blobs() [][]Vector {
+	var blobs [][]Vector
+	seen := make(map[Vector]bool)
+	for y := height - 1; y >= 0; y-- {
+		for x := 0; x < width; x++ {
+			start := Vector{x, y}
+			if seen[start] || !board.cells.contains(start) {
+				continue
+			}
+			color := board.cells[start]
+			seen[start] = true
+			blob := []Vector{start}
+			for i := 0; i < len(blob); i++ {
+				for _, direction := range []Vector{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+					next := blob[i].plus(direction)
+					if seen[next] || !board.cells.contains(next) || board.cells[next] != color {
+						continue
+					}
+					seen[next] = true
+					blob = append(blob, next)
+				}
+			}
+			blobs = append(blobs, blob)
+		}
+	}
+	return blobs
+}
+
+// Let every group of connected blocks of the same color fall as far as it can as a unit. Groups are dropped
+// from the bottom up, and that's repeated until nothing moves, since a group can be held up by one that falls
+// later.
+func (board *Board) settleBlobs() {
+	for moved := true; moved; {
+		moved = false
+		for _, blob := range board.blobs() {
+			if distance := board.dropDistance(blob); distance > 0 {
+				board.moveBlob(blob, Vector{0, distance})
+				moved = true
+			}
+		}
+	}
+}
+
+// Find how many rows a group of blocks can fall before it lands on the floor or on another block.
+func (board *Board) dropDistance(blob []Vector) int {
+	inBlob := make(map[Vector]bool)
+	for _, point := range blob {
+		inBlob[point] = true
+	}
+	distance := 0
+	for {
+		for _, point := range blob {
+			below := point.plus(Vector{0, distance + 1})
+			if board.overlaps(below) && !inBlob[below] {
+				return distance
+			}
+		}
+		distance++
+	}
+}
+
+// Move a group of blocks by the given offset, keeping their colors and lock times.
+func (board *Board) moveBlob(blob []Vector, offset Vector) {
+	colors := make(ColorMap)
+	lockTimes := make(map[Vector]int)
+	for _, point := range blob {
+		colors[point] = board.cells[point]
+		if lockTime, ok := board.lockTimes[point]; ok {
+			lockTimes[point] = lockTime
+		}
+		delete(board.cells, point)
+		delete(board.lockTimes, point)
+	}
+	for point, color := range colors {
+		board.cells[point.plus(offset)] = color
+	}
+	for point, lockTime := range lockTimes {
+		board.lockTimes[point.plus(offset)] = lockTime
+	}
+}
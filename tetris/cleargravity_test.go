@@ -0,0 +1,127 @@
+package tetris
+
+import (
+	"strings"
+	"testing"
+)
+
+var testColors = map[byte]Color{'R': ColorRed, 'G': ColorGreen, 'B': ColorBlue, 'Y': ColorYellow}
+
+// Fill the bottom rows of a board from a picture of them, with a letter for each block's color (see
+// testColors) and a dot for each empty cell.
+func boardFromRows(rows ...string) *Board {
+	board := newBoard()
+	for i, row := range rows {
+		y := height - len(rows) + i
+		for x := 0; x < width; x++ {
+			if color, ok := testColors[row[x]]; ok {
+				board.cells[Vector{x, y}] = color
+			}
+		}
+	}
+	return board
+}
+
+// Draw the bottom rows of a board like boardFromRows reads them.
+func boardRows(board *Board, rows int) []string {
+	var pictures []string
+	for y := height - rows; y < height; y++ {
+		var picture strings.Builder
+		for x := 0; x < width; x++ {
+			letter := byte('.')
+			for l, color := range testColors {
+				if board.cells.contains(Vector{x, y}) && board.cells[Vector{x, y}] == color {
+					letter = l
+				}
+			}
+			picture.WriteByte(letter)
+		}
+		pictures = append(pictures, picture.String())
+	}
+	return pictures
+}
+
+func TestClearGravity(t *testing.T) {
+	start := []string{
+		"..........",
+		"RR.......B",
+		"GGGGGGGGGG",
+		"Y.YYYYYYY.",
+	}
+	for _, test := range []struct {
+		gravity ClearGravity
+		want    []string
+	}{
+		{ClearNaive, []string{
+			"..........",
+			"..........",
+			"RR.......B",
+			"Y.YYYYYYY.",
+		}},
+		// The red pair falls together until its left block lands.
+		{ClearSticky, []string{
+			"..........",
+			"..........",
+			"RR........",
+			"Y.YYYYYYYB",
+		}},
+		// Every block falls as far as it can, completing the bottom row.
+		{ClearCascade, []string{
+			"..........",
+			"..........",
+			"R.........",
+			"YRYYYYYYYB",
+		}},
+	} {
+		board := boardFromRows(start...)
+		board.clearRows(test.gravity)
+		if got := boardRows(board, len(start)); strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("with %s gravity the board ends up as\n%s\nwant\n%s",
+				test.gravity, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
+
+func TestClearGravityChain(t *testing.T) {
+	mode, err := NewMode("sprint", ModeOptions{ClearGravity: ClearCascade})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(1, mode)
+	game.board = boardFromRows(
+		"RR.......B",
+		"GGGGGGGGGG",
+		"Y.YYYYYYY.",
+	)
+	game.clearingRows = game.board.clearedRows()
+	game.finishClear()
+	if game.chain != 1 || len(game.clearingRows) != 1 || game.score != 100 {
+		t.Fatalf("after the first clear, the chain is %d with %d rows clearing and %d points; want 1, 1, 100",
+			game.chain, len(game.clearingRows), game.score)
+	}
+	// The second link of the chain scores double.
+	game.finishClear()
+	if game.chain != 0 || game.clearingRows != nil || game.score != 300 || game.lines != 2 {
+		t.Fatalf("after the chain, the chain is %d with %d rows clearing, %d points and %d lines; "+
+			"want 0, 0, 300, 2", game.chain, len(game.clearingRows), game.score, game.lines)
+	}
+}
+
+func TestClearGravityReplay(t *testing.T) {
+	for _, gravity := range []ClearGravity{ClearSticky, ClearCascade} {
+		mode, err := NewMode("sprint", ModeOptions{ClearGravity: gravity})
+		if err != nil {
+			t.Fatal(err)
+		}
+		game := NewGame(5, mode)
+		botPlay(game, 200000, 5)
+		replayed, err := game.Replay().Simulate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if replayed.clock != game.clock || replayed.Lines() != game.Lines() ||
+			replayed.Score() != game.Score() {
+			t.Errorf("with %s gravity, the replay doesn't match the game", gravity)
+		}
+	}
+}
@@ -1,9 +1,5 @@
 package tetris
 
-import (
-	"github.com/nsf/termbox-go"
-)
-
 const (
 	// The width of the game board in game cells (each game cell is two terminal cells wide).
 	width = 10
@@ -11,5 +7,7 @@ const (
 	height = 18
 	// The background color of the game. It's necessary to set this to ensure that the colors work well with any
 	// terminal background color.
-	backgroundColor = termbox.ColorBlack
+	BackgroundColor = ColorBlack
+	// The color of garbage blocks (and of gray fumen blocks).
+	garbageColor = ColorDarkGray
 )
@@ -0,0 +1,151 @@
+package tetris
+
+import "sync"
+
+// A Color is the color of a cell's character or background: one of the 16 colors that terminals have, or the
+// terminal's default. The colors have the same values as termbox's.
+type Color uint16
+
+const (
+	ColorDefault Color = iota
+	ColorBlack
+	ColorRed
+	ColorGreen
+	ColorYellow
+	ColorBlue
+	ColorMagenta
+	ColorCyan
+	ColorWhite
+	ColorDarkGray
+	ColorLightRed
+	ColorLightGreen
+	ColorLightYellow
+	ColorLightBlue
+	ColorLightMagenta
+	ColorLightCyan
+	ColorLightGray
+)
+
+// A Key is a special key on the keyboard. Control keys are the control characters that they send (e.g. ctrl-c
+// is 3), as they are with termbox.
+type Key uint16
+
+const (
+	KeyCtrlC Key = 0x03
+	KeyEnter Key = 0x0d
+	KeyCtrlR Key = 0x12
+	KeyEsc   Key = 0x1b
+	KeySpace Key = 0x20
+
+	KeyArrowUp Key = 0xffff - iota
+	KeyArrowDown
+	KeyArrowLeft
+	KeyArrowRight
+)
+
+// The types of Event.
+type EventType int
+
+const (
+	// A key was pressed.
+	EventKey EventType = iota
+	// The console changed size, and needs to be redrawn.
+	EventResize
+	// The console's input has ended, or reading it failed.
+	EventError
+	// The console was interrupted (see Console.Interrupt).
+	EventInterrupt
+	// Something happened which doesn't matter to the game.
+	EventNone
+)
+
+// An Event is something that happens at a Console, like a key press.
+type Event struct {
+	Type EventType
+	// For EventKey, the key: a character (Ch), or if Ch is 0, a special key (Key).
+	Key Key
+	Ch  rune
+	// For EventError, what went wrong.
+	Err error
+}
+
+// A Console is somewhere that games are played: a Screen, along with the player's key presses. It's the
+// terminal that termbox manages for a local game, but it can also be a Terminal at the other end of a
+// connection, a web page, and so on.
+type Console interface {
+	Screen
+	// Blank every cell of the screen (not just the area that the game interface uses).
+	Clear(fg, bg Color)
+	// Wait for the next event. Once the input has ended, it returns EventError every time.
+	PollEvent() Event
+	// Make PollEvent return an EventInterrupt, straight away if it's waiting, so that the screen can be
+	// updated while waiting for the player.
+	Interrupt()
+}
+
+// A consoleEvents is the queue of events for a Console (other than termbox's) whose key presses come from
+// another goroutine: one reading a connection, say. Resizes and interrupts can also be signalled at any time.
+type consoleEvents struct {
+	// The key presses, which is closed once the input ends (with err saying why).
+	keys chan Event
+	err  error
+	// Resizes and interrupts, which don't wait for PollEvent. They're dropped if too many are waiting.
+	signals chan Event
+	// A channel which is closed when the console is, so that the keys aren't waited for any more.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newConsoleEvents() *consoleEvents {
+	return &consoleEvents{
+		keys:    make(chan Event, 100),
+		signals: make(chan Event, 10),
+		done:    make(chan struct{}),
+	}
+}
+
+// Add a key press (or another event that must not be dropped) to the queue, waiting until there's room.
+// Returns false if the console was closed instead.
+func (events *consoleEvents) key(event Event) bool {
+	select {
+	case events.keys <- event:
+		return true
+	case <-events.done:
+		return false
+	}
+}
+
+// Mark the end of the input. Only the goroutine that adds the key presses can call it, once.
+func (events *consoleEvents) end(err error) {
+	events.err = err
+	close(events.keys)
+}
+
+// Add a resize or an interrupt to the queue, unless it's full.
+func (events *consoleEvents) signal(event Event) {
+	select {
+	case events.signals <- event:
+	default:
+	}
+}
+
+func (events *consoleEvents) PollEvent() Event {
+	select {
+	case event := <-events.signals:
+		return event
+	case event, ok := <-events.keys:
+		if !ok {
+			return Event{Type: EventError, Err: events.err}
+		}
+		return event
+	}
+}
+
+func (events *consoleEvents) Interrupt() {
+	events.signal(Event{Type: EventInterrupt})
+}
+
+// Stop waiting to add key presses to the queue (see key).
+func (events *consoleEvents) close() {
+	events.closeOnce.Do(func() { close(events.done) })
+}
@@ -1,7 +1,7 @@
 package tetris
 
 import (
-	"github.com/nsf/termbox-go"
+	"strings"
 )
 
 /*
@@ -30,43 +30,67 @@ var (
 	headerHeight       = 5
 	previewHeight      = 6
 	sidebarWidth       = 20
-	instructionsHeight = 11
+	instructionsHeight = 14
 
 	// The internal cells (the board cells) are treated as pairs, so to keep them on even x coordinates we'll
 	// put an empty column on the left side.
 	totalHeight = headerHeight + height + instructionsHeight + 2
 	totalWidth  = (width * 2) + sidebarWidth + 1
+
+	// How long each on/off flash of the rows being cleared lasts.
+	lineClearFlashMillis = 80
 )
 
-// Our own wrapper around termbox.SetCell which knows the background color we're using.
-func setCell(x, y int, ch rune, fg termbox.Attribute) {
-	termbox.SetCell(x, y, ch, fg, backgroundColor)
+// Our own wrapper around Screen.SetCell which knows the background color we're using.
+func setCell(screen Screen, x, y int, ch rune, fg Color) {
+	screen.SetCell(x, y, ch, fg, BackgroundColor)
 }
 
 // A board cell is two terminal cells wide, for squaritude. Only need to set the whole bg color (for filling
 // in a cell).
-func setBoardCell(x, y int, color termbox.Attribute) {
-	termbox.SetCell(x, y, ' ', termbox.ColorDefault, color)
-	termbox.SetCell(x+1, y, ' ', termbox.ColorDefault, color)
+func setBoardCell(screen Screen, x, y int, color Color) {
+	screen.SetCell(x, y, ' ', ColorDefault, color)
+	screen.SetCell(x+1, y, ' ', ColorDefault, color)
+}
+
+// Draw a board cell as an outline in the given color, for blocks which are about to disappear.
+func setBoardCellOutline(screen Screen, x, y int, color Color) {
+	screen.SetCell(x, y, '[', color, BackgroundColor)
+	screen.SetCell(x+1, y, ']', color, BackgroundColor)
+}
+
+// Draw a board cell in the given color, marked with '><', for blocks in the wrong place.
+func setBoardCellMistake(screen Screen, x, y int, color Color) {
+	screen.SetCell(x, y, '>', ColorBlack, color)
+	screen.SetCell(x+1, y, '<', ColorBlack, color)
 }
 
 // Print a message in white text.
-func printString(x, y int, message string) {
-	for i, ch := range message {
-		setCell(x+i, y, ch, termbox.ColorWhite)
+func PrintString(screen Screen, x, y int, message string) {
+	for i, ch := range []rune(message) {
+		setCell(screen, x+i, y, ch, ColorWhite)
+	}
+}
+
+// Print a message in white text, truncated or padded with spaces to the given width.
+func printPadded(screen Screen, x, y int, message string, width int) {
+	runes := []rune(message)
+	if len(runes) > width {
+		runes = runes[:width]
 	}
+	PrintString(screen, x, y, string(runes)+strings.Repeat(" ", width-len(runes)))
 }
 
 // Print a message vertically in white text.
-func printStringVertical(x, y int, message string) {
-	for i, ch := range message {
-		setCell(x, y+i, ch, termbox.ColorWhite)
+func printStringVertical(screen Screen, x, y int, message string) {
+	for i, ch := range []rune(message) {
+		setCell(screen, x, y+i, ch, ColorWhite)
 	}
 }
 
 // Print a box-drawing border character.
-func printBorderCharacter(x, y int, ch rune) {
-	setCell(x, y, ch, termbox.ColorBlue)
+func printBorderCharacter(screen Screen, x, y int, ch rune) {
+	setCell(screen, x, y, ch, ColorBlue)
 }
 
 var digitToAsciiArt = map[int][]string{0: []string{" __ ", "/  \\", "\\__/"},
@@ -82,61 +106,97 @@ var digitToAsciiArt = map[int][]string{0: []string{" __ ", "/  \\", "\\__/"},
 }
 
 // Print the current score in big ascii art digits
-func drawDigitAsAscii(x, y, digit int) {
+func drawDigitAsAscii(screen Screen, x, y, digit int) {
 	for i, line := range digitToAsciiArt[digit] {
-		printString(x, y+i, line)
+		PrintString(screen, x, y+i, line)
 	}
 }
 
+// Draw the static parts of the game interface, with the usual controls below the game board.
+func drawStaticBoardParts(screen Screen) {
+	DrawStaticParts(screen, controls)
+}
+
+// The controls listed below the game board.
+var controls = []string{"Controls:",
+	"",
+	"Move left       left arrow or 'h'",
+	"Move right      right arrow or 'l'",
+	"Move down       down arrow or 'j'",
+	"Rotate piece    up arrow or 'k'",
+	"Quick drop      space",
+	"Pause/Resume    'p'",
+	"Save fumen      'f'",
+	"Retry           'r'",
+	"Undo/Redo       'u' / ctrl-r",
+	"Quit            ctrl-c or 'q'",
+}
+
 /*
 // See http://en.wikipedia.org/wiki/Box-drawing_character for unicode characters.
 */
-func drawStaticBoardParts() {
+// Draw the static parts of the game interface, with the given instructions below the game board.
+func DrawStaticParts(screen Screen, instructions []string) {
 	// Make the whole board area the background color.
 	for x := 0; x < totalWidth+4; x++ {
 		for y := 0; y < totalHeight+2; y++ {
-			termbox.SetCell(x, y, ' ', termbox.ColorDefault, backgroundColor)
+			screen.SetCell(x, y, ' ', ColorDefault, BackgroundColor)
 		}
 	}
 
 	// Print the borders.
 	for x := 2; x < totalWidth+2; x++ {
-		printBorderCharacter(x, 0, '─')
-		printBorderCharacter(x, headerHeight+1, '─')
-		printBorderCharacter(x, headerHeight+height+2, '─')
-		printBorderCharacter(x, totalHeight+1, '─')
+		printBorderCharacter(screen, x, 0, '─')
+		printBorderCharacter(screen, x, headerHeight+1, '─')
+		printBorderCharacter(screen, x, headerHeight+height+2, '─')
+		printBorderCharacter(screen, x, totalHeight+1, '─')
 	}
 	for x := width + 2; x < totalWidth+2; x++ {
-		printBorderCharacter(x, headerHeight+previewHeight+2, '─')
+		printBorderCharacter(screen, x, headerHeight+previewHeight+2, '─')
 	}
 	for y := 1; y < totalHeight+1; y++ {
-		printBorderCharacter(1, y, '│')
-		printBorderCharacter(totalWidth+2, y, '│')
+		printBorderCharacter(screen, 1, y, '│')
+		printBorderCharacter(screen, totalWidth+2, y, '│')
 	}
 	// Bold borders around the board
 	for x := 2; x < (width*2)+2; x++ {
-		printBorderCharacter(x, headerHeight+1, '━')
-		printBorderCharacter(x, headerHeight+height+2, '━')
+		printBorderCharacter(screen, x, headerHeight+1, '━')
+		printBorderCharacter(screen, x, headerHeight+height+2, '━')
 	}
 	for y := headerHeight + 2; y < headerHeight+height+2; y++ {
-		printBorderCharacter(1, y, '┃')
-		printBorderCharacter((width*2)+2, y, '┃')
+		printBorderCharacter(screen, 1, y, '┃')
+		printBorderCharacter(screen, (width*2)+2, y, '┃')
 	}
 	// Print the various corners
-	printBorderCharacter(1, 0, '┌')
-	printBorderCharacter(totalWidth+2, 0, '┐')
-	printBorderCharacter(totalWidth+2, totalHeight+1, '┘')
-	printBorderCharacter(1, totalHeight+1, '└')
-	printBorderCharacter(1, headerHeight+1, '┢')
-	printBorderCharacter((width*2)+2, headerHeight+1, '┱')
-	printBorderCharacter(totalWidth+2, headerHeight+1, '┤')
-	printBorderCharacter((width*2)+2, headerHeight+previewHeight+2, '┠')
-	printBorderCharacter(totalWidth+2, headerHeight+previewHeight+2, '┤')
-	printBorderCharacter(1, headerHeight+height+2, '┡')
-	printBorderCharacter((width*2)+2, headerHeight+height+2, '┹')
-	printBorderCharacter(totalWidth+2, headerHeight+height+2, '┤')
-
-	// Print the header logo
+	printBorderCharacter(screen, 1, 0, '┌')
+	printBorderCharacter(screen, totalWidth+2, 0, '┐')
+	printBorderCharacter(screen, totalWidth+2, totalHeight+1, '┘')
+	printBorderCharacter(screen, 1, totalHeight+1, '└')
+	printBorderCharacter(screen, 1, headerHeight+1, '┢')
+	printBorderCharacter(screen, (width*2)+2, headerHeight+1, '┱')
+	printBorderCharacter(screen, totalWidth+2, headerHeight+1, '┤')
+	printBorderCharacter(screen, (width*2)+2, headerHeight+previewHeight+2, '┠')
+	printBorderCharacter(screen, totalWidth+2, headerHeight+previewHeight+2, '┤')
+	printBorderCharacter(screen, 1, headerHeight+height+2, '┡')
+	printBorderCharacter(screen, (width*2)+2, headerHeight+height+2, '┹')
+	printBorderCharacter(screen, totalWidth+2, headerHeight+height+2, '┤')
+
+	drawLogo(screen)
+
+	// Print the "NEXT" text vertically
+	printStringVertical(screen, (width*2)+5, headerHeight+3, "NEXT")
+
+	// Print the "SCORE" header
+	PrintString(screen, (width*2)+10, headerHeight+previewHeight+4, "SCORE")
+
+	// Print instructions below the game board.
+	for i, message := range instructions {
+		PrintString(screen, 4, headerHeight+height+4+i, message)
+	}
+}
+
+// Print the header logo.
+func drawLogo(screen Screen) {
 	header := []string{"",
 		"   ____         _____    _        _     ",
 		"  / ___| ___   |_   _|__| |_ _ __(_)___ ",
@@ -145,27 +205,238 @@ func drawStaticBoardParts() {
 		"  \\____|\\___/    |_|\\___|\\__|_|  |_|___/",
 	}
 	for i, line := range header {
-		printString(2, i, line)
+		PrintString(screen, 2, i, line)
 	}
+}
 
-	// Print the "NEXT" text vertically
-	printStringVertical((width*2)+5, headerHeight+3, "NEXT")
+// Draw a menu screen in the style of the game interface (like the puzzle select screen): the logo and a
+// title, a list of items with the one at the given index selected (or none, if it's -1), and details at the
+// bottom. The list scrolls to keep the selected item in view.
+func DrawMenu(screen Screen, title string, items []string, selected int, details []string) {
+	for x := 0; x < ScreenWidth; x++ {
+		for y := 0; y < ScreenHeight; y++ {
+			screen.SetCell(x, y, ' ', ColorDefault, BackgroundColor)
+		}
+	}
+	drawLogo(screen)
+	printPadded(screen, 4, headerHeight+1, title, totalWidth-4)
 
-	// Print the "SCORE" header
-	printString((width*2)+10, headerHeight+previewHeight+4, "SCORE")
+	top := headerHeight + 3
+	rows := ScreenHeight - top - len(details) - 2
+	first := 0
+	if selected >= rows {
+		first = selected - rows + 1
+	}
+	for i := first; i < len(items) && i < first+rows; i++ {
+		marker := " "
+		if i == selected {
+			marker = ">"
+		}
+		printPadded(screen, 2, top+i-first, marker+" "+items[i], totalWidth)
+	}
 
-	// Print instructions below the game board.
-	instructions := []string{"Controls:",
-		"",
-		"Move left       left arrow or 'h'",
-		"Move right      right arrow or 'l'",
-		"Move down       down arrow or 'j'",
-		"Rotate piece    up arrow or 'k'",
-		"Quick drop      space",
-		"Pause/Resume    'p'",
-		"Quit            ctrl-c or 'q'",
+	for i, line := range details {
+		printPadded(screen, 4, ScreenHeight-len(details)-1+i, line, totalWidth-4)
 	}
-	for i, message := range instructions {
-		printString(4, headerHeight+height+4+i, message)
+	screen.Flush()
+}
+
+// Draw the dynamic parts of the game interface (the board, the next piece preview pane, and the score).  The
+// static parts should be drawn with the drawStaticBoardParts() function, if needed.  If clearOnly is true,
+// the board and preview pane will be cleared rather than redrawn.
+func (game *Game) DrawDynamic(screen Screen, clearOnly bool) {
+
+	flashing := game.hiddenRows()
+
+	// Print the board contents. Each block will correspond to a side-by-side pair of cells on the screen, so
+	// that the visible blocks will be roughly square.  If clearOnly is true, draw background color.
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			if clearOnly || flashing[y] {
+				setBoardCell(screen, (x*2)+2, headerHeight+y+2, BackgroundColor)
+				continue
+			}
+			switch color, appearance := game.cellAppearance(Vector{x, y}); appearance {
+			case cellOutline:
+				setBoardCellOutline(screen, (x*2)+2, headerHeight+y+2, color)
+			case cellMistake:
+				setBoardCellMistake(screen, (x*2)+2, headerHeight+y+2, color)
+			default:
+				setBoardCell(screen, (x*2)+2, headerHeight+y+2, color)
+			}
+		}
+	}
+
+	// Draw the bar of garbage waiting to rise beside the board, if the mode has one: garbage that will rise
+	// with the next piece at the bottom, and garbage that is still waiting above it.
+	if meter, ok := game.mode.(garbageMeter); ok {
+		ready, waiting := meter.incomingGarbage(game)
+		for y := 0; y < height; y++ {
+			color := BackgroundColor
+			switch row := height - 1 - y; {
+			case clearOnly:
+			case row < ready:
+				color = ColorRed
+			case row < ready+waiting:
+				color = ColorYellow
+			}
+			screen.SetCell(0, headerHeight+y+2, ' ', ColorDefault, color)
+		}
+	}
+
+	// Print the preview piece. Need to clear the box first.  Draw next piece only if clearOnly is false
+	previewPieceOffset := Vector{(width * 2) + 8, headerHeight + 3}
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 4; y++ {
+			cursor := previewPieceOffset.plus(Vector{x, y})
+			setCell(screen, cursor.x, cursor.y, ' ', ColorDefault)
+		}
+	}
+	if !clearOnly && game.nextPiece != nil {
+		for _, point := range game.nextPiece.rotations[0] {
+			cursor := previewPieceOffset.plus(Vector{point.x * 2, point.y})
+			setBoardCell(screen, cursor.x, cursor.y, game.nextPiece.color)
+		}
+	}
+
+	// Draw the current score.  If clearOnly, do the same.
+	score := game.score
+	cursor := Vector{(width * 2) + 18, headerHeight + previewHeight + 7}
+	for {
+		digit := score % 10
+		score /= 10
+		drawDigitAsAscii(screen, cursor.x, cursor.y, digit)
+		cursor = cursor.plus(Vector{-4, 0})
+		if score == 0 {
+			break
+		}
+	}
+
+	// Draw the mode's status lines below the score, followed by finesse faults if they're being counted.
+	status := append(game.mode.Status(game), game.finesseStatus()...)
+	for i := 0; headerHeight+previewHeight+10+i < headerHeight+height+2; i++ {
+		line := ""
+		if i < len(status) && !clearOnly {
+			line = status[i]
+		}
+		printPadded(screen, (width*2)+5, headerHeight+previewHeight+10+i, line, sidebarWidth-4)
+	}
+
+	// Draw the message line below the instructions.
+	printPadded(screen, 4, headerHeight+height+16, game.message, totalWidth-4)
+
+	// Flush the screen's internal state (e.g. the console's) to the display.
+	screen.Flush()
+}
+
+// The size of a miniature board (see DrawMiniBoard), including its label and borders.
+var (
+	MiniBoardWidth  = width + 2
+	MiniBoardHeight = height/2 + 2
+)
+
+// Draw a miniature of the game's board, for showing many games at once, with its top left corner at (x, y).
+// Each character cell holds two board cells, one above the other, drawn as a half block. The label goes above
+// the board, in yellow if highlight is true.
+func (game *Game) DrawMiniBoard(screen Screen, x, y int, label string, highlight bool) {
+	labelColor := ColorWhite
+	if highlight {
+		labelColor = ColorYellow
+	}
+	runes := []rune(label)
+	for i := 0; i < MiniBoardWidth; i++ {
+		ch := ' '
+		if i < len(runes) {
+			ch = runes[i]
+		}
+		setCell(screen, x+i, y, ch, labelColor)
+	}
+	for row := 0; row < height/2; row++ {
+		printBorderCharacter(screen, x, y+1+row, '│')
+		printBorderCharacter(screen, x+width+1, y+1+row, '│')
+		for column := 0; column < width; column++ {
+			top, _ := game.cellAppearance(Vector{column, row * 2})
+			bottom, _ := game.cellAppearance(Vector{column, row*2 + 1})
+			screen.SetCell(x+1+column, y+1+row, '▀', top, bottom)
+		}
+	}
+	printBorderCharacter(screen, x, y+MiniBoardHeight-1, '└')
+	for column := 0; column < width; column++ {
+		printBorderCharacter(screen, x+1+column, y+MiniBoardHeight-1, '─')
+	}
+	printBorderCharacter(screen, x+width+1, y+MiniBoardHeight-1, '┘')
+}
+
+// Find the rows which should currently be drawn as empty: rows which are being cleared flash between their
+// colors and the background color.
+func (game *Game) hiddenRows() map[int]bool {
+	hidden := make(map[int]bool)
+	if (game.clock-game.clearStart)/lineClearFlashMillis%2 == 0 {
+		for _, y := range game.clearingRows {
+			hidden[y] = true
+		}
+	}
+	return hidden
+}
+
+// Draw the pause screen, hiding the game board and next piece.
+func (game *Game) DrawPauseScreen(screen Screen) {
+	// Clear the board and preview screen
+	game.DrawDynamic(screen, true)
+
+	// Draw PAUSED overlay
+	for y := (totalHeight/2 - 1); y <= (totalHeight/2)+1; y++ {
+		for x := 1; x < totalWidth+3; x++ {
+			screen.SetCell(x, y, ' ', ColorDefault, ColorBlue)
+		}
+	}
+	for i, ch := range "PAUSED" {
+		screen.SetCell(totalWidth/2-2+i, totalHeight/2, ch, ColorWhite, ColorBlue)
+	}
+
+	// Flush to the display
+	screen.Flush()
+}
+
+// Modes can implement titler to choose their own title for the results overlay.
+type titler interface {
+	title(game *Game) string
+}
+
+// Draw the results overlay on top of the game interface: "GAME OVER" (or "FINISHED" if the game ended by
+// reaching the mode's goal, or the mode's own title), followed by the mode's results and any extra notes.
+func (game *Game) DrawGameOver(screen Screen, notes ...string) {
+	title := "GAME OVER"
+	if t, ok := game.mode.(titler); ok {
+		title = t.title(game)
+	} else if game.finished {
+		title = "FINISHED"
+	}
+	lines := append(game.Results(), notes...)
+	// The title goes in the middle of a blue band, with the other lines below it after a blank line.
+	top := totalHeight/2 - 1 - (len(lines)+1)/2
+	bottom := top + 2
+	if len(lines) > 0 {
+		bottom += len(lines) + 1
+	}
+	for y := top; y <= bottom; y++ {
+		for x := 1; x < totalWidth+3; x++ {
+			screen.SetCell(x, y, ' ', ColorDefault, ColorBlue)
+		}
+	}
+	for i, ch := range title {
+		screen.SetCell(totalWidth/2-(len(title)-1)/2+i, top+1, ch, ColorWhite, ColorBlue)
+	}
+	lineWidth := 0
+	for _, line := range lines {
+		if n := len([]rune(line)); n > lineWidth {
+			lineWidth = n
+		}
+	}
+	for i, line := range lines {
+		for j, ch := range []rune(line) {
+			screen.SetCell(totalWidth/2-(lineWidth-1)/2+j, top+3+i, ch, ColorWhite, ColorBlue)
+		}
 	}
+	screen.Flush()
 }
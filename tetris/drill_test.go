@@ -0,0 +1,218 @@
+package tetris
+
+import (
+	"strings"
+	"testing"
+)
+
+// Play a drill from the built-in pack, placing each piece by making the given moves with it and then
+// dropping it.
+func playDrill(t *testing.T, number int, plan [][]GameEvent) (*Game, *puzzleMode) {
+	mode, err := NewMode("drill", ModeOptions{Puzzle: number})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(1, mode)
+	for _, events := range plan {
+		for _, event := range events {
+			game.Handle(event)
+		}
+		game.Handle(QuickDrop)
+		game.Advance(1000)
+	}
+	return game, mode.(*puzzleMode)
+}
+
+func TestBuiltinDrillsBuildable(t *testing.T) {
+	for i, drill := range builtinDrillPack.puzzles {
+		// Find a place for each piece in turn which doesn't make any mistakes (or clear any lines).
+		var plan [][]GameEvent
+	pieces:
+		for range drill.pieces {
+			for rotations := 0; rotations < 4; rotations++ {
+				for dx := -5; dx <= 5; dx++ {
+					var events []GameEvent
+					for j := 0; j < rotations; j++ {
+						events = append(events, Rotate)
+					}
+					for j := 0; j < abs(dx); j++ {
+						if dx < 0 {
+							events = append(events, MoveLeft)
+						} else {
+							events = append(events, MoveRight)
+						}
+					}
+					next := append(append([][]GameEvent(nil), plan...), events)
+					game, mode := playDrill(t, i+1, next)
+					if game.piecesPlaced == len(next) && game.Lines() == 0 && len(mode.mistakes) == 0 {
+						plan = next
+						continue pieces
+					}
+				}
+			}
+			game, _ := playDrill(t, i+1, plan)
+			t.Fatalf("drill %d (%s): no place for piece %d\n%s", i+1, drill.title, len(plan)+1,
+				dumpBoard(game))
+		}
+		if game, _ := playDrill(t, i+1, plan); !game.Finished() {
+			t.Errorf("drill %d (%s) isn't finished after placing every piece\n%s", i+1, drill.title,
+				dumpBoard(game))
+		}
+	}
+}
+
+func TestDrillMistakes(t *testing.T) {
+	// The first piece of the first drill doesn't go in the middle of the board.
+	game, mode := playDrill(t, 1, [][]GameEvent{nil})
+	if len(mode.mistakes) == 0 || game.Over() {
+		t.Fatalf("dropping the first piece in the middle made %d mistakes (game over: %t)",
+			len(mode.mistakes), game.Over())
+	}
+	for position := range mode.mistakes {
+		if _, appearance := game.cellAppearance(position); appearance != cellMistake {
+			t.Errorf("the mistake at %v has appearance %d", position, appearance)
+		}
+	}
+	if status := strings.Join(mode.Status(game), "\n"); !strings.Contains(status, "Retry") {
+		t.Errorf("the status doesn't say how to retry:\n%s", status)
+	}
+
+	game.restart()
+	mode = game.mode.(*puzzleMode)
+	if len(mode.mistakes) != 0 || game.piecesPlaced != 0 || len(game.board.cells) != 0 {
+		t.Fatalf("restarting didn't clear the board and the mistakes")
+	}
+	// The empty parts of the target are shown as outlines.
+	for position := range mode.target {
+		if _, appearance := game.cellAppearance(position); appearance != cellOutline {
+			t.Errorf("the target cell at %v has appearance %d", position, appearance)
+		}
+	}
+}
+
+func TestDrillFromFumen(t *testing.T) {
+	drill := builtinDrillPack.puzzles[4]
+	pieces := srsPieces()
+	board := newBoard()
+	first := board.fumenPage(pieces)
+	board.cells = boardColors(drill.target, pieces)
+	second := board.fumenPage(pieces)
+	data := encodeFumen([]fumenPage{first, second})
+
+	pack, err := ReadPuzzlePack(strings.NewReader("go-tetris puzzles 1\npack fumen\npuzzle From a fumen\n" +
+		"goal build\npieces " + strings.Join(drill.pieces, "") + "\nfumen " + data + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	puzzle := pack.puzzles[0]
+	if len(puzzle.rows) != 0 || strings.Join(puzzle.target, "\n") != strings.Join(drill.target, "\n") {
+		t.Errorf("the fumen gave the board %q and the target %q; want no board and the target %q",
+			puzzle.rows, puzzle.target, drill.target)
+	}
+}
+
+func TestReadDrillErrors(t *testing.T) {
+	for _, test := range []struct {
+		pack, want string
+	}{
+		{"goal build\npieces O\n", "if and only if"},
+		{"goal lines 1\npieces O\ntarget OO........\n", "if and only if"},
+		{"goal build\npieces O\nrow #.........\ntarget .OO.......\ntarget .OO.......\n", "board's blocks"},
+		{"goal build\npieces O\ntarget .OO.......\ntarget .OOOO.....\n", "don't match the pieces"},
+		{"goal build\npieces I\nrow .#########\ntarget I.........\ntarget I.........\n" +
+			"target I.........\ntarget I#########\n", "complete row"},
+	} {
+		_, err := ReadPuzzlePack(strings.NewReader("go-tetris puzzles 1\npack x\npuzzle a\n" + test.pack))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("reading the drill %q gave the error %v; want one about %q", test.pack, err, test.want)
+		}
+	}
+}
+
+// Start a puzzle on the board that a built-in drill builds, with the given pieces and goal (as they're
+// written in a pack file), to check what the opener sets up.
+func afterDrill(t *testing.T, title, pieces, goal string) *Game {
+	for _, drill := range builtinDrillPack.puzzles {
+		if drill.title != title {
+			continue
+		}
+		data := "go-tetris puzzles 1\npack after-drill\npuzzle After " + title + "\ngoal " + goal +
+			"\npieces " + pieces + "\n"
+		for _, row := range drill.target {
+			data += "row " + row + "\n"
+		}
+		pack, err := ReadPuzzlePack(strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		puzzlePacks[pack.Name] = pack
+		t.Cleanup(func() { delete(puzzlePacks, pack.Name) })
+		mode, err := NewMode("puzzle", ModeOptions{Pack: pack.Name, Puzzle: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewGame(1, mode)
+	}
+	t.Fatalf("there's no drill called %q", title)
+	return nil
+}
+
+// Make some moves with the current piece, let it fall as far as it goes, and make some more moves with it
+// there (like sliding it under an overhang, or spinning it into a slot) before locking it. This returns
+// whether the piece was a T-spin.
+func tuck(game *Game, before, after []GameEvent) (tSpin bool) {
+	for _, event := range before {
+		game.Handle(event)
+	}
+	for game.board.moveIfPossible(Vector{0, 1}) {
+	}
+	for _, event := range after {
+		game.Handle(event)
+	}
+	game.Handle(QuickDrop)
+	tSpin = game.tSpin
+	game.Advance(1000)
+	return tSpin
+}
+
+func TestTKIDrill(t *testing.T) {
+	// The next T goes into the slot on its side, and spins into a T-spin double.
+	game := afterDrill(t, "TKI", "T", "lines 2")
+	tSpin := tuck(game, []GameEvent{Rotate, MoveLeft, MoveLeft}, []GameEvent{Rotate})
+	if !tSpin || game.Lines() != 2 || !game.Finished() {
+		t.Errorf("the T cleared %d lines (T-spin: %t); want a T-spin double\n%s", game.Lines(), tSpin,
+			dumpBoard(game))
+	}
+}
+
+func TestDTCannonDrill(t *testing.T) {
+	// A T-spin double on the right uncovers a slot on the left, where the T after it slides under the
+	// overhang and spins into a T-spin triple.
+	game := afterDrill(t, "DT cannon", "TT", "lines 5")
+	before := []GameEvent{Rotate, MoveRight, MoveRight, MoveRight, MoveRight}
+	if tSpin := tuck(game, before, []GameEvent{Rotate}); game.Lines() != 2 || !tSpin {
+		t.Fatalf("the first T cleared %d lines (T-spin: %t); want a T-spin double\n%s", game.Lines(), tSpin,
+			dumpBoard(game))
+	}
+	tSpin := tuck(game, []GameEvent{MoveLeft}, []GameEvent{MoveLeft, Rotate})
+	if !tSpin || game.Lines() != 5 || !game.Finished() {
+		t.Errorf("the second T cleared %d lines (T-spin: %t); want a T-spin triple\n%s", game.Lines()-2,
+			tSpin, dumpBoard(game))
+	}
+}
+
+func TestPCODrill(t *testing.T) {
+	// The first bag's T and three pieces of the next bag make a perfect clear.
+	game := afterDrill(t, "PCO", "LJTI", "perfect")
+	for _, events := range [][]GameEvent{
+		nil,
+		{Rotate, MoveRight, MoveRight},
+		{Rotate, Rotate, MoveLeft},
+		{MoveRight, MoveRight, MoveRight},
+	} {
+		tuck(game, events, nil)
+	}
+	if !game.Finished() || len(game.board.cells) != 0 {
+		t.Errorf("the pieces didn't make a perfect clear\n%s", dumpBoard(game))
+	}
+}
@@ -0,0 +1,286 @@
+package tetris
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+)
+
+// Write a replay as an asciicast (version 2) recording of the game as it would appear in the terminal. See
+// https://docs.asciinema.org/manual/asciicast/v2/ for the format. The screen is captured fps times per second
+// of game time, and a frame is only written when something changed.
+func WriteAsciicast(w io.Writer, replay *Replay, fps int) error {
+	encoder := json.NewEncoder(w)
+	header := map[string]interface{}{
+		"version": 2,
+		"width":   ScreenWidth,
+		"height":  ScreenHeight,
+		"env":     map[string]string{"TERM": "xterm-256color"},
+	}
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+
+	buffer := NewBuffer(ScreenWidth, ScreenHeight)
+	drawStaticBoardParts(buffer)
+	// Clear the terminal and hide the cursor before the first frame.
+	prefix := "\x1b[2J\x1b[?25l\x1b[H"
+	previous := ""
+	return forEachFrame(replay, fps, func(game *Game) error {
+		game.DrawDynamic(buffer, false)
+		if game.over {
+			game.DrawGameOver(buffer)
+		}
+		frame := buffer.ANSI()
+		if frame == previous {
+			return nil
+		}
+		previous = frame
+		event := []interface{}{float64(game.clock) / 1000, "o", prefix + frame}
+		prefix = "\x1b[H"
+		return encoder.Encode(event)
+	})
+}
+
+// Play back a replay, calling frame with the game at every multiple of 1000/fps milliseconds of game time and
+// at the end of the replay.
+func forEachFrame(replay *Replay, fps int, frame func(game *Game) error) error {
+	frameMillis := 1000 / fps
+	if frameMillis < 1 {
+		frameMillis = 1
+	}
+	player, err := NewReplayPlayer(replay)
+	if err != nil {
+		return err
+	}
+	for millis := 0; ; millis += frameMillis {
+		if err := player.AdvanceTo(millis); err != nil {
+			return err
+		}
+		if err := frame(player.game); err != nil {
+			return err
+		}
+		if player.Done() {
+			return nil
+		}
+	}
+}
+
+// The colors used for drawing a game as an image. The pieces use the RGB equivalent of their terminal color.
+var imageColors = map[Color]color.Color{
+	BackgroundColor: color.RGBA{0x00, 0x00, 0x00, 0xff},
+	ColorRed:        color.RGBA{0xe0, 0x3c, 0x31, 0xff},
+	ColorGreen:      color.RGBA{0x4c, 0xbb, 0x17, 0xff},
+	ColorYellow:     color.RGBA{0xf4, 0xd0, 0x3f, 0xff},
+	ColorBlue:       color.RGBA{0x2e, 0x6f, 0xd8, 0xff},
+	ColorMagenta:    color.RGBA{0xa6, 0x4c, 0xa6, 0xff},
+	ColorCyan:       color.RGBA{0x3e, 0xc7, 0xd3, 0xff},
+	ColorWhite:      color.RGBA{0xe6, 0xe6, 0xe6, 0xff},
+	ColorDarkGray:   color.RGBA{0x6b, 0x6b, 0x6b, 0xff},
+}
+
+// The colors of the parts of an image which aren't board cells.
+var (
+	imageBorderColor = color.RGBA{0x1f, 0x3a, 0x7a, 0xff}
+	imageTextColor   = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// Dimensions of the image layout, in board cells. The board is surrounded by a border, with a sidebar on the
+// right holding the next piece and the score.
+const (
+	imageSidebarWidth = 6
+	imageColumns      = width + imageSidebarWidth + 3
+	imageRows         = height + 2
+)
+
+// 3x5 pixel font for the score digits.
+var imageDigits = [10][5]string{
+	{"###", "# #", "# #", "# #", "###"},
+	{" # ", "## ", " # ", " # ", "###"},
+	{"###", "  #", "###", "#  ", "###"},
+	{"###", "  #", "###", "  #", "###"},
+	{"# #", "# #", "###", "  #", "  #"},
+	{"###", "#  ", "###", "  #", "###"},
+	{"###", "#  ", "###", "# #", "###"},
+	{"###", "  #", "  #", "  #", "  #"},
+	{"###", "# #", "###", "# #", "###"},
+	{"###", "# #", "###", "  #", "###"},
+}
+
+// An imageRenderer draws the state of a game as an image with the given number of pixels per board cell.
+type imageRenderer struct {
+	cellSize int
+	palette  color.Palette
+	// The palette index of each color.
+	index map[color.Color]uint8
+}
+
+func newImageRenderer(cellSize int) *imageRenderer {
+	renderer := &imageRenderer{cellSize: cellSize, index: make(map[color.Color]uint8)}
+	add := func(c color.Color) {
+		if _, ok := renderer.index[c]; !ok {
+			renderer.index[c] = uint8(len(renderer.palette))
+			renderer.palette = append(renderer.palette, c)
+		}
+	}
+	add(imageColors[BackgroundColor])
+	add(imageBorderColor)
+	add(imageTextColor)
+	for attribute := ColorDefault; attribute <= ColorLightGray; attribute++ {
+		if c, ok := imageColors[attribute]; ok {
+			add(c)
+		}
+	}
+	return renderer
+}
+
+// Find the image color for a cell's Color.
+func (renderer *imageRenderer) cellColor(attribute Color) color.Color {
+	if c, ok := imageColors[attribute]; ok {
+		return c
+	}
+	return imageColors[ColorWhite]
+}
+
+// Fill a rectangle given in pixels.
+func (renderer *imageRenderer) fill(img *image.Paletted, x, y, w, h int, c color.Color) {
+	index := renderer.index[c]
+	for py := y; py < y+h; py++ {
+		for px := x; px < x+w; px++ {
+			img.SetColorIndex(px, py, index)
+		}
+	}
+}
+
+// Fill the board cell at the given position in the image layout. Blocks get a one pixel gap around them (if
+// there's room) so that the individual cells are visible.
+func (renderer *imageRenderer) fillCell(img *image.Paletted, position Vector, c color.Color) {
+	size := renderer.cellSize
+	gap := 0
+	if size >= 4 && c != imageColors[BackgroundColor] && c != imageBorderColor {
+		gap = 1
+	}
+	renderer.fill(img, position.x*size+gap, position.y*size+gap, size-2*gap, size-2*gap, c)
+}
+
+// Draw the outline of the board cell at the given position in the image layout.
+func (renderer *imageRenderer) outlineCell(img *image.Paletted, position Vector, c color.Color) {
+	size := renderer.cellSize
+	x, y := position.x*size, position.y*size
+	renderer.fill(img, x, y, size, 1, c)
+	renderer.fill(img, x, y+size-1, size, 1, c)
+	renderer.fill(img, x, y, 1, size, c)
+	renderer.fill(img, x+size-1, y, 1, size, c)
+}
+
+// Draw a number using the pixel font, with its top-left corner at the given pixel position.
+func (renderer *imageRenderer) drawNumber(img *image.Paletted, x, y int, n int, scale int) {
+	digits := []int{}
+	for {
+		digits = append([]int{n % 10}, digits...)
+		n /= 10
+		if n == 0 {
+			break
+		}
+	}
+	for i, digit := range digits {
+		for row, line := range imageDigits[digit] {
+			for col, ch := range line {
+				if ch == '#' {
+					renderer.fill(img, x+(i*4+col)*scale, y+row*scale, scale, scale, imageTextColor)
+				}
+			}
+		}
+	}
+}
+
+// Render the game.
+func (renderer *imageRenderer) render(game *Game) *image.Paletted {
+	size := renderer.cellSize
+	img := image.NewPaletted(image.Rect(0, 0, imageColumns*size, imageRows*size), renderer.palette)
+
+	// Borders around the board and sidebar
+	for x := 0; x < imageColumns; x++ {
+		renderer.fillCell(img, Vector{x, 0}, imageBorderColor)
+		renderer.fillCell(img, Vector{x, imageRows - 1}, imageBorderColor)
+	}
+	for y := 0; y < imageRows; y++ {
+		renderer.fillCell(img, Vector{0, y}, imageBorderColor)
+		renderer.fillCell(img, Vector{width + 1, y}, imageBorderColor)
+		renderer.fillCell(img, Vector{imageColumns - 1, y}, imageBorderColor)
+	}
+
+	// The board
+	hidden := game.hiddenRows()
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			if hidden[y] {
+				continue
+			}
+			attribute, appearance := game.cellAppearance(Vector{x, y})
+			switch appearance {
+			case cellOutline:
+				renderer.outlineCell(img, Vector{x + 1, y + 1}, renderer.cellColor(attribute))
+			case cellMistake:
+				renderer.fillCell(img, Vector{x + 1, y + 1}, renderer.cellColor(attribute))
+				renderer.outlineCell(img, Vector{x + 1, y + 1}, imageTextColor)
+			default:
+				renderer.fillCell(img, Vector{x + 1, y + 1}, renderer.cellColor(attribute))
+			}
+		}
+	}
+
+	// The next piece and the score
+	sidebar := Vector{width + 3, 2}
+	if game.nextPiece != nil {
+		for _, point := range game.nextPiece.rotations[0] {
+			renderer.fillCell(img, sidebar.plus(point), renderer.cellColor(game.nextPiece.color))
+		}
+	}
+	scale := size / 4
+	if scale < 1 {
+		scale = 1
+	}
+	renderer.drawNumber(img, (width+2)*size+scale, 7*size, game.score, scale)
+	return img
+}
+
+// Write a replay as an animated GIF of the board, the next piece, and the score. Each board cell is cellSize
+// pixels square, and the game is captured fps times per second of game time (GIF frame delays are in
+// hundredths of a second, so at most 100).
+func WriteGIF(w io.Writer, replay *Replay, cellSize, fps int) error {
+	if fps > 100 {
+		fps = 100
+	}
+	renderer := newImageRenderer(cellSize)
+	animation := new(gif.GIF)
+	// Each frame lasts from its game time until the next frame's, rounded to hundredths of a second. Working
+	// the delays out from the game times, rather than adding up 100/fps for each frame, keeps the rounding from
+	// adding up, so the animation doesn't drift from the game's own time.
+	start, end := 0, 0
+	err := forEachFrame(replay, fps, func(game *Game) error {
+		end = game.clock
+		img := renderer.render(game)
+		// Identical frames are merged by letting the previous one last longer.
+		n := len(animation.Image)
+		if n > 0 && bytes.Equal(animation.Image[n-1].Pix, img.Pix) {
+			return nil
+		}
+		if n > 0 {
+			animation.Delay[n-1] = game.clock/10 - start/10
+		}
+		start = game.clock
+		animation.Image = append(animation.Image, img)
+		animation.Delay = append(animation.Delay, 0)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Hold the final frame for a couple of seconds before looping.
+	animation.Delay[len(animation.Delay)-1] = end/10 - start/10 + 200
+	return gif.EncodeAll(w, animation)
+}
@@ -0,0 +1,47 @@
+package tetris
+
+import (
+	"bytes"
+	"image/gif"
+	"testing"
+)
+
+// Play a short game of the given mode with a fixed sequence of inputs, returning its replay.
+func scriptedReplay(t *testing.T, name string) *Replay {
+	mode, err := NewMode(name, ModeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(1, mode)
+	inputs := []GameEvent{MoveLeft, Rotate, MoveRight, MoveRight, QuickDrop, MoveLeft, MoveLeft, QuickDrop}
+	for i := 0; i < 120 && !game.Over(); i++ {
+		game.Advance(37 + i%7)
+		game.Handle(inputs[i%len(inputs)])
+	}
+	return game.Replay()
+}
+
+func TestWriteGIFKeepsTime(t *testing.T) {
+	replay := scriptedReplay(t, "classic")
+	for _, fps := range []int{7, 30, 60, 100} {
+		var b bytes.Buffer
+		if err := WriteGIF(&b, replay, 4, fps); err != nil {
+			t.Fatal(err)
+		}
+		animation, err := gif.DecodeAll(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total := 0
+		for _, delay := range animation.Delay {
+			if delay <= 0 {
+				t.Fatalf("at %d fps: a frame has a delay of %d", fps, delay)
+			}
+			total += delay
+		}
+		// The frames add up to the length of the game, plus the final frame's two seconds.
+		if want := replay.Time/10 + 200; total != want {
+			t.Errorf("at %d fps: the frames last %d hundredths of a second; want %d", fps, total, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package tetris
+
+import (
+	"fmt"
+)
+
+// FinesseTracking is whether the game keeps track of finesse: placing each piece with as few moves and
+// rotations as possible. Every input beyond the fewest that would have put a piece in the same place is an
+// extra input, and each piece placed with extra inputs is a fault.
+type FinesseTracking int
+
+const (
+	// Finesse isn't tracked.
+	FinesseOff FinesseTracking = iota
+	// Faults are counted and shown in the sidebar and on the results screen.
+	FinesseTrack
+	// Faults are counted, and the game starts over as soon as there is one.
+	FinesseStrict
+)
+
+var finesseTrackingNames = map[FinesseTracking]string{
+	FinesseOff:    "off",
+	FinesseTrack:  "track",
+	FinesseStrict: "strict",
+}
+
+func (tracking FinesseTracking) String() string {
+	if name, ok := finesseTrackingNames[tracking]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Find the finesse tracking with the given name (as returned by FinesseTracking.String).
+func ParseFinesseTracking(name string) (FinesseTracking, error) {
+	for tracking, trackingName := range finesseTrackingNames {
+		if trackingName == name {
+			return tracking, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown finesse tracking %q", name)
+}
+
+// The position and rotation of a piece.
+type pieceState struct {
+	position Vector
+	rotation int
+}
+
+// Start keeping track of the inputs for the current piece, from where it is now.
+func (game *Game) startPieceInputs() {
+	game.pieceInputs = nil
+	if piece := game.board.currentPiece; piece != nil {
+		game.pieceStart = pieceState{game.board.currentPosition, piece.currentRotation}
+	}
+}
+
+// Whether an event is an input which counts towards finesse: a move to the side or a rotation. Moving down is
+// free, like letting the piece fall.
+func (event GameEvent) isFinesseInput() bool {
+	return event == MoveLeft || event == MoveRight || event == Rotate
+}
+
+// Check the finesse of the current piece, which is about to lock, and count a fault if it took extra inputs.
+func (game *Game) checkFinesse() {
+	game.lastExtraInputs = 0
+	tracking := game.mode.Options().Finesse
+	if tracking == FinesseOff {
+		return
+	}
+	minimum := game.fewestInputs()
+	if minimum < 0 || len(game.pieceInputs) <= minimum {
+		return
+	}
+	game.lastExtraInputs = len(game.pieceInputs) - minimum
+	game.extraInputs += game.lastExtraInputs
+	game.finesseFaults++
+	if tracking == FinesseStrict {
+		game.finesseFailed = true
+	}
+}
+
+// Find the fewest moves and rotations that would take the current piece from where it started (see
+// startPieceInputs) to where it is now, on the board as it is now, letting it fall as far as it likes for
+// free. Returns -1 if it can't get there (e.g. because garbage has risen since it started).
+func (game *Game) fewestInputs() int {
+	goal := make(map[Vector]bool)
+	for _, point := range game.board.currentPiece.instance() {
+		goal[point.plus(game.board.currentPosition)] = true
+	}
+	// The search moves a copy of the piece around a board which shares the game board's cells.
+	piece := *game.board.currentPiece
+	board := &Board{cells: game.board.cells, currentPiece: &piece}
+	try := func(state pieceState, action func() bool) (pieceState, bool) {
+		board.currentPosition = state.position
+		piece.currentRotation = state.rotation
+		if !action() {
+			return state, false
+		}
+		return pieceState{board.currentPosition, piece.currentRotation}, true
+	}
+	actions := []func() bool{
+		func() bool { return board.moveIfPossible(Vector{-1, 0}) },
+		func() bool { return board.moveIfPossible(Vector{1, 0}) },
+		func() bool { return board.rotateIfPossible(game.ruleset.rotation.kicks) },
+	}
+	fall := func() bool { return board.moveIfPossible(Vector{0, 1}) }
+
+	seen := map[pieceState]bool{game.pieceStart: true}
+	frontier := []pieceState{game.pieceStart}
+	for inputs := 0; len(frontier) > 0; inputs++ {
+		// Falling is free, so everywhere below the states in the frontier takes the same number of inputs.
+		for i := 0; i < len(frontier); i++ {
+			state := frontier[i]
+			piece.currentRotation = state.rotation
+			matches := true
+			for _, point := range piece.instance() {
+				if !goal[point.plus(state.position)] {
+					matches = false
+				}
+			}
+			if matches {
+				return inputs
+			}
+			if below, ok := try(state, fall); ok && !seen[below] {
+				seen[below] = true
+				frontier = append(frontier, below)
+			}
+		}
+		var next []pieceState
+		for _, state := range frontier {
+			for _, action := range actions {
+				if moved, ok := try(state, action); ok && !seen[moved] {
+					seen[moved] = true
+					next = append(next, moved)
+				}
+			}
+		}
+		frontier = next
+	}
+	return -1
+}
+
+// A line about finesse for the sidebar: the number of faults, and the extra inputs for the last piece placed
+// if it was one.
+func (game *Game) finesseStatus() []string {
+	if game.mode.Options().Finesse == FinesseOff {
+		return nil
+	}
+	status := fmt.Sprintf("Faults %d", game.finesseFaults)
+	if game.lastExtraInputs > 0 {
+		status += fmt.Sprintf(" (+%d)", game.lastExtraInputs)
+	}
+	return []string{status}
+}
+
+// Lines about finesse for the results screen.
+func (game *Game) finesseResults() []string {
+	if game.mode.Options().Finesse == FinesseOff {
+		return nil
+	}
+	return []string{
+		"",
+		fmt.Sprintf("Faults  %d", game.finesseFaults),
+		fmt.Sprintf("Extra inputs  %d", game.extraInputs),
+	}
+}
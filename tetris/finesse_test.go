@@ -0,0 +1,89 @@
+package tetris
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFinesseFaults(t *testing.T) {
+	for _, name := range []string{"classic", "sprint", "master", "drill"} {
+		options := ModeOptions{Finesse: FinesseTrack}
+		if name == "drill" {
+			options.Puzzle = 1
+		}
+		mode, err := NewMode(name, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if description := describeMode(mode); !strings.Contains(description, "finesse=track") {
+			t.Errorf("the %s mode's description, %q, doesn't say that finesse is tracked", name, description)
+		}
+		game := NewGame(3, mode)
+		for _, event := range []GameEvent{MoveLeft, MoveRight, QuickDrop} {
+			game.Handle(event)
+		}
+		if game.finesseFaults != 1 || game.extraInputs != 2 {
+			t.Errorf("in %s mode, moving left and right again made %d faults with %d extra inputs; want 1, 2",
+				name, game.finesseFaults, game.extraInputs)
+		}
+		if status := game.finesseStatus(); len(status) != 1 || status[0] != "Faults 1 (+2)" {
+			t.Errorf("in %s mode, the finesse status is %q", name, status)
+		}
+		game.Advance(1000)
+		// Rotating all the way around is four extra inputs.
+		for _, event := range []GameEvent{Rotate, Rotate, Rotate, Rotate, QuickDrop} {
+			game.Handle(event)
+		}
+		if game.finesseFaults != 2 || game.extraInputs != 6 {
+			t.Errorf("in %s mode, rotating a piece all the way around made %d faults with %d extra inputs; "+
+				"want 2, 6", name, game.finesseFaults, game.extraInputs)
+		}
+	}
+}
+
+func TestFinesseBot(t *testing.T) {
+	mode, err := NewMode("sprint", ModeOptions{Finesse: FinesseTrack})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(2, mode)
+	// The bot moves each piece the shortest way to where it's going (moving down is free).
+	botPlay(game, 200000, 50)
+	if game.piecesPlaced == 0 || game.finesseFaults != 0 {
+		t.Errorf("the bot made %d finesse faults in %d pieces", game.finesseFaults, game.piecesPlaced)
+	}
+}
+
+func TestStrictFinesse(t *testing.T) {
+	mode, err := NewMode("sprint", ModeOptions{Finesse: FinesseStrict})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(5, mode)
+	for _, event := range []GameEvent{MoveLeft, MoveRight, QuickDrop} {
+		game.Handle(event)
+	}
+	game.Advance(1)
+	if !game.Over() || !game.finesseFailed {
+		t.Fatalf("a finesse fault with strict finesse didn't end the game")
+	}
+	replayed, err := game.Replay().Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !replayed.Over() || !replayed.finesseFailed {
+		t.Errorf("the replay of a game which failed on finesse didn't fail")
+	}
+}
+
+func TestFinesseTrackingNames(t *testing.T) {
+	for tracking := range finesseTrackingNames {
+		parsed, err := ParseFinesseTracking(tracking.String())
+		if err != nil || parsed != tracking {
+			t.Errorf("ParseFinesseTracking(%q) = %v, %v", tracking.String(), parsed, err)
+		}
+	}
+	if _, err := ParseFinesseTracking("lenient"); err == nil {
+		t.Errorf("ParseFinesseTracking accepted an unknown name")
+	}
+}
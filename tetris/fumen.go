@@ -0,0 +1,628 @@
+package tetris
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Fumen is the format the tetris community uses to share board setups, as a string like "v115@vhAAgH" (see
+// https://harddrop.com/fumen/). A fumen holds a sequence of pages, each with a 10-wide field of blocks, an
+// optional piece placed on it, and an optional comment. Only version 115 of the format is supported.
+
+const (
+	// The version that starts fumen data, after a letter for how it's shown: "v" (the usual one), "m", or
+	// "d".
+	fumenPrefix   = "115@"
+	fumenVariants = "vmd"
+	// The characters used to encode numbers in base 64.
+	fumenTable = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+	// The characters allowed in (escaped) comments, which are encoded in base 96.
+	fumenCommentTable = " !\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`" +
+		"abcdefghijklmnopqrstuvwxyz{|}~"
+	fumenWidth = 10
+	// The number of rows in a fumen field (plus one more row of garbage below it).
+	fumenFieldTop  = 23
+	fumenFieldSize = (fumenFieldTop + 1) * fumenWidth
+)
+
+// The block values used in fumen fields, by piece name. 8 is a gray (garbage) block.
+var fumenBlocks = map[string]int{"I": 1, "L": 2, "O": 3, "Z": 4, "T": 5, "J": 6, "S": 7}
+
+const fumenGray = 8
+
+// The shape of each piece in its spawn rotation, relative to the point fumen uses as its position (with y
+// increasing upwards).
+var fumenShapes = map[int][]Vector{
+	1: {{0, 0}, {-1, 0}, {1, 0}, {2, 0}},
+	2: {{0, 0}, {-1, 0}, {1, 0}, {1, 1}},
+	3: {{0, 0}, {1, 0}, {0, 1}, {1, 1}},
+	4: {{0, 0}, {1, 0}, {0, 1}, {-1, 1}},
+	5: {{0, 0}, {-1, 0}, {1, 0}, {0, 1}},
+	6: {{0, 0}, {-1, 0}, {1, 0}, {-1, 1}},
+	7: {{0, 0}, {-1, 0}, {0, 1}, {1, 1}},
+}
+
+// Fumen rotations.
+const (
+	fumenReverse = iota
+	fumenRight
+	fumenSpawn
+	fumenLeft
+)
+
+// A fumenField holds the block value of every cell, starting with the top row. The last row is the garbage
+// row below the field.
+type fumenField [fumenFieldSize]int
+
+// Find the index of the fumen cell at (x, y), where y = 0 is the bottom row of the field and y = -1 is the
+// garbage row.
+func fumenIndex(x, y int) int {
+	return (fumenFieldTop-1-y)*fumenWidth + x
+}
+
+// A fumenPiece is a piece placed on a fumen page. A kind of 0 means there's no piece.
+type fumenPiece struct {
+	kind, rotation, x, y int
+}
+
+// The cells covered by the piece, in fumen coordinates.
+func (piece fumenPiece) blocks() []Vector {
+	var blocks []Vector
+	for _, point := range fumenShapes[piece.kind] {
+		switch piece.rotation {
+		case fumenRight:
+			point = Vector{point.y, -point.x}
+		case fumenReverse:
+			point = Vector{-point.x, -point.y}
+		case fumenLeft:
+			point = Vector{-point.y, point.x}
+		}
+		blocks = append(blocks, point.plus(Vector{piece.x, piece.y}))
+	}
+	return blocks
+}
+
+// Older versions of fumen used a different position for some pieces in some rotations, and the encoded
+// coordinates still use those. This returns the difference between the encoded and the actual position.
+func (piece fumenPiece) coordinateAdjustment() Vector {
+	switch {
+	case piece.kind == 3 && piece.rotation == fumenLeft:
+		return Vector{-1, 1}
+	case piece.kind == 3 && piece.rotation == fumenReverse:
+		return Vector{-1, 0}
+	case piece.kind == 3 && piece.rotation == fumenSpawn:
+		return Vector{0, 1}
+	case piece.kind == 1 && piece.rotation == fumenReverse:
+		return Vector{-1, 0}
+	case piece.kind == 1 && piece.rotation == fumenLeft:
+		return Vector{0, 1}
+	case piece.kind == 7 && piece.rotation == fumenSpawn:
+		return Vector{0, 1}
+	case piece.kind == 7 && piece.rotation == fumenRight:
+		return Vector{1, 0}
+	case piece.kind == 4 && piece.rotation == fumenSpawn:
+		return Vector{0, 1}
+	case piece.kind == 4 && piece.rotation == fumenLeft:
+		return Vector{-1, 0}
+	}
+	return Vector{0, 0}
+}
+
+// A single page of a fumen.
+type fumenPage struct {
+	field   fumenField
+	piece   fumenPiece
+	comment string
+	// Whether the piece is locked into the field (and lines cleared) before the next page.
+	lock bool
+	// Whether the garbage row rises into the field before the next page.
+	rise bool
+	// Whether the field is mirrored before the next page.
+	mirror bool
+}
+
+// Find the field that the next page is based on: this page's field after the piece is locked in, lines are
+// cleared, and any rise or mirror has been applied.
+func (page *fumenPage) nextField() fumenField {
+	field := page.field
+	if page.lock && page.piece.kind != 0 {
+		for _, block := range page.piece.blocks() {
+			if block.x >= 0 && block.x < fumenWidth && block.y >= 0 && block.y < fumenFieldTop {
+				field[fumenIndex(block.x, block.y)] = page.piece.kind
+			}
+		}
+		// Clear full lines, moving everything above them down.
+		var cleared fumenField
+		copy(cleared[fumenIndex(0, -1):], field[fumenIndex(0, -1):])
+		y := 0
+		for row := 0; row < fumenFieldTop; row++ {
+			full := true
+			for x := 0; x < fumenWidth; x++ {
+				if field[fumenIndex(x, row)] == 0 {
+					full = false
+				}
+			}
+			if !full {
+				copy(cleared[fumenIndex(0, y):fumenIndex(0, y)+fumenWidth], field[fumenIndex(0, row):])
+				y++
+			}
+		}
+		field = cleared
+	}
+	if page.rise {
+		var risen fumenField
+		copy(risen[:], field[fumenWidth:])
+		field = risen
+	}
+	if page.mirror {
+		for y := 0; y < fumenFieldTop; y++ {
+			for x := 0; x < fumenWidth/2; x++ {
+				i, j := fumenIndex(x, y), fumenIndex(fumenWidth-1-x, y)
+				field[i], field[j] = field[j], field[i]
+			}
+		}
+	}
+	return field
+}
+
+// A fumenReader reads base 64 numbers from fumen data.
+type fumenReader struct {
+	data string
+}
+
+// Read a number encoded in the next n characters (least significant first).
+func (r *fumenReader) poll(n int) (int, error) {
+	if len(r.data) < n {
+		return 0, fmt.Errorf("fumen data ends unexpectedly")
+	}
+	value := 0
+	for i := n - 1; i >= 0; i-- {
+		digit := strings.IndexByte(fumenTable, r.data[i])
+		if digit < 0 {
+			return 0, fmt.Errorf("invalid character %q in fumen data", r.data[i])
+		}
+		value = value*64 + digit
+	}
+	r.data = r.data[n:]
+	return value, nil
+}
+
+// Decode fumen data into its pages. The data may be a URL containing the fumen (after the "?").
+func decodeFumen(data string) ([]fumenPage, error) {
+	start := strings.Index(data, fumenPrefix)
+	if start < 1 || !strings.ContainsRune(fumenVariants, rune(data[start-1])) ||
+		start > 1 && !strings.HasSuffix(data[:start-1], "?") {
+		return nil, fmt.Errorf("not fumen v115 data")
+	}
+	r := &fumenReader{strings.Replace(data[start+len(fumenPrefix):], "?", "", -1)}
+	if i := strings.IndexAny(r.data, "&# "); i >= 0 {
+		r.data = r.data[:i]
+	}
+
+	var pages []fumenPage
+	var previous fumenField
+	comment := ""
+	repeat := 0
+	for len(r.data) > 0 {
+		page := fumenPage{field: previous}
+		if repeat > 0 {
+			repeat--
+		} else {
+			for i := 0; i < fumenFieldSize; {
+				value, err := r.poll(2)
+				if err != nil {
+					return nil, err
+				}
+				diff, run := value/fumenFieldSize-8, value%fumenFieldSize+1
+				if diff == 0 && run == fumenFieldSize {
+					// The field is unchanged. This is followed by the number of following pages which are
+					// also unchanged (and don't include a field).
+					if repeat, err = r.poll(1); err != nil {
+						return nil, err
+					}
+				}
+				if i+run > fumenFieldSize {
+					return nil, fmt.Errorf("invalid fumen field")
+				}
+				for ; run > 0; run-- {
+					page.field[i] += diff
+					if page.field[i] < 0 || page.field[i] > fumenGray {
+						return nil, fmt.Errorf("invalid fumen field")
+					}
+					i++
+				}
+			}
+		}
+
+		value, err := r.poll(3)
+		if err != nil {
+			return nil, err
+		}
+		page.piece.kind = value % 8
+		page.piece.rotation = value / 8 % 4
+		coordinate := value / 32 % fumenFieldSize
+		page.rise = value/7680%2 == 1
+		page.mirror = value/15360%2 == 1
+		hasComment := value/61440%2 == 1
+		page.lock = value/122880%2 == 0
+		if page.piece.kind != 0 {
+			position := Vector{coordinate % fumenWidth, fumenFieldTop - coordinate/fumenWidth - 1}
+			position = position.plus(page.piece.coordinateAdjustment().times(-1))
+			page.piece.x, page.piece.y = position.x, position.y
+		}
+
+		if hasComment {
+			if comment, err = r.readComment(); err != nil {
+				return nil, err
+			}
+		}
+		page.comment = comment
+		pages = append(pages, page)
+		previous = page.nextField()
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("fumen data has no pages")
+	}
+	return pages, nil
+}
+
+// Read a comment: its length and then its characters, four at a time.
+func (r *fumenReader) readComment() (string, error) {
+	length, err := r.poll(2)
+	if err != nil {
+		return "", err
+	}
+	var escaped []byte
+	for len(escaped) < length {
+		value, err := r.poll(5)
+		if err != nil {
+			return "", err
+		}
+		for i := 0; i < 4 && len(escaped) < length; i++ {
+			if value%96 >= len(fumenCommentTable) {
+				return "", fmt.Errorf("invalid character in fumen comment")
+			}
+			escaped = append(escaped, fumenCommentTable[value%96])
+			value /= 96
+		}
+	}
+	return fumenUnescape(string(escaped)), nil
+}
+
+// Undo JavaScript's escape(), which writes characters as %XX (for character codes below 256) or %uXXXX.
+func fumenUnescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' {
+			hex, digits := s[i+1:], 2
+			if strings.HasPrefix(hex, "u") {
+				hex, digits = hex[1:], 4
+			}
+			if len(hex) >= digits {
+				if code, err := strconv.ParseUint(hex[:digits], 16, 32); err == nil {
+					b.WriteRune(rune(code))
+					i = len(s) - len(hex) + digits - 1
+					continue
+				}
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Escape a comment the way JavaScript's escape() does.
+func fumenEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9',
+			strings.ContainsRune("@*_+-./", r):
+			b.WriteRune(r)
+		case r < 0x100:
+			fmt.Fprintf(&b, "%%%02X", r)
+		default:
+			fmt.Fprintf(&b, "%%u%04X", r)
+		}
+	}
+	return b.String()
+}
+
+// A fumenWriter builds up fumen data.
+type fumenWriter struct {
+	data []byte
+}
+
+// Write a number as n characters (least significant first).
+func (w *fumenWriter) push(value, n int) {
+	for i := 0; i < n; i++ {
+		w.data = append(w.data, fumenTable[value%64])
+		value /= 64
+	}
+}
+
+// Encode pages as fumen data (including the "v115@" prefix).
+func encodeFumen(pages []fumenPage) string {
+	w := new(fumenWriter)
+	var previous fumenField
+	comment := ""
+	repeat := 0
+	for i := range pages {
+		page := &pages[i]
+		if repeat > 0 {
+			repeat--
+		} else {
+			// Run-length encode the differences from the previous field.
+			for start := 0; start < fumenFieldSize; {
+				diff := page.field[start] - previous[start]
+				end := start + 1
+				for end < fumenFieldSize && page.field[end]-previous[end] == diff {
+					end++
+				}
+				w.push((diff+8)*fumenFieldSize+end-start-1, 2)
+				if diff == 0 && end-start == fumenFieldSize {
+					// Count the following pages that have no changes either.
+					field := page.nextField()
+					for j := i + 1; j < len(pages) && repeat < 63 && pages[j].field == field; j++ {
+						field = pages[j].nextField()
+						repeat++
+					}
+					w.push(repeat, 1)
+				}
+				start = end
+			}
+		}
+
+		value := 0
+		if page.piece.kind != 0 {
+			position := Vector{page.piece.x, page.piece.y}.plus(page.piece.coordinateAdjustment())
+			coordinate := (fumenFieldTop-position.y-1)*fumenWidth + position.x
+			value = page.piece.kind + page.piece.rotation*8 + coordinate*32
+		}
+		if page.rise {
+			value += 7680
+		}
+		if page.mirror {
+			value += 15360
+		}
+		// The "colorize" flag (guideline piece colors) is only meaningful on the first page.
+		if i == 0 {
+			value += 30720
+		}
+		hasComment := page.comment != comment
+		if hasComment {
+			value += 61440
+		}
+		if !page.lock {
+			value += 122880
+		}
+		w.push(value, 3)
+
+		if hasComment {
+			escaped := fumenEscape(page.comment)
+			if len(escaped) > 4095 {
+				escaped = escaped[:4095]
+			}
+			w.push(len(escaped), 2)
+			for start := 0; start < len(escaped); start += 4 {
+				value, scale := 0, 1
+				for j := start; j < start+4 && j < len(escaped); j++ {
+					value += strings.IndexByte(fumenCommentTable, escaped[j]) * scale
+					scale *= 96
+				}
+				w.push(value, 5)
+			}
+			comment = page.comment
+		}
+		previous = page.nextField()
+	}
+
+	// The data is split up with '?' every 47 characters (including the prefix).
+	data := "v" + fumenPrefix + string(w.data)
+	var b strings.Builder
+	for len(data) > 47 {
+		b.WriteString(data[:47])
+		b.WriteByte('?')
+		data = data[47:]
+	}
+	b.WriteString(data)
+	return b.String()
+}
+
+// Find the fumen page showing the board (with its current piece).
+func (board *Board) fumenPage(pieces []Piece) fumenPage {
+	page := fumenPage{lock: true}
+	kinds := make(map[Color]int)
+	for _, piece := range pieces {
+		kinds[piece.color] = fumenBlocks[piece.name]
+	}
+	for point, color := range board.cells {
+		kind, ok := kinds[color]
+		if !ok {
+			kind = fumenGray
+		}
+		page.field[fumenIndex(point.x, height-1-point.y)] = kind
+	}
+	if board.currentPiece != nil {
+		page.piece = board.currentFumenPiece()
+	}
+	return page
+}
+
+// Find the fumen piece that covers the same cells as the current piece.
+func (board *Board) currentFumenPiece() fumenPiece {
+	var target []Vector
+	for _, point := range board.currentPiece.instance() {
+		point = point.plus(board.currentPosition)
+		target = append(target, Vector{point.x, height - 1 - point.y})
+	}
+	kind := fumenBlocks[board.currentPiece.name]
+	// Some pieces look the same in more than one rotation; prefer the spawn rotation in that case.
+	for _, rotation := range []int{fumenSpawn, fumenRight, fumenReverse, fumenLeft} {
+		piece := fumenPiece{kind: kind, rotation: rotation}
+		if offset, ok := shapeOffset(piece.blocks(), target); ok {
+			piece.x, piece.y = offset.x, offset.y
+			return piece
+		}
+	}
+	// The pieces always have a matching fumen rotation, so this shouldn't be reached.
+	return fumenPiece{}
+}
+
+// If shape can be translated to cover exactly the same cells as target, return the translation.
+func shapeOffset(shape, target []Vector) (Vector, bool) {
+	if len(shape) != len(target) {
+		return Vector{}, false
+	}
+	offset := minPoint(target).plus(minPoint(shape).times(-1))
+	cells := make(map[Vector]bool)
+	for _, point := range target {
+		cells[point] = true
+	}
+	for _, point := range shape {
+		if !cells[point.plus(offset)] {
+			return Vector{}, false
+		}
+	}
+	return offset, true
+}
+
+// Find the smallest x and y values in a set of points.
+func minPoint(points []Vector) Vector {
+	min := points[0]
+	for _, point := range points[1:] {
+		if point.x < min.x {
+			min.x = point.x
+		}
+		if point.y < min.y {
+			min.y = point.y
+		}
+	}
+	return min
+}
+
+// Convert a fumen field into rows in the format of a puzzle's board (see ReadPuzzlePack), from its highest
+// non-empty row down to the bottom. Blocks which aren't any piece's are garbage.
+func (field *fumenField) puzzleRows() []string {
+	var rows []string
+	for y := 0; y < fumenFieldTop; y++ {
+		row := []byte(strings.Repeat(".", fumenWidth))
+		for x := 0; x < fumenWidth; x++ {
+			kind := field[fumenIndex(x, y)]
+			if kind == 0 {
+				continue
+			}
+			row[x] = '#'
+			for name, block := range fumenBlocks {
+				if block == kind {
+					row[x] = name[0]
+				}
+			}
+		}
+		rows = append([]string{string(row)}, rows...)
+	}
+	for len(rows) > 0 && !strings.ContainsAny(rows[0], puzzleCells[1:]) {
+		rows = rows[1:]
+	}
+	return rows
+}
+
+// Set up the board from a fumen page: the blocks of its field, and its piece as the current piece (if the
+// page has one). Returns an error if the page doesn't fit on the board.
+func (board *Board) loadFumenPage(page fumenPage, pieces []Piece) error {
+	colors := make(map[int]Color)
+	for _, piece := range pieces {
+		colors[fumenBlocks[piece.name]] = piece.color
+	}
+	cells := make(ColorMap)
+	for y := 0; y < fumenFieldTop; y++ {
+		for x := 0; x < fumenWidth; x++ {
+			kind := page.field[fumenIndex(x, y)]
+			if kind == 0 {
+				continue
+			}
+			if y >= height {
+				return fmt.Errorf("the fumen field is taller than the board (%d rows)", height)
+			}
+			color, ok := colors[kind]
+			if !ok {
+				color = garbageColor
+			}
+			cells[Vector{x, height - 1 - y}] = color
+		}
+	}
+	board.cells = cells
+	board.lockTimes = make(map[Vector]int)
+	if page.piece.kind == 0 {
+		return nil
+	}
+
+	var target []Vector
+	for _, block := range page.piece.blocks() {
+		target = append(target, Vector{block.x, height - 1 - block.y})
+	}
+	for i := range pieces {
+		piece := &pieces[i]
+		if fumenBlocks[piece.name] != page.piece.kind {
+			continue
+		}
+		for rotation, instance := range piece.rotations {
+			if offset, ok := shapeOffset(instance, target); ok {
+				piece.currentRotation = rotation
+				board.currentPiece = piece
+				board.currentPosition = offset
+				if board.currentPieceInCollision() {
+					return fmt.Errorf("the fumen piece doesn't fit on the board")
+				}
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("the fumen piece has no matching rotation")
+}
+
+// Set up the game from the first page of a fumen: its field, its piece (which becomes the current piece), and
+// its comment. This must be called before any inputs are given to the game. The fumen is recorded in the
+// replay so that the game can be replayed from the same starting position.
+func (game *Game) LoadFumen(data string) error {
+	pages, err := decodeFumen(data)
+	if err != nil {
+		return err
+	}
+	if err := game.board.loadFumenPage(pages[0], game.pieces); err != nil {
+		return err
+	}
+	game.message = pages[0].comment
+	game.replay.Fumen = data
+	game.startPieceInputs()
+	game.history = nil
+	game.saveSnapshot()
+	return nil
+}
+
+// Encode the board (and its current piece) as a single page of fumen data.
+func (game *Game) Fumen() string {
+	page := game.board.fumenPage(game.pieces)
+	page.comment = game.message
+	return encodeFumen([]fumenPage{page})
+}
+
+// Encode a replay as fumen data, with a page for every piece placed during the game showing where it went.
+func (replay *Replay) EncodeFumen() (string, error) {
+	player, err := NewReplayPlayer(replay)
+	if err != nil {
+		return "", err
+	}
+	var pages []fumenPage
+	player.game.onAnchor = func() {
+		pages = append(pages, player.game.board.fumenPage(player.game.pieces))
+	}
+	if err := player.AdvanceTo(replay.Time); err != nil {
+		return "", err
+	}
+	if len(pages) == 0 {
+		pages = append(pages, player.game.board.fumenPage(player.game.pieces))
+	}
+	return encodeFumen(pages), nil
+}
@@ -0,0 +1,132 @@
+package tetris
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A fumen with garbage across the bottom row apart from its right column, a T piece in its spawn rotation
+// above it, and the comment "Hi".
+const fumenGarbageT = "v115@bhI8KeVLYCAIuBAA"
+
+func TestDecodeFumen(t *testing.T) {
+	for _, data := range []string{fumenGarbageT, "https://harddrop.com/fumen/?v115@bhI8KeVLYCAIuBAA",
+		"m115@bhI8KeVLYCAIuBAA", "d115@bhI8KeVLYCAIuBAA"} {
+		pages, err := decodeFumen(data)
+		if err != nil {
+			t.Fatalf("decoding %q: %s", data, err)
+		}
+		if len(pages) != 1 {
+			t.Fatalf("%q has %d pages; want 1", data, len(pages))
+		}
+		page := pages[0]
+		var field fumenField
+		for x := 0; x < 9; x++ {
+			field[fumenIndex(x, 0)] = fumenGray
+		}
+		if page.field != field {
+			t.Errorf("%q has the field\n%s\nwant\n%s", data, strings.Join(page.field.puzzleRows(), "\n"),
+				strings.Join(field.puzzleRows(), "\n"))
+		}
+		if want := (fumenPiece{fumenBlocks["T"], fumenSpawn, 4, 1}); page.piece != want {
+			t.Errorf("%q has the piece %+v; want %+v", data, page.piece, want)
+		}
+		if page.comment != "Hi" || !page.lock || page.rise || page.mirror {
+			t.Errorf("%q has the comment %q and lock %t, rise %t, mirror %t; want \"Hi\", locking", data,
+				page.comment, page.lock, page.rise, page.mirror)
+		}
+	}
+
+	pages, err := decodeFumen("v115@vhAAgH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 || pages[0] != (fumenPage{lock: true}) {
+		t.Errorf("the empty fumen decoded as %+v; want one empty page", pages)
+	}
+}
+
+func TestEncodeFumen(t *testing.T) {
+	var field fumenField
+	for x := 0; x < fumenWidth; x++ {
+		field[fumenIndex(x, 0)] = fumenBlocks["I"]
+		if x != 3 {
+			field[fumenIndex(x, 1)] = fumenGray
+		}
+		field[fumenIndex(x, -1)] = fumenGray
+	}
+	field[fumenIndex(0, 2)] = fumenBlocks["L"]
+	pages := []fumenPage{
+		{field: field, piece: fumenPiece{fumenBlocks["I"], fumenLeft, 3, 2}, comment: "Tetris?", lock: true},
+		// The same field as the page before (once its piece has locked and its lines are cleared).
+		{piece: fumenPiece{fumenBlocks["O"], fumenSpawn, 4, 5}, comment: "Tetris?", lock: true},
+		{piece: fumenPiece{fumenBlocks["S"], fumenRight, 8, 1}, comment: "100% ✓ très bien", rise: true},
+		{comment: "", mirror: true, lock: true},
+		{piece: fumenPiece{fumenBlocks["Z"], fumenReverse, 1, 0}, lock: true},
+	}
+	for i := 1; i < len(pages); i++ {
+		pages[i].field = pages[i-1].nextField()
+	}
+	if pages[1].field == pages[0].field {
+		t.Fatalf("the I piece didn't clear any lines")
+	}
+	pages[4].field[fumenIndex(9, 0)] = fumenBlocks["J"]
+
+	data := encodeFumen(pages)
+	decoded, err := decodeFumen(data)
+	if err != nil {
+		t.Fatalf("decoding %q: %s", data, err)
+	}
+	if !reflect.DeepEqual(decoded, pages) {
+		t.Errorf("%q decoded as\n%+v\nwant\n%+v", data, decoded, pages)
+	}
+
+	if data := encodeFumen(pages[:1]); !strings.HasPrefix(data, "v115@") {
+		t.Errorf("the fumen %q doesn't start with v115@", data)
+	}
+	mode, err := NewMode("sprint", ModeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(1, mode)
+	if err := game.LoadFumen(fumenGarbageT); err != nil {
+		t.Fatal(err)
+	}
+	if data := game.Fumen(); data != fumenGarbageT {
+		t.Errorf("the game loaded from %q encodes as %q", fumenGarbageT, data)
+	}
+}
+
+func TestDecodeFumenErrors(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"115@vhAAgH",
+		"x115@vhAAgH",
+		"v114@vhAAgH",
+		"see v115@vhAAgH",
+		"v115@",
+		"v115@vh",
+		"v115@vhAAg",
+		"v115@vh!AgH",
+		// A comment which is cut short, and one with a character beyond the end of the table.
+		"v115@vhAAgWFAIuBAA",
+		"v115@vhAAgWBAfBAAA",
+		"v115@vhGRQYHAvHsQYJ4AvKsQ4GPDvEMQ8AvG4AvHqvKyAvC0AvF4AvI4Bv",
+		// A run of blocks past the end of the field.
+		"v115@bhv/AgH",
+	} {
+		if pages, err := decodeFumen(data); err == nil {
+			t.Errorf("%q decoded as %+v; want an error", data, pages)
+		}
+	}
+
+	// A piece that doesn't fit on the board.
+	mode, err := NewMode("sprint", ModeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewGame(1, mode).LoadFumen("v115@vhA/gH"); err == nil {
+		t.Errorf("a fumen with its piece out of the board was loaded")
+	}
+}
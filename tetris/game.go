@@ -1,12 +1,9 @@
-// This is a simple implementation of a console-based Tetris clone. It only works where termbox-go works
-// (linux/mac should be fine).
+// This is a simple implementation of a console-based Tetris clone. Games are played in a terminal (anywhere
+// that termbox-go works, for local games), and can also be played in a browser.
 package tetris
 
 import (
-	"github.com/nsf/termbox-go"
 	"math"
-	"math/rand"
-	"time"
 )
 
 type Direction int
@@ -20,21 +17,91 @@ const (
 
 // A Game tracks the entire game state of tetris, including the Board, the upcoming piece, the game speed
 // (dropDelayMillis), the score, and various other internal data.
+//
+// A Game doesn't do any drawing or read any input itself, and it doesn't look at the wall clock: time only
+// passes when Advance is called. This means that a game is completely determined by its seed and the inputs
+// given to it (and when they were given), which is what makes replays possible.
 type Game struct {
 	board           *Board
 	nextPiece       *Piece
 	pieces          []Piece
+	rng             *Random
 	paused          bool
 	over            bool
 	dropDelayMillis int
-	ticker          *time.Ticker
 	score           int
+	lines           int
+	piecesPlaced    int
+	// The number of cleared lines which had garbage in them.
+	garbageCleared int
+	// The game clock, in milliseconds of unpaused play.
+	clock int
+	// The clock time at which gravity will next move the current piece down.
+	nextDrop int
+	// The rows which are being cleared (flashing on screen) and the clock time at which that started. There is
+	// no current piece while rows are being cleared.
+	clearingRows []int
+	clearStart   int
+	// The number of times in a row that removing cleared rows has completed more rows (see finishClear).
+	chain  int
+	replay *Replay
+	// A line of text shown below the controls, such as the comment of the fumen the game was set up from.
+	message string
+	// If set, this is called whenever a piece is about to be anchored to the board.
+	onAnchor func()
+	mode     Mode
+	// The clock time of the first input, or -1 if there hasn't been one yet.
+	firstInput int
+	// Whether the game ended because the mode's goal was reached (rather than by topping out).
+	finished bool
+	ruleset  *Ruleset
+	// The current level, for modes which have levels (see Ruleset.gravity).
+	level int
+	// The clock time at which the current piece came to rest on the stack, or -1 if it isn't resting. It locks
+	// when the ruleset's lock delay has passed.
+	lockStart int
+	// How many times the lock delay has been restarted for the current piece.
+	lockResets int
+	// The clock time at which the next piece will appear, or -1 if the game isn't waiting for one (see
+	// Timings.Entry).
+	spawnAt int
+	// The names of the pieces still to come, for modes with a fixed sequence of pieces (see GeneratePiece).
+	// It's nil if pieces are chosen at random.
+	queue []string
+	// Whether the current piece's last successful move was a rotation, and whether the last piece to lock was
+	// a T-spin (see isTSpin).
+	rotated bool
+	tSpin   bool
+	// Finesse tracking (see ModeOptions.Finesse): where the current piece started and the inputs that have
+	// moved or rotated it since, the number of pieces placed with extra inputs and the total number of extra
+	// inputs, the extra inputs for the last piece placed, and whether a fault has ended the game.
+	pieceStart      pieceState
+	pieceInputs     []GameEvent
+	finesseFaults   int
+	extraInputs     int
+	lastExtraInputs int
+	finesseFailed   bool
+	// For modes which let the player undo (see rewinder): snapshots from when each piece came in, up to the
+	// current one, the snapshots for placements which have been undone (the last one undone is at the end),
+	// and the number of undos.
+	history []*snapshot
+	future  []*snapshot
+	undos   int
 }
 
-// Initialize a new game, ready to be started with Start().
-func NewGame() *Game {
+// Initialize a new game, ready to be started with Start(). The seed determines the sequence of pieces, and
+// the mode determines the rules on top of the basic game (see Mode).
+func NewGame(seed int64, mode Mode) *Game {
 	game := new(Game)
 	game.pieces = tetrisPieces()
+	game.rng = NewRandom(seed)
+	game.replay = &Replay{Seed: seed, Mode: describeMode(mode)}
+	game.mode = mode
+	game.firstInput = -1
+	game.ruleset = classicRuleset
+	game.level = 1
+	game.lockStart = -1
+	game.spawnAt = -1
 	game.board = newBoard()
 	game.board.currentPiece = game.GeneratePiece()
 	game.board.currentPosition = game.board.currentPiece.initialLocation
@@ -42,23 +109,49 @@ func NewGame() *Game {
 	game.paused = false
 	game.over = false
 	game.score = 0
-	game.startTicker()
+	game.updateSpeed()
+	game.nextDrop = game.dropDelayMillis
+	mode.Setup(game)
+	game.startPieceInputs()
+	game.saveSnapshot()
 	return game
 }
 
-// Start up the ticker with the appropriate interval based on the current score.
-func (game *Game) startTicker() {
+// Switch to another ruleset. The current and next pieces are replaced by the same pieces from the ruleset's
+// rotation system, and the speed is updated. This is meant to be called from Mode.Setup.
+func (game *Game) setRuleset(ruleset *Ruleset) {
+	game.ruleset = ruleset
+	game.pieces = ruleset.rotation.pieces()
+	if game.board.currentPiece != nil {
+		game.board.currentPiece = game.pieceNamed(game.board.currentPiece.name)
+		game.board.currentPosition = game.board.currentPiece.initialLocation
+	}
+	game.nextPiece = game.pieceNamed(game.nextPiece.name)
+	game.updateSpeed()
+	game.nextDrop = game.clock + game.dropDelayMillis
+}
+
+// The ruleset's timings at the current level.
+func (game *Game) timings() Timings {
+	return game.ruleset.timings(game.level)
+}
+
+// Modes which implement speedSetter choose the drop delay themselves, instead of it depending on the score.
+type speedSetter interface {
+	dropDelay(game *Game) int
+}
+
+// Set the drop delay (the time between automatic moves down) appropriately for the current score.
+func (game *Game) updateSpeed() {
+	if setter, ok := game.mode.(speedSetter); ok {
+		game.dropDelayMillis = setter.dropDelay(game)
+		return
+	}
 	// Set the speed as a function of score. Starts at 800ms, decreases to 200ms by 100ms each 500 points.
 	game.dropDelayMillis = 800 - game.score/5
 	if game.dropDelayMillis < 200 {
 		game.dropDelayMillis = 200
 	}
-	game.ticker = time.NewTicker(time.Duration(game.dropDelayMillis) * time.Millisecond)
-}
-
-// Stop the game ticker. This stops automatic advancement of the piece.
-func (game *Game) stopTicker() {
-	game.ticker.Stop()
 }
 
 // A game event, generated by user input or by the game ticker.
@@ -74,178 +167,307 @@ const (
 	Quit
 	// An event that doesn't cause a change to game state but causes a full redraw; e.g., a window resize.
 	Redraw
+	// Save the board as fumen data.
+	SaveFumen
+	// Start the game over from the beginning, in modes which allow it (see practicer).
+	Retry
+	// Undo the last piece placed, or redo the last one undone (in modes which allow it; see rewinder).
+	Undo
+	Redo
+	// Events for a player in a match with more than two players (see Match): cycle through the strategies for
+	// choosing who to send garbage to, or leave the match.
+	Target
+	Forfeit
 )
 
-// Start running the game. It will continue indefinitely until the user exits.
-func (game *Game) Start() {
+var gameEventNames = map[GameEvent]string{
+	MoveLeft:  "left",
+	MoveRight: "right",
+	MoveDown:  "down",
+	Rotate:    "rotate",
+	QuickDrop: "drop",
+	Pause:     "pause",
+	Quit:      "quit",
+	Redraw:    "redraw",
+	SaveFumen: "savefumen",
+	Retry:     "retry",
+	Undo:      "undo",
+	Redo:      "redo",
+	Target:    "target",
+	Forfeit:   "forfeit",
+}
 
-	drawStaticBoardParts()
-	game.DrawDynamic(false)
+func (event GameEvent) String() string {
+	if name, ok := gameEventNames[event]; ok {
+		return name
+	}
+	return "unknown"
+}
 
-	eventQueue := make(chan GameEvent, 100)
-	go func() {
-		for {
-			eventQueue <- waitForUserEvent()
+// Find the event with the given name (as returned by GameEvent.String).
+func ParseGameEvent(name string) (GameEvent, bool) {
+	for event, eventName := range gameEventNames {
+		if eventName == name {
+			return event, true
 		}
-	}()
-gameOver:
-	for {
-		var event GameEvent
-		select {
-		case event = <-eventQueue:
-		case <-game.ticker.C:
-			event = MoveDown
+	}
+	return 0, false
+}
+
+// Whether an event changes the state of the game (as opposed to only affecting the interface around it).
+// These are the events that are recorded in replays.
+func (event GameEvent) IsGameplay() bool {
+	switch event {
+	case MoveLeft, MoveRight, MoveDown, Rotate, QuickDrop, Undo, Redo:
+		return true
+	}
+	return false
+}
+
+// Advance the game clock by some number of milliseconds, applying gravity and finishing line clears as their
+// times come up. The clock doesn't move while the game is paused or after it's over.
+func (game *Game) Advance(millis int) {
+	for i := 0; i < millis && !game.paused && !game.over; i++ {
+		game.clock++
+		game.step()
+		game.mode.Update(game)
+	}
+}
+
+// Perform anything that is scheduled to happen at the current clock time.
+func (game *Game) step() {
+	if game.clearingRows != nil {
+		if game.clock >= game.clearStart+game.timings().LineClear {
+			game.finishClear()
 		}
-		// If the game is paused, ignore all commands except for Pause, Quit, and Redraw. On Redraw, redraw
-		// the pause screen.
-		if game.paused {
-			switch event {
-			case Pause:
-				game.PauseToggle()
-			case Quit:
-				return
-			case Redraw:
-				drawStaticBoardParts()
-				game.DrawPauseScreen()
-			}
-		} else {
-			switch event {
-			case MoveLeft:
-				game.Move(Left)
-			case MoveRight:
-				game.Move(Right)
-			case MoveDown:
-				game.Move(Down)
-			case QuickDrop:
-				game.QuickDrop()
-			case Rotate:
-				game.Rotate()
-			case Pause:
-				game.PauseToggle()
-			case Quit:
-				return
-			case Redraw:
-				drawStaticBoardParts()
-			}
-			// Update screen only if game is not now paused.
-			if !game.paused {
-				game.DrawDynamic(false)
-			}
+		return
+	}
+	if game.spawnAt >= 0 {
+		if game.clock >= game.spawnAt {
+			game.spawnAt = -1
+			game.nextDrop = game.clock + game.dropDelayMillis
+			game.spawnNextPiece()
 		}
-		if game.over {
-			break gameOver
+		return
+	}
+	if game.clock >= game.nextDrop {
+		game.nextDrop = game.clock + game.dropDelayMillis
+		game.fall()
+	}
+	if game.timings().Lock > 0 && game.board.currentPiece != nil {
+		game.updateLock()
+	}
+}
+
+// Move the current piece down by gravity: one row, or all the way to the bottom at 20G. Without a lock delay,
+// a piece that can't move down locks straight away.
+func (game *Game) fall() {
+	if game.board.currentPiece == nil {
+		return
+	}
+	moved := game.board.moveIfPossible(Vector{0, 1})
+	for moved && game.dropDelayMillis == 0 && game.board.moveIfPossible(Vector{0, 1}) {
+	}
+	if !moved && game.timings().Lock == 0 {
+		game.anchor()
+	}
+}
+
+// Start the lock delay when the current piece comes to rest on the stack, and lock the piece when the delay
+// runs out. If the piece moves off the stack (for instance, by sliding off a ledge), the delay starts again
+// when it lands.
+func (game *Game) updateLock() {
+	if !game.board.currentPieceResting() {
+		game.lockStart = -1
+		return
+	}
+	if game.lockStart < 0 {
+		game.lockStart = game.clock
+	}
+	if game.clock >= game.lockStart+game.timings().Lock {
+		game.anchor()
+	}
+}
+
+// Restart the lock delay after a resting piece was moved or rotated, unless it has been restarted too many
+// times already.
+func (game *Game) resetLockDelay() {
+	if game.lockStart >= 0 && game.lockResets < game.ruleset.lockResets {
+		game.lockStart = game.clock
+		game.lockResets++
+	}
+}
+
+// Apply a gameplay event (see IsGameplay) at the current clock time and record it in the replay. Other events
+// are ignored, as are all events while the game is paused or over.
+func (game *Game) Handle(event GameEvent) {
+	if !event.IsGameplay() || game.paused || game.over {
+		return
+	}
+	game.replay.Inputs = append(game.replay.Inputs, ReplayInput{game.clock, event})
+	if game.firstInput < 0 {
+		game.firstInput = game.clock
+	}
+	// There's nothing to control while rows are being cleared or the next piece is on its way.
+	if game.board.currentPiece == nil {
+		return
+	}
+	if event.isFinesseInput() {
+		game.pieceInputs = append(game.pieceInputs, event)
+	}
+	switch event {
+	case MoveLeft:
+		game.Move(Left)
+	case MoveRight:
+		game.Move(Right)
+	case MoveDown:
+		game.Move(Down)
+	case QuickDrop:
+		game.QuickDrop()
+	case Rotate:
+		game.Rotate()
+	case Undo:
+		game.undo()
+	case Redo:
+		game.redo()
+	}
+	game.mode.Update(game)
+}
+
+// Randomly choose a new game piece from among the the available pieces, or take the next one from the queue
+// if the mode has set one up. Returns nil if the queue has run out.
+func (game *Game) GeneratePiece() *Piece {
+	if game.queue != nil {
+		if len(game.queue) == 0 {
+			return nil
 		}
+		name := game.queue[0]
+		game.queue = game.queue[1:]
+		return game.pieceNamed(name)
 	}
-	game.DrawGameOver()
-	for waitForUserEvent() != Quit {
-	}
-}
-
-// A blocking function that waits on a ticker and then emits a MoveDown event.
-func waitForTick(ticker *time.Ticker) GameEvent {
-	<-ticker.C
-	return MoveDown
-}
-
-// A blocking function that waits for user input and then emits the appropriate GameEvent.
-func waitForUserEvent() GameEvent {
-	switch event := termbox.PollEvent(); event.Type {
-	// Movement: arrow keys or vim controls (h, j, k, l)
-	// Pause: 'p'
-	// Exit: 'q' or ctrl-c.
-	case termbox.EventKey:
-		if event.Ch == 0 { // A special key combo was pressed
-			switch event.Key {
-			case termbox.KeyCtrlC:
-				return Quit
-			case termbox.KeyArrowLeft:
-				return MoveLeft
-			case termbox.KeyArrowUp:
-				return Rotate
-			case termbox.KeyArrowRight:
-				return MoveRight
-			case termbox.KeyArrowDown:
-				return MoveDown
-			case termbox.KeySpace:
-				return QuickDrop
-			}
-		} else {
-			switch event.Ch {
-			case 'p':
-				return Pause
-			case 'q':
-				return Quit
-			case 'h':
-				return MoveLeft
-			case 'k':
-				return Rotate
-			case 'l':
-				return MoveRight
-			case 'j':
-				return MoveDown
-			}
+	return &game.pieces[game.rng.Intn(len(game.pieces))]
+}
+
+// Find the available piece with the given name (see Piece.name), or nil if there isn't one.
+func (game *Game) pieceNamed(name string) *Piece {
+	for i := range game.pieces {
+		if game.pieces[i].name == name {
+			return &game.pieces[i]
 		}
-	case termbox.EventResize:
-		return Redraw
-	case termbox.EventError:
-		panic(event.Err)
 	}
-	return Redraw // Should never be reached
+	return nil
 }
 
-// Randomly choose a new game piece from among the the available pieces.
-func (game *Game) GeneratePiece() *Piece {
-	return &game.pieces[rand.Intn(len(game.pieces))]
+// Modes which implement lockScorer do their own scoring: they're told about every piece that locks, along
+// with the number of rows that it completed, and the standard points for clearing lines aren't given. Rows
+// completed later on by a chain (see finishClear) don't count towards any piece.
+type lockScorer interface {
+	pieceLocked(game *Game, rows int)
 }
 
-// Anchor the current piece to the board, clears lines, increments the score, and generate a new piece. Sets
-// the 'game over' state if the new piece overlaps existing pieces.
+// Anchor the current piece to the board and start clearing any completed lines. If there aren't any, the next
+// piece comes in after the entry delay.
 func (game *Game) anchor() {
-	game.board.mergeCurrentPiece()
-
-	// Clear any completed rows (with animation) and increment the score if necessary.
-	rowsCleared := game.board.clearedRows()
-
-	if len(rowsCleared) > 0 {
-		// Animate the cleared rows disappearing
-		game.stopTicker()
-		flickerCells := make(map[Vector]termbox.Attribute)
-		for _, y := range rowsCleared {
-			for x := 0; x < width; x++ {
-				point := Vector{x, y}
-				flickerCells[point] = game.board.cells[point]
-			}
-		}
-		for i := 0; i < 5; i++ {
-			for point, color := range flickerCells {
-				if i%2 == 0 {
-					color = backgroundColor
-				}
-				setBoardCell((point.x*2)+2, headerHeight+point.y+2, color)
-			}
-			termbox.Flush()
-			time.Sleep(80 * time.Millisecond)
-		}
+	if game.onAnchor != nil {
+		game.onAnchor()
+	}
+	game.tSpin = game.isTSpin()
+	game.checkFinesse()
+	game.board.mergeCurrentPiece(game.clock)
+	game.piecesPlaced++
+	if game.finesseFailed {
+		game.over = true
+		return
+	}
+	rows := game.board.clearedRows()
+	if scorer, ok := game.mode.(lockScorer); ok {
+		scorer.pieceLocked(game, len(rows))
+	}
+
+	// Completed rows flash for a little while before they are removed (see finishClear).
+	if len(rows) > 0 {
+		game.clearingRows = rows
+		game.clearStart = game.clock
+		return
+	}
+	if entry := game.timings().Entry; entry > 0 {
+		game.spawnAt = game.clock + entry
+		return
+	}
+	game.spawnNextPiece()
+}
+
+// Finish clearing rows and bring in the next piece after the entry delay. If the blocks that fell completed
+// more rows (which can happen with sticky or cascade gravity; see ClearGravity), those rows are cleared first
+// as the next link of a chain.
+func (game *Game) finishClear() {
+	game.clearLines()
+	if rows := game.board.clearedRows(); len(rows) > 0 {
+		game.chain++
+		game.clearingRows = rows
+		game.clearStart = game.clock
+		return
+	}
+	game.chain = 0
+	if entry := game.timings().Entry; entry > 0 {
+		game.spawnAt = game.clock + entry
+		return
+	}
+	game.nextDrop = game.clock + game.dropDelayMillis
+	game.spawnNextPiece()
+}
 
-		// Get rid of the rows
-		game.board.clearRows()
+// Remove the rows that were being cleared and increment the score.
+func (game *Game) clearLines() {
+	rowsCleared := len(game.clearingRows)
+	for _, y := range game.clearingRows {
+		if game.board.rowHasGarbage(y) {
+			game.garbageCleared++
+		}
+	}
+	game.board.clearRows(game.mode.Options().ClearGravity)
+	game.clearingRows = nil
+	game.lines += rowsCleared
+
+	// Scoring -- 1 row -> 100, 2 rows -> 200, ... 4 rows -> 800, multiplied by the length of the chain so far
+	if _, ok := game.mode.(lockScorer); !ok {
+		points := 100 * math.Pow(2, float64(rowsCleared-1))
+		game.score += int(points) * (game.chain + 1)
+	}
 
-		// Scoring -- 1 row -> 100, 2 rows -> 200, ... 4 rows -> 800
-		points := 100 * math.Pow(2, float64(len(rowsCleared)-1))
-		game.score += int(points)
+	game.updateSpeed()
+}
 
-		game.startTicker()
+// End the game because the mode's goal has been reached. Rows which are being cleared are removed first.
+func (game *Game) finish() {
+	if game.clearingRows != nil {
+		game.clearLines()
 	}
+	game.over = true
+	game.finished = true
+}
 
-	// Bring in the next piece.
+// Bring in the next piece. Sets the 'game over' state if the new piece overlaps existing pieces, or if there
+// isn't a next piece because the mode's queue has run out.
+func (game *Game) spawnNextPiece() {
 	game.board.currentPiece = game.nextPiece
+	if game.board.currentPiece == nil {
+		game.over = true
+		return
+	}
 	game.board.currentPiece.currentRotation = 0
 	game.board.currentPosition = game.board.currentPiece.initialLocation
 	game.nextPiece = game.GeneratePiece()
+	game.lockStart = -1
+	game.lockResets = 0
+	game.rotated = false
+	game.startPieceInputs()
 
 	if game.board.currentPieceInCollision() {
 		game.over = true
+		return
 	}
+	game.saveSnapshot()
 }
 
 // Attempt to move.
@@ -265,6 +487,13 @@ func (game *Game) Move(where Direction) {
 	// Perform anchoring if we tried to move down but we were unsuccessful.
 	if where == Down && !moved {
 		game.anchor()
+		return
+	}
+	if moved {
+		game.rotated = false
+	}
+	if moved && where != Down {
+		game.resetLockDelay()
 	}
 }
 
@@ -272,110 +501,129 @@ func (game *Game) Move(where Direction) {
 func (game *Game) QuickDrop() {
 	// Move down as far as possible
 	for game.board.moveIfPossible(Vector{0, 1}) {
+		game.rotated = false
 	}
-	game.DrawDynamic(false)
 	game.anchor()
 }
 
 // Rotates the current game piece, if possible.
 func (game *Game) Rotate() {
-	game.board.currentPiece.rotate()
-	if game.board.currentPieceInCollision() {
-		game.board.currentPiece.unrotate()
+	if !game.board.rotateIfPossible(game.ruleset.rotation.kicks) {
+		return
 	}
+	game.rotated = true
+	game.resetLockDelay()
 }
 
-// Draw the dynamic parts of the game interface (the board, the next piece preview pane, and the score).  The
-// static parts should be drawn with the drawStaticBoardParts() function, if needed.  If clearOnly is true, 
-// the board and preview pane will be cleared rather than redrawn. 
-func (game *Game) DrawDynamic(clearOnly bool) {
-
-	// Print the board contents. Each block will correspond to a side-by-side pair of cells in the termbox, so
-	// that the visible blocks will be roughly square.  If clearOnly is true, draw background color.
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			if clearOnly {
-				setBoardCell((x*2)+2, headerHeight+y+2, backgroundColor)
-			} else {
-				color := game.board.CellColor(Vector{x, y})
-				setBoardCell((x*2)+2, headerHeight+y+2, color)
-			}
-		}
+// Check whether the current piece is in a T-spin: it's a T which got where it is by rotating, and at least
+// three of the four cells diagonally next to its center are filled (or off the board).
+func (game *Game) isTSpin() bool {
+	board := game.board
+	if board.currentPiece.name != "T" || !game.rotated {
+		return false
 	}
-
-	// Print the preview piece. Need to clear the box first.  Draw next piece only if clearOnly is false
-	previewPieceOffset := Vector{(width * 2) + 8, headerHeight + 3}
-	for x := 0; x < 8; x++ {
-		for y := 0; y < 4; y++ {
-			cursor := previewPieceOffset.plus(Vector{x, y})
-			setCell(cursor.x, cursor.y, ' ', termbox.ColorDefault)
-		}
+	// The center of a T is the block that's next to all of the others.
+	var center Vector
+	blocks := make(map[Vector]bool)
+	for _, block := range board.currentPiece.instance() {
+		blocks[block.plus(board.currentPosition)] = true
 	}
-	if !clearOnly {
-		for _, point := range game.nextPiece.rotations[0] {
-			cursor := previewPieceOffset.plus(Vector{point.x * 2, point.y})
-			setBoardCell(cursor.x, cursor.y, game.nextPiece.color)
+	for block := range blocks {
+		neighbors := 0
+		for _, direction := range []Vector{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			if blocks[block.plus(direction)] {
+				neighbors++
+			}
+		}
+		if neighbors == 3 {
+			center = block
 		}
 	}
-
-	// Draw the current score.  If clearOnly, do the same.
-	score := game.score
-	cursor := Vector{(width * 2) + 18, headerHeight + previewHeight + 7}
-	for {
-		digit := score % 10
-		score /= 10
-		drawDigitAsAscii(cursor.x, cursor.y, digit)
-		cursor = cursor.plus(Vector{-4, 0})
-		if score == 0 {
-			break
+	corners := 0
+	for _, corner := range []Vector{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}} {
+		if board.overlaps(center.plus(corner)) {
+			corners++
 		}
 	}
-
-	// Flush termbox's internal state to the screen.
-	termbox.Flush()
+	return corners >= 3
 }
 
-// Pause or unpause the game, depending on game.paused.
+// Pause or unpause the game, depending on game.paused. The game clock stops while the game is paused.
 func (game *Game) PauseToggle() {
-	if game.paused {
-		drawStaticBoardParts()
-		game.DrawDynamic(false)
-		game.startTicker()
-	} else {
-		game.stopTicker()
-		game.DrawPauseScreen()
-	}
 	game.paused = !game.paused
 }
 
-// Draw the pause screen, hiding the game board and next piece.
-func (game *Game) DrawPauseScreen() {
-	// Clear the board and preview screen
-	game.DrawDynamic(true)
+// Lines of text describing how the game went, for the results screen: the mode's results, and the number of
+// finesse faults if they were counted.
+func (game *Game) Results() []string {
+	return append(game.mode.Results(game), game.finesseResults()...)
+}
 
-	// Draw PAUSED overlay
-	for y := (totalHeight/2 - 1); y <= (totalHeight/2)+1; y++ {
-		for x := 1; x < totalWidth+3; x++ {
-			termbox.SetCell(x, y, ' ', termbox.ColorDefault, termbox.ColorBlue)
-		}
-	}
-	for i, ch := range "PAUSED" {
-		termbox.SetCell(totalWidth/2-2+i, totalHeight/2, ch, termbox.ColorWhite, termbox.ColorBlue)
-	}
+// The number of pieces placed with more moves and rotations than they needed (if finesse is tracked; see
+// ModeOptions.Finesse).
+func (game *Game) FinesseFaults() int {
+	return game.finesseFaults
+}
 
-	// Flush termbox to screen
-	termbox.Flush()
+// The current score.
+func (game *Game) Score() int {
+	return game.score
 }
 
-// Draw the "GAME OVER" overlay on top of the game interface.
-func (game *Game) DrawGameOver() {
-	for y := (totalHeight/2 - 1); y <= (totalHeight/2)+1; y++ {
-		for x := 1; x < totalWidth+3; x++ {
-			termbox.SetCell(x, y, ' ', termbox.ColorDefault, termbox.ColorBlue)
-		}
-	}
-	for i, ch := range "GAME OVER" {
-		termbox.SetCell(totalWidth/2-4+i, totalHeight/2, ch, termbox.ColorWhite, termbox.ColorBlue)
+// The number of lines cleared so far.
+func (game *Game) Lines() int {
+	return game.lines
+}
+
+// The number of pieces that have been anchored to the board so far.
+func (game *Game) PiecesPlaced() int {
+	return game.piecesPlaced
+}
+
+// The time, in milliseconds, that has been played so far.
+func (game *Game) Clock() int {
+	return game.clock
+}
+
+// Whether the game has ended.
+func (game *Game) Over() bool {
+	return game.over
+}
+
+// Whether the game ended by reaching the mode's goal (as opposed to topping out).
+func (game *Game) Finished() bool {
+	return game.finished
+}
+
+// Show a line of text below the controls, such as why a network match ended.
+func (game *Game) SetMessage(message string) {
+	game.message = message
+}
+
+// The current level (always 1 in modes without levels).
+func (game *Game) Level() int {
+	return game.level
+}
+
+// The game's mode.
+func (game *Game) Mode() Mode {
+	return game.mode
+}
+
+// The time, in milliseconds, since the player's first input (or 0 if there hasn't been one yet).
+func (game *Game) TimeSinceFirstInput() int {
+	if game.firstInput < 0 {
+		return 0
 	}
-	termbox.Flush()
+	return game.clock - game.firstInput
+}
+
+// The replay of the game so far, with the result fields filled in with the current state of the game.
+func (game *Game) Replay() *Replay {
+	replay := *game.replay
+	replay.Inputs = append([]ReplayInput(nil), game.replay.Inputs...)
+	replay.Score = game.score
+	replay.Lines = game.lines
+	replay.Time = game.clock
+	return &replay
 }
@@ -0,0 +1,83 @@
+package tetris
+
+// Push every cell of the board up by one row and fill the bottom row with garbage, except for a hole in the
+// given column. Cells pushed above the top of the board are lost.
+func (board *Board) pushGarbageRow(hole int) {
+	for y := 1; y < height; y++ {
+		for x := 0; x < width; x++ {
+			board.moveCell(Vector{x, y}, Vector{x, y - 1})
+		}
+	}
+	for x := 0; x < width; x++ {
+		delete(board.lockTimes, Vector{x, height - 1})
+		if x == hole {
+			delete(board.cells, Vector{x, height - 1})
+		} else {
+			board.cells[Vector{x, height - 1}] = garbageColor
+		}
+	}
+}
+
+// Check whether a row has any garbage in it.
+func (board *Board) rowHasGarbage(y int) bool {
+	for x := 0; x < width; x++ {
+		if board.cells[Vector{x, y}] == garbageColor {
+			return true
+		}
+	}
+	return false
+}
+
+// Count the rows which have any garbage in them.
+func (board *Board) garbageRows() int {
+	rows := 0
+	for y := 0; y < height; y++ {
+		if board.rowHasGarbage(y) {
+			rows++
+		}
+	}
+	return rows
+}
+
+// Choose the column of the hole in a new garbage row, given the column of the hole in the row below it (or -1
+// if there isn't one). messiness is the percentage chance that the hole is in a different column from the one
+// below; with a messiness of 0, all of the holes line up. The choice comes from the game's random number
+// generator, so games with the same seed get the same garbage.
+func (game *Game) garbageHole(previous, messiness int) int {
+	if previous < 0 {
+		return game.rng.Intn(width)
+	}
+	if game.rng.Intn(100) >= messiness {
+		return previous
+	}
+	hole := game.rng.Intn(width - 1)
+	if hole >= previous {
+		hole++
+	}
+	return hole
+}
+
+// Raise garbage rows from the bottom of the board, one for each of the given hole columns (the first one ends
+// up highest). Everything on the board moves up, including rows which are being cleared and the current
+// piece. If anything in the stack is pushed above the top of the board, the player tops out. The current piece
+// stays put if it's already at the top, and the player only tops out if the stack then overlaps it.
+func (game *Game) raiseGarbage(holes []int) {
+	for _, hole := range holes {
+		for x := 0; x < width; x++ {
+			if game.board.cells.contains(Vector{x, 0}) {
+				game.over = true
+			}
+		}
+		game.board.pushGarbageRow(hole)
+		for i := range game.clearingRows {
+			game.clearingRows[i]--
+		}
+		if game.board.currentPiece != nil && !game.board.moveIfPossible(Vector{0, -1}) &&
+			game.board.currentPieceInCollision() {
+			game.over = true
+		}
+		if game.over {
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package tetris
+
+// Modes can implement rewinder to let the player undo the placement of pieces, and redo what they undid (see
+// Game.undo). The game keeps a snapshot of its state from when each piece came in.
+type rewinder interface {
+	rewind()
+}
+
+// A snapshot of the game's state, taken as a piece comes in (see Game.saveSnapshot).
+type snapshot struct {
+	game Game
+	// The current piece's rotation, which lives in the Piece that the game shares with its snapshots.
+	rotation int
+}
+
+// Take a snapshot of the game's state, sharing nothing that changes as the game goes on. The history itself
+// isn't part of the snapshot.
+func (game *Game) snapshot() *snapshot {
+	s := &snapshot{game: *game}
+	board := *game.board
+	board.cells = make(ColorMap)
+	for position, color := range game.board.cells {
+		board.cells[position] = color
+	}
+	board.lockTimes = make(map[Vector]int)
+	for position, lockTime := range game.board.lockTimes {
+		board.lockTimes[position] = lockTime
+	}
+	s.game.board = &board
+	rng := *game.rng
+	s.game.rng = &rng
+	if game.queue != nil {
+		s.game.queue = append([]string{}, game.queue...)
+	}
+	s.game.pieceInputs = append([]GameEvent(nil), game.pieceInputs...)
+	s.game.history = nil
+	s.game.future = nil
+	if game.board.currentPiece != nil {
+		s.rotation = game.board.currentPiece.currentRotation
+	}
+	return s
+}
+
+// Add a snapshot of the game to its history as a new piece comes in, if the mode lets the player undo. This
+// forgets anything that was undone (there's nothing to redo after a new placement).
+func (game *Game) saveSnapshot() {
+	if _, ok := game.mode.(rewinder); !ok {
+		return
+	}
+	game.history = append(game.history, game.snapshot())
+	game.future = nil
+}
+
+// Go back to a snapshot. Only the game's state is restored: the clock keeps going, the inputs stay in the
+// replay, and the history is left for the caller to update. Timers (like the lock delay) start again.
+func (game *Game) restore(s *snapshot) {
+	restored := s.game
+	copied := restored.snapshot()
+	restored.board = copied.game.board
+	restored.rng = copied.game.rng
+	restored.queue = copied.game.queue
+	restored.pieceInputs = copied.game.pieceInputs
+	if restored.board.currentPiece != nil {
+		restored.board.currentPiece.currentRotation = s.rotation
+	}
+
+	restored.clock = game.clock
+	restored.replay = game.replay
+	restored.firstInput = game.firstInput
+	restored.message = game.message
+	restored.history = game.history
+	restored.future = game.future
+	restored.undos = game.undos
+	restored.nextDrop = game.clock + restored.dropDelayMillis
+	restored.lockStart = -1
+	restored.spawnAt = -1
+	*game = restored
+}
+
+// Undo the placement of the last piece, going back to when it came in, if the mode allows it and there's a
+// placement to undo.
+func (game *Game) undo() {
+	if len(game.history) < 2 {
+		return
+	}
+	last := len(game.history) - 1
+	game.future = append(game.future, game.history[last])
+	game.history = game.history[:last]
+	game.undos++
+	game.restore(game.history[last-1])
+}
+
+// Redo the last placement that was undone, if there is one.
+func (game *Game) redo() {
+	if len(game.future) == 0 {
+		return
+	}
+	last := len(game.future) - 1
+	game.history = append(game.history, game.future[last])
+	game.future = game.future[:last]
+	game.restore(game.history[len(game.history)-1])
+}
@@ -0,0 +1,295 @@
+package tetris
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// Network matches are played in lockstep: both players simulate the whole match (see Match), and only send
+// each other their inputs. Time is divided into frames of LockstepFrameMillis, and each player's inputs are
+// applied at the start of a frame, so the inputs for every frame determine the match completely. A player's
+// inputs are scheduled LockstepInputDelay frames ahead, which gives them time to reach the other player
+// before they're needed.
+const (
+	LockstepFrameMillis = 16
+	LockstepInputDelay  = 4
+	// How often the players compare hashes of the match's state to check that their simulations agree.
+	lockstepHashFrames = 60
+)
+
+// Describe the state of the game which affects how it goes on, as text, so that two simulations of the same
+// game can be compared (see Match.stateHash). It's also readable enough to diagnose a desync with.
+func (game *Game) state() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "clock %d over %t finished %t rng %d\n",
+		game.clock, game.over, game.finished, game.rng.state)
+	fmt.Fprintf(&b, "score %d lines %d level %d pieces %d chain %d\n",
+		game.score, game.lines, game.level, game.piecesPlaced, game.chain)
+	fmt.Fprintf(&b, "drop %d/%d lock %d/%d spawn %d clearing %v/%d\n", game.nextDrop, game.dropDelayMillis,
+		game.lockStart, game.lockResets, game.spawnAt, game.clearingRows, game.clearStart)
+	if piece := game.board.currentPiece; piece != nil {
+		fmt.Fprintf(&b, "piece %s %d %d rotation %d\n", piece.name, game.board.currentPosition.x,
+			game.board.currentPosition.y, piece.currentRotation)
+	}
+	if game.nextPiece != nil {
+		fmt.Fprintf(&b, "next %s\n", game.nextPiece.name)
+	}
+	if mode, ok := game.mode.(*versusMode); ok {
+		fmt.Fprintf(&b, "sent %d cancelled %d received %d combo %d b2b %t garbage %v rng %d\n",
+			mode.sent, mode.cancelled, mode.received, mode.attacker.combo, mode.attacker.backToBack,
+			mode.garbage.batches, mode.rng.state)
+		fmt.Fprintf(&b, "targeting %s target %d attacker %d kos %d place %d\n",
+			mode.targeting, mode.target, mode.lastAttacker, mode.kos, mode.place)
+	}
+	colors := make(map[Color]byte)
+	for _, piece := range game.pieces {
+		colors[piece.color] = piece.name[0]
+	}
+	for y := 0; y < height; y++ {
+		row := []byte(strings.Repeat(".", width))
+		for x := 0; x < width; x++ {
+			if color, ok := game.board.cells[Vector{x, y}]; ok {
+				row[x] = '#'
+				if name, ok := colors[color]; ok {
+					row[x] = name
+				}
+			}
+		}
+		b.Write(row)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Describe the state of every game in the match (see Game.state).
+func (match *Match) state() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "over %t winner %d rng %d\n", match.over, match.winner, match.rng.state)
+	for player, game := range match.games {
+		fmt.Fprintf(&b, "\nplayer %d\n%s", player+1, game.state())
+	}
+	return b.String()
+}
+
+// A hash of the match's state, for checking that two simulations of it agree.
+func (match *Match) stateHash() uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(match.state()))
+	return h.Sum64()
+}
+
+// A Lockstep runs a match from the inputs of every player (see LockstepFrameMillis), checking the simulation
+// against another one (the other player's, or a server's) with hashes of the state. It doesn't send or
+// receive anything itself: the network code passes the inputs and hashes between the simulations.
+type Lockstep struct {
+	match *Match
+	// The index of this player in the match, or -1 if this simulation isn't playing in it (e.g. a
+	// spectator's, or a server's).
+	player int
+	// The inputs for each frame which hasn't been simulated yet, for each player, once they're known.
+	inputs []map[int][]GameEvent
+	// The next frame to simulate, and the last frame that this player's inputs have been scheduled for.
+	frame, scheduled int
+	// This player's inputs which haven't been scheduled for a frame yet.
+	pending []GameEvent
+	// The hashes of the state after frames which are checked (see lockstepHashFrames), from this simulation
+	// and from the other one, until both are known.
+	hashes, remoteHashes map[int]uint64
+	// The states after the frames in hashes, for the diagnostic dump if they don't match.
+	states map[int]string
+	// A description of the desync if the simulations disagreed, including both games' states and inputs.
+	desync string
+	// Every input applied so far, for replaying the match.
+	record *MatchReplay
+}
+
+// Run a match in lockstep, as one of its players (from 0), or -1 to simulate it without playing.
+func NewLockstep(match *Match, player int) *Lockstep {
+	step := &Lockstep{
+		match:        match,
+		player:       player,
+		inputs:       make([]map[int][]GameEvent, len(match.games)),
+		scheduled:    LockstepInputDelay - 1,
+		hashes:       make(map[int]uint64),
+		remoteHashes: make(map[int]uint64),
+		states:       make(map[int]string),
+		record: &MatchReplay{Seed: match.games[0].replay.Seed, Options: match.options,
+			Players: len(match.games)},
+	}
+	// Nobody has any inputs during the frames before the first ones that they can schedule.
+	for p := range step.inputs {
+		step.inputs[p] = make(map[int][]GameEvent)
+		for frame := 0; frame < LockstepInputDelay; frame++ {
+			step.inputs[p][frame] = nil
+		}
+	}
+	return step
+}
+
+// The match being run.
+func (step *Lockstep) Match() *Match {
+	return step.match
+}
+
+// The index of this player in the match, or -1 if this simulation isn't playing in it.
+func (step *Lockstep) Player() int {
+	return step.player
+}
+
+// The next frame to simulate.
+func (step *Lockstep) Frame() int {
+	return step.frame
+}
+
+// The description of the desync if the simulations disagreed (see Check), including both games' states and
+// inputs, or "" if they haven't.
+func (step *Lockstep) Desync() string {
+	return step.desync
+}
+
+// Queue an input from this player, to be scheduled for the next frame available.
+func (step *Lockstep) Input(event GameEvent) {
+	step.pending = append(step.pending, event)
+}
+
+// Whether this player's inputs must be scheduled for another frame before the next frame can be simulated.
+func (step *Lockstep) ScheduleDue() bool {
+	return step.player >= 0 && step.scheduled < step.frame+LockstepInputDelay
+}
+
+// Schedule this player's pending inputs for the next frame, returning it. The inputs must be sent to the
+// other player.
+func (step *Lockstep) Schedule() (int, []GameEvent) {
+	step.scheduled++
+	events := step.pending
+	step.pending = nil
+	step.inputs[step.player][step.scheduled] = events
+	return step.scheduled, events
+}
+
+// Record another player's inputs for a frame, which must be the frame after the last one they sent.
+func (step *Lockstep) ReceiveInputs(player, frame int, events []GameEvent) error {
+	inputs := step.inputs[player]
+	if _, ok := inputs[frame]; ok || frame < step.frame {
+		return fmt.Errorf("inputs for frame %d were already received", frame)
+	}
+	if _, ok := inputs[frame-1]; !ok && frame > step.frame {
+		return fmt.Errorf("inputs for frame %d arrived out of order", frame)
+	}
+	inputs[frame] = events
+	return nil
+}
+
+// A player's inputs for a frame which hasn't been simulated yet, if they're known.
+func (step *Lockstep) Inputs(player, frame int) ([]GameEvent, bool) {
+	events, ok := step.inputs[player][frame]
+	return events, ok
+}
+
+// Set a player's inputs for a frame which hasn't been simulated yet, without checking that they arrived in
+// order (e.g. for a server, which fills in the inputs of players who have left).
+func (step *Lockstep) SetInputs(player, frame int, events []GameEvent) {
+	step.inputs[player][frame] = events
+}
+
+// Simulate the next frame if every player's inputs for it are known, returning whether it was simulated.
+// Before a frame is simulated, this player's inputs for it must have been scheduled (see ScheduleDue). After
+// frames which are checked, Hash has the hash of the state: it must be sent to the other side, and then
+// compared with theirs by Check.
+func (step *Lockstep) Simulate() bool {
+	frame := step.frame
+	if step.ScheduleDue() {
+		return false
+	}
+	for _, inputs := range step.inputs {
+		if _, ok := inputs[frame]; !ok {
+			return false
+		}
+	}
+	for player, inputs := range step.inputs {
+		for _, event := range inputs[frame] {
+			step.match.Handle(player, event)
+			step.record.Inputs = append(step.record.Inputs, MatchInput{frame, player, event})
+		}
+		delete(inputs, frame)
+	}
+	step.match.Advance(LockstepFrameMillis)
+	step.frame++
+	if frame%lockstepHashFrames == 0 {
+		step.hashes[frame] = step.match.stateHash()
+		step.states[frame] = step.match.state()
+	}
+	return true
+}
+
+// Knock a player out of the match because they left it, outside of the inputs of any frame (e.g. because
+// the other player said goodbye). It's recorded as a Forfeit input at the start of the next frame.
+func (step *Lockstep) Leave(player int) {
+	if step.match.over {
+		return
+	}
+	step.match.forfeit(player)
+	step.record.Inputs = append(step.record.Inputs, MatchInput{step.frame, player, Forfeit})
+}
+
+// A replay of the match so far (see MatchReplay).
+func (step *Lockstep) Replay() *MatchReplay {
+	replay := *step.record
+	replay.Frames = step.frame
+	replay.Winner = step.match.winner
+	return &replay
+}
+
+// This simulation's hash of the state after a frame, if the frame is checked and the hash hasn't been
+// compared with the other side's yet.
+func (step *Lockstep) Hash(frame int) (uint64, bool) {
+	hash, ok := step.hashes[frame]
+	return hash, ok
+}
+
+// Forget this simulation's hash of the state after a frame, when there isn't another simulation to compare
+// it with (e.g. on a server, whose hashes the players compare with theirs).
+func (step *Lockstep) DropHash(frame int) {
+	delete(step.hashes, frame)
+	delete(step.states, frame)
+}
+
+// Record the other side's hash of the state after a frame.
+func (step *Lockstep) ReceiveHash(frame int, hash uint64) {
+	step.remoteHashes[frame] = hash
+	step.Check(frame)
+}
+
+// Compare the hashes of the state after a frame, once both are known, noting a desync if they differ.
+func (step *Lockstep) Check(frame int) {
+	hash, ok := step.hashes[frame]
+	remoteHash, remoteOK := step.remoteHashes[frame]
+	if !ok || !remoteOK {
+		return
+	}
+	if hash != remoteHash && step.desync == "" {
+		step.desync = step.dump(frame, hash, remoteHash)
+	}
+	delete(step.hashes, frame)
+	delete(step.remoteHashes, frame)
+	delete(step.states, frame)
+}
+
+// Describe a desync for diagnosing it: the hashes, the state of the match after the frame where the
+// simulations disagreed (as this side saw it), and every input so far.
+func (step *Lockstep) dump(frame int, hash, remoteHash uint64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "go-tetris desync at frame %d (clock %d)\n", frame, (frame+1)*LockstepFrameMillis)
+	fmt.Fprintf(&b, "player %d of %d\n", step.player+1, len(step.match.games))
+	fmt.Fprintf(&b, "hash %016x, the other side's %016x\n", hash, remoteHash)
+	fmt.Fprintf(&b, "seed %d\noptions %s\n\n", step.match.games[0].replay.Seed, step.match.options)
+	b.WriteString(step.states[frame])
+	for player, game := range step.match.games {
+		fmt.Fprintf(&b, "\nplayer %d inputs\n", player+1)
+		for _, input := range game.replay.Inputs {
+			fmt.Fprintf(&b, "input %d %s\n", input.Time, input.Event)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,193 @@
+package tetris
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Make the simulations of a match for each of its two players, which pass their inputs and hashes straight to
+// each other (as the network code would send them).
+func lockstepPair(seed int64, options ModeOptions) [2]*Lockstep {
+	host := NewLockstep(NewMatch(seed, 2, options), 0)
+	guest := NewLockstep(NewMatch(seed, 2, options), 1)
+	return [2]*Lockstep{host, guest}
+}
+
+// Play a match in lockstep for up to some number of frames, or until it's over or a side notices a desync.
+// Before each frame, inputs calls for each player's inputs for that frame.
+func playLockstep(t *testing.T, sides [2]*Lockstep, frames int, inputs func(frame int)) {
+	host, guest := sides[0], sides[1]
+	for frame := 1; frame <= frames && !host.match.over && host.desync == "" && guest.desync == ""; frame++ {
+		inputs(frame)
+		for sides[0].frame < frame && !sides[0].match.over {
+			for player, side := range sides {
+				for side.ScheduleDue() {
+					scheduled, events := side.Schedule()
+					if err := sides[1-player].ReceiveInputs(player, scheduled, events); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			for player, side := range sides {
+				simulated := side.frame
+				if !side.Simulate() {
+					t.Fatalf("frame %d: player %d's simulation is stuck", simulated, player+1)
+				}
+				if hash, ok := side.Hash(simulated); ok {
+					sides[1-player].ReceiveHash(simulated, hash)
+					side.Check(simulated)
+				}
+			}
+		}
+	}
+}
+
+// Inputs for the first player to play with the bot, and the second to drop a piece every so often.
+func botVersusDropper(sides [2]*Lockstep) func(frame int) {
+	return func(frame int) {
+		game := sides[0].match.games[0]
+		if frame%10 == 0 && game.board.currentPiece != nil && game.clearingRows == nil {
+			for _, event := range botMoves(game) {
+				sides[0].Input(event)
+			}
+		}
+		if frame%40 == 0 {
+			sides[1].Input(QuickDrop)
+		}
+	}
+}
+
+func TestLockstepDesync(t *testing.T) {
+	sides := lockstepPair(7, ModeOptions{})
+	host, guest := sides[0], sides[1]
+	playLockstep(t, sides, 1000, func(frame int) {
+		if frame == 100 {
+			// Something that only happens in one simulation.
+			guest.match.games[1].score += 100
+		}
+		if frame%30 == 0 {
+			host.Input(QuickDrop)
+			guest.Input(QuickDrop)
+		}
+	})
+	// The match stops as soon as either side notices.
+	desync := host.desync + guest.desync
+	if !strings.HasPrefix(desync, "go-tetris desync at frame 120 ") {
+		t.Fatalf("the desync was noticed as %.40q; want it at frame 120", desync)
+	}
+	if !strings.Contains(desync, "\nplayer 2 inputs\ninput ") {
+		t.Errorf("the dump doesn't include the inputs:\n%s", desync)
+	}
+}
+
+// Check that a replay of a match played in lockstep survives being saved and read back, and reproduces the
+// match.
+func checkMatchReplay(t *testing.T, step *Lockstep) {
+	replay := step.Replay()
+	var b bytes.Buffer
+	if err := replay.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !IsMatchReplay(b.Bytes()) {
+		t.Fatalf("a saved match replay isn't recognized as one")
+	}
+	read, err := ReadMatchReplay(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, replay) {
+		t.Fatalf("the replay read back is %+v; want %+v", read, replay)
+	}
+	simulated, err := read.Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if simulated.state() != step.match.state() {
+		t.Errorf("the replay ends in the state\n%s\nbut the match ended in\n%s", simulated.state(),
+			step.match.state())
+	}
+}
+
+func TestMatchReplay(t *testing.T) {
+	sides := lockstepPair(42, ModeOptions{Stack: StackFading})
+	host, guest := sides[0], sides[1]
+	playLockstep(t, sides, 20000, botVersusDropper(sides))
+	if !host.match.over {
+		t.Fatalf("the match isn't over after %d frames", host.frame)
+	}
+	replay := host.Replay()
+	if replay.Winner != 0 || replay.Frames != host.frame || replay.Options.Stack != StackFading {
+		t.Errorf("the replay has winner %d after %d frames with %v; want 0 after %d with stack=fading",
+			replay.Winner, replay.Frames, replay.Options, host.frame)
+	}
+	if guest.match.winner != 0 {
+		t.Errorf("player 2 sees player %d win; want 1", guest.match.winner+1)
+	}
+	if host.match.modes[0].sent == 0 || guest.match.modes[1].received == 0 {
+		t.Errorf("no garbage was sent")
+	}
+	if !reflect.DeepEqual(replay, guest.Replay()) {
+		t.Errorf("the players recorded different replays")
+	}
+	checkMatchReplay(t, host)
+}
+
+func TestMatchReplayLeave(t *testing.T) {
+	sides := lockstepPair(3, ModeOptions{})
+	host, guest := sides[0], sides[1]
+	playLockstep(t, sides, 100, func(frame int) {
+		if frame%20 == 0 {
+			guest.Input(QuickDrop)
+		}
+	})
+	if host.match.over {
+		t.Fatalf("the match ended before the other player left")
+	}
+	host.Leave(1)
+	if !host.match.over {
+		t.Fatalf("the match didn't end when the other player left")
+	}
+	inputs := host.Replay().Inputs
+	if last := inputs[len(inputs)-1]; last != (MatchInput{host.frame, 1, Forfeit}) {
+		t.Errorf("the last input recorded is %+v; want the other player's forfeit", last)
+	}
+	checkMatchReplay(t, host)
+}
+
+func TestReadMatchReplayErrors(t *testing.T) {
+	for _, test := range []struct {
+		replay, want string
+	}{
+		{"go-tetris replay 1\nseed 1\n", "missing"},
+		{matchReplayHeader + "\ninput 1 1 hold\n", "bad input event"},
+		{matchReplayHeader + "\ninput 1 0 left\n", "bad input player"},
+		{matchReplayHeader + "\ninput 1 3 left\n", "only 2"},
+		{matchReplayHeader + "\nplayers 1\n", "from 2 to"},
+		{matchReplayHeader + "\nplayers 1000000\n", "from 2 to"},
+		{matchReplayHeader + "\nframes -1\n", "frames (-1)"},
+		{matchReplayHeader + "\nframes 999999999\n", "frames (999999999)"},
+		{matchReplayHeader + "\noptions stack=foggy\n", "foggy"},
+		{matchReplayHeader + "\nwinner 5\n", "only 2"},
+	} {
+		_, err := ReadMatchReplay(strings.NewReader(test.replay))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("reading %q gave the error %v; want one about %q", test.replay, err, test.want)
+		}
+	}
+	for _, test := range []struct {
+		replay, want string
+	}{
+		{matchReplayHeader + "\ninput 5 1 left\ninput 4 2 left\nframes 10\n", "earlier"},
+		{matchReplayHeader + "\ninput 11 1 left\nframes 10\n", "after the end"},
+	} {
+		replay, err := ReadMatchReplay(strings.NewReader(test.replay))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := replay.Simulate(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("simulating %q gave the error %v; want one about %q", test.replay, err, test.want)
+		}
+	}
+}
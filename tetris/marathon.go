@@ -0,0 +1,72 @@
+package tetris
+
+import (
+	"fmt"
+)
+
+// Marathon mode uses the modern ruleset (see Ruleset), starting at a chosen level and going up a level for
+// every 10 lines, until a line cap (150, normally) is reached. In endless marathon there's no cap, and the
+// game goes on until the player tops out.
+type marathonMode struct {
+	modeBase
+}
+
+// The number of lines to clear to go up a level.
+const linesPerLevel = 10
+
+func (mode *marathonMode) Setup(game *Game) {
+	game.level = mode.options.Level
+	game.setRuleset(modernRuleset)
+}
+
+func (mode *marathonMode) dropDelay(game *Game) int {
+	return game.ruleset.gravity(game.level)
+}
+
+func (mode *marathonMode) Update(game *Game) {
+	if level := mode.options.Level + game.lines/linesPerLevel; level != game.level {
+		game.level = level
+		game.updateSpeed()
+	}
+	if !mode.options.Endless && game.lines+len(game.clearingRows) >= mode.options.Lines {
+		game.finish()
+	}
+}
+
+func (mode *marathonMode) title(game *Game) string {
+	if game.finished {
+		return "VICTORY!"
+	}
+	return "GAME OVER"
+}
+
+func (mode *marathonMode) lines(game *Game) string {
+	if mode.options.Endless {
+		return fmt.Sprint(game.lines)
+	}
+	return fmt.Sprintf("%d/%d", game.lines, mode.options.Lines)
+}
+
+func (mode *marathonMode) Status(game *Game) []string {
+	return []string{
+		fmt.Sprintf("Level  %d", game.level),
+		fmt.Sprintf("Lines  %s", mode.lines(game)),
+		fmt.Sprintf("Time   %s", FormatMillis(game.clock)),
+	}
+}
+
+func (mode *marathonMode) Results(game *Game) []string {
+	return []string{
+		fmt.Sprintf("Score   %d", game.score),
+		fmt.Sprintf("Level   %d", game.level),
+		fmt.Sprintf("Lines   %s", mode.lines(game)),
+		fmt.Sprintf("Pieces  %d", game.piecesPlaced),
+		fmt.Sprintf("Time    %s", FormatMillis(game.clock)),
+	}
+}
+
+// Marathon games count for personal bests if they reach the line cap. Endless games always count, since they
+// can only end by topping out.
+func (mode *marathonMode) Result(game *Game) (Result, bool) {
+	return Result{Value: game.score, Display: fmt.Sprint(game.score)}, game.finished || mode.options.Endless
+}
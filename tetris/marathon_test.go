@@ -0,0 +1,56 @@
+package tetris
+
+import (
+	"testing"
+)
+
+func TestMarathon(t *testing.T) {
+	for _, level := range []int{1, 12} {
+		mode, err := NewMode("marathon", ModeOptions{Level: level, Lines: 30})
+		if err != nil {
+			t.Fatal(err)
+		}
+		game := NewGame(5, mode)
+		for millis := 1000; !game.Over(); millis += 1000 {
+			botPlay(game, millis, 1)
+			if game.Over() {
+				break
+			}
+			if want := level + game.Lines()/linesPerLevel; game.level != want {
+				t.Fatalf("starting at level %d, the game is at level %d after %d lines; want %d",
+					level, game.level, game.Lines(), want)
+			}
+			if want := modernRuleset.gravity(game.level); game.dropDelayMillis != want {
+				t.Fatalf("at level %d the drop delay is %d ms; want %d",
+					game.level, game.dropDelayMillis, want)
+			}
+		}
+		if !game.Finished() || game.Lines() < 30 {
+			t.Errorf("starting at level %d, the bot topped out after %d lines\n%s", level, game.Lines(),
+				dumpBoard(game))
+			continue
+		}
+		replayed, err := game.Replay().Simulate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if replayed.clock != game.clock || replayed.Score() != game.Score() || replayed.level != game.level {
+			t.Errorf("starting at level %d, the replay doesn't match the game", level)
+		}
+	}
+}
+
+func TestEndlessMarathon(t *testing.T) {
+	mode, err := NewMode("marathon", ModeOptions{Level: 15, Endless: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(5, mode)
+	botPlay(game, 600000, 30)
+	if !game.Over() || game.Finished() {
+		t.Fatalf("an endless game should only end by topping out")
+	}
+	if _, ok := mode.Result(game); !ok {
+		t.Errorf("an endless game which topped out doesn't count for personal bests")
+	}
+}
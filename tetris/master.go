@@ -0,0 +1,145 @@
+package tetris
+
+import (
+	"fmt"
+)
+
+// The level at which master mode ends.
+const masterMaxLevel = 999
+
+// The grades of master mode, from worst to best, with the score needed for each one (the grandmaster grade
+// has extra requirements; see masterMode.grandmaster).
+var masterGrades = []struct {
+	name  string
+	score int
+}{
+	{"9", 0}, {"8", 400}, {"7", 800}, {"6", 1400}, {"5", 2000}, {"4", 3500}, {"3", 5500}, {"2", 8000},
+	{"1", 12000}, {"S1", 16000}, {"S2", 22000}, {"S3", 30000}, {"S4", 40000}, {"S5", 52000}, {"S6", 66000},
+	{"S7", 82000}, {"S8", 100000}, {"S9", 120000}, {"GM", 126000},
+}
+
+// The requirements for the grandmaster grade: a minimum score by a maximum time at each of these levels.
+var masterCheckpoints = []struct {
+	level, score, millis int
+}{
+	{300, 12000, (4*60 + 15) * 1000},
+	{500, 40000, (7*60 + 30) * 1000},
+	{masterMaxLevel, 126000, (13*60 + 30) * 1000},
+}
+
+// Master mode is in the style of Tetris: The Grand Master. It uses the master ruleset (see Ruleset), which
+// has the ARS rotation system and gravity that reaches 20G at level 500. The level goes up by one for every
+// piece and by one for every line cleared, except that pieces alone can't take it past the end of a section
+// (99, 199, and so on) or to the final level of 999. At the end of the game the player is awarded a grade
+// based on their score, and the grandmaster grade for reaching level 999 fast enough and with a high enough
+// score.
+type masterMode struct {
+	modeBase
+	// The scoring combo multiplier, which grows with consecutive line clears.
+	combo int
+	// The number of grandmaster checkpoints (see masterCheckpoints) passed so far, or -1 if one was failed.
+	checkpoints int
+}
+
+func (mode *masterMode) Setup(game *Game) {
+	game.level = 0
+	game.setRuleset(masterRuleset)
+	mode.combo = 1
+}
+
+func (mode *masterMode) dropDelay(game *Game) int {
+	return game.ruleset.gravity(game.level)
+}
+
+// Level up and score for a locked piece. The score for a line clear is (ceil((level + lines) / 4)) * lines *
+// combo, using the level from before the lines were counted, and it's quadrupled for a bravo (clearing the
+// whole board).
+func (mode *masterMode) pieceLocked(game *Game, rows int) {
+	if game.level%100 != 99 && game.level != masterMaxLevel-1 {
+		game.level++
+	}
+	if rows > 0 {
+		mode.combo += 2*rows - 2
+		points := (game.level + rows + 3) / 4 * rows * mode.combo
+		if len(game.board.cells) == rows*width {
+			points *= 4
+		}
+		game.score += points
+		game.level += rows
+	} else {
+		mode.combo = 1
+	}
+	if game.level > masterMaxLevel {
+		game.level = masterMaxLevel
+	}
+	for mode.checkpoints >= 0 && mode.checkpoints < len(masterCheckpoints) {
+		checkpoint := masterCheckpoints[mode.checkpoints]
+		if game.level < checkpoint.level {
+			break
+		}
+		if game.score >= checkpoint.score && game.clock <= checkpoint.millis {
+			mode.checkpoints++
+		} else {
+			mode.checkpoints = -1
+		}
+	}
+	game.updateSpeed()
+}
+
+func (mode *masterMode) Update(game *Game) {
+	if game.level >= masterMaxLevel {
+		game.finish()
+	}
+}
+
+// Whether the player has met all of the requirements for the grandmaster grade.
+func (mode *masterMode) grandmaster() bool {
+	return mode.checkpoints == len(masterCheckpoints)
+}
+
+// The index in masterGrades of the player's grade.
+func (mode *masterMode) grade(game *Game) int {
+	if mode.grandmaster() {
+		return len(masterGrades) - 1
+	}
+	grade := 0
+	for i, g := range masterGrades[:len(masterGrades)-1] {
+		if game.score >= g.score {
+			grade = i
+		}
+	}
+	return grade
+}
+
+// The level at the end of the current section: the level that pieces alone can't go past.
+func (mode *masterMode) sectionEnd(game *Game) int {
+	end := (game.level/100 + 1) * 100
+	if end > masterMaxLevel {
+		return masterMaxLevel
+	}
+	return end
+}
+
+func (mode *masterMode) Status(game *Game) []string {
+	return []string{
+		fmt.Sprintf("Grade  %s", masterGrades[mode.grade(game)].name),
+		fmt.Sprintf("Level  %d/%d", game.level, mode.sectionEnd(game)),
+		fmt.Sprintf("Time   %s", FormatMillis(game.clock)),
+	}
+}
+
+func (mode *masterMode) Results(game *Game) []string {
+	return []string{
+		fmt.Sprintf("Grade   %s", masterGrades[mode.grade(game)].name),
+		fmt.Sprintf("Score   %d", game.score),
+		fmt.Sprintf("Level   %d", game.level),
+		fmt.Sprintf("Lines   %d", game.lines),
+		fmt.Sprintf("Time    %s", FormatMillis(game.clock)),
+	}
+}
+
+// Every master game gets a grade, however it ends.
+func (mode *masterMode) Result(game *Game) (Result, bool) {
+	grade := mode.grade(game)
+	return Result{Value: grade, Display: masterGrades[grade].name}, true
+}
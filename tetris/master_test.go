@@ -0,0 +1,140 @@
+package tetris
+
+import (
+	"testing"
+)
+
+func newMasterGame(t *testing.T) (*Game, *masterMode) {
+	mode, err := NewMode("master", ModeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewGame(11, mode), mode.(*masterMode)
+}
+
+func TestMasterLevels(t *testing.T) {
+	game, mode := newMasterGame(t)
+	game.board.cells = ColorMap{Vector{0, height - 1}: ColorRed}
+	for _, test := range []struct {
+		level, rows int
+		want        int
+	}{
+		{0, 0, 1},
+		{98, 0, 99},
+		// Pieces alone can't finish a section, but lines can.
+		{99, 0, 99},
+		{99, 1, 100},
+		{998, 0, 998},
+		{997, 4, masterMaxLevel},
+	} {
+		game.level = test.level
+		mode.pieceLocked(game, test.rows)
+		if game.level != test.want {
+			t.Errorf("at level %d, locking a piece which clears %d rows goes to level %d; want %d",
+				test.level, test.rows, game.level, test.want)
+		}
+	}
+}
+
+func TestMasterScore(t *testing.T) {
+	game, mode := newMasterGame(t)
+	game.board.cells = ColorMap{Vector{0, height - 1}: ColorRed}
+	game.level = 10
+	mode.pieceLocked(game, 2)
+	// ceil((11 + 2) / 4) * 2 lines * a combo of 3.
+	if game.score != 4*2*3 {
+		t.Fatalf("the score for a double at level 10 is %d; want %d", game.score, 4*2*3)
+	}
+	mode.pieceLocked(game, 0)
+	if mode.combo != 1 {
+		t.Errorf("the combo is %d after a piece which didn't clear any lines; want 1", mode.combo)
+	}
+	// Clearing the whole board quadruples the score.
+	game.score = 0
+	game.level = 10
+	game.board.cells = make(ColorMap)
+	for x := 0; x < width; x++ {
+		game.board.cells[Vector{x, height - 1}] = ColorRed
+	}
+	mode.pieceLocked(game, 1)
+	if want := 3 * 1 * 1 * 4; game.score != want {
+		t.Errorf("the score for a single bravo at level 10 is %d; want %d", game.score, want)
+	}
+}
+
+func TestMasterGrades(t *testing.T) {
+	game, mode := newMasterGame(t)
+	for _, test := range []struct {
+		score int
+		want  string
+	}{
+		{0, "9"}, {399, "9"}, {400, "8"}, {16000, "S1"}, {125999, "S9"}, {200000, "S9"},
+	} {
+		game.score = test.score
+		if grade := masterGrades[mode.grade(game)].name; grade != test.want {
+			t.Errorf("a score of %d gets grade %s; want %s", test.score, grade, test.want)
+		}
+	}
+	mode.checkpoints = len(masterCheckpoints)
+	if grade := masterGrades[mode.grade(game)].name; grade != "GM" {
+		t.Errorf("passing every checkpoint gets grade %s; want GM", grade)
+	}
+}
+
+func TestMasterBot(t *testing.T) {
+	game, mode := newMasterGame(t)
+	botPlay(game, 20*60000, 5)
+	if game.level < 100 {
+		t.Errorf("the bot only reached level %d\n%s", game.level, dumpBoard(game))
+	}
+	if want := masterRuleset.gravity(game.level); game.dropDelayMillis != want {
+		t.Errorf("at level %d the drop delay is %d ms; want %d", game.level, game.dropDelayMillis, want)
+	}
+	if _, ok := mode.Result(game); !ok {
+		t.Errorf("the game didn't get a grade")
+	}
+	replayed, err := game.Replay().Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.clock != game.clock || replayed.Score() != game.Score() || replayed.level != game.level {
+		t.Errorf("the replay doesn't match the game")
+	}
+}
+
+func TestARSKicks(t *testing.T) {
+	game, _ := newMasterGame(t)
+	board := game.board
+	for i := range game.pieces {
+		if game.pieces[i].name == "T" {
+			board.currentPiece = &game.pieces[i]
+		}
+	}
+	// A T pointing left, against the left wall, is kicked right when it's rotated.
+	board.currentPiece.currentRotation = 3
+	board.currentPosition = Vector{-1, 5}
+	if board.currentPieceInCollision() {
+		t.Fatal("the T piece doesn't fit against the wall")
+	}
+	game.Rotate()
+	if board.currentPiece.currentRotation != 0 || board.currentPosition.x != 0 {
+		t.Fatalf("after rotating, the T piece has rotation %d at %v; want rotation 0 at x = 0",
+			board.currentPiece.currentRotation, board.currentPosition)
+	}
+
+	// A piece isn't kicked if the cell blocking it is in its center column.
+	board.cells = ColorMap{Vector{4, 6}: ColorRed}
+	board.currentPiece.currentRotation = 1
+	board.currentPosition = Vector{3, 5}
+	game.Rotate()
+	if board.currentPiece.currentRotation != 1 {
+		t.Fatalf("the T piece was kicked to %v, though it was blocked in its center column",
+			board.currentPosition)
+	}
+	board.cells = ColorMap{Vector{3, 7}: ColorRed}
+	game.Rotate()
+	if board.currentPiece.currentRotation != 2 || board.currentPosition.x != 4 {
+		t.Fatalf("after rotating, the T piece has rotation %d at %v; want rotation 2 at x = 4",
+			board.currentPiece.currentRotation, board.currentPosition)
+	}
+}
@@ -0,0 +1,195 @@
+package tetris
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A MatchReplay contains everything needed to reproduce a network match exactly (see Lockstep): the seed, the
+// options, and every player's inputs along with the frame they were applied in. Unlike a Replay of one of the
+// games, it includes the garbage that the players sent each other. It also holds the result (the winner, and
+// the number of frames the match lasted) claimed by whoever recorded it, which can be checked by simulating
+// the match again.
+type MatchReplay struct {
+	Seed    int64
+	Options ModeOptions
+	Players int
+	// Every input in the order it was applied, which is the order of the frames, and within a frame, the
+	// order of the players.
+	Inputs []MatchInput
+	Frames int
+	// The index of the player who won, or -1 if nobody did.
+	Winner int
+}
+
+// A single recorded input to a match: a gameplay event, Target, or Forfeit (see Match.Handle).
+type MatchInput struct {
+	Frame  int
+	Player int
+	Event  GameEvent
+}
+
+// The first line of every match replay file.
+const matchReplayHeader = "go-tetris match 1"
+
+// The longest match that a replay can hold, in frames: a day, as for a single game (see maxReplayTime).
+const maxMatchReplayFrames = maxReplayTime / LockstepFrameMillis
+
+// Whether a file (or the start of one) holds a match replay, rather than a replay of a single game.
+func IsMatchReplay(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(matchReplayHeader))
+}
+
+// Write a match replay in a line-based text format like Replay's, with the players counted from 1:
+//
+//	go-tetris match 1
+//	seed 1234
+//	players 2
+//	options stack=fading (only if any are set)
+//	input 12 1 left
+//	input 12 2 drop
+//	...
+//	frames 5678
+//	winner 2 (or 0 if nobody won)
+func (replay *MatchReplay) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, matchReplayHeader)
+	fmt.Fprintf(bw, "seed %d\n", replay.Seed)
+	fmt.Fprintf(bw, "players %d\n", replay.Players)
+	if options := replay.Options.String(); options != "" {
+		fmt.Fprintf(bw, "options %s\n", options)
+	}
+	for _, input := range replay.Inputs {
+		fmt.Fprintf(bw, "input %d %d %s\n", input.Frame, input.Player+1, input.Event)
+	}
+	fmt.Fprintf(bw, "frames %d\n", replay.Frames)
+	fmt.Fprintf(bw, "winner %d\n", replay.Winner+1)
+	return bw.Flush()
+}
+
+// Read a match replay in the format produced by MatchReplay.Write.
+func ReadMatchReplay(r io.Reader) (*MatchReplay, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || scanner.Text() != matchReplayHeader {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("not a match replay file (missing %q header)", matchReplayHeader)
+	}
+	replay := &MatchReplay{Players: 2, Winner: -1}
+	for lineNumber := 2; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if err := replay.parseLine(fields); err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if replay.Players < 2 || replay.Players > MaxPlayers {
+		return nil, fmt.Errorf("a match has from 2 to %d players, not %d", MaxPlayers, replay.Players)
+	}
+	if replay.Frames < 0 || replay.Frames > maxMatchReplayFrames {
+		return nil, fmt.Errorf("the match's frames (%d) must be from 0 to %d", replay.Frames,
+			maxMatchReplayFrames)
+	}
+	for i, input := range replay.Inputs {
+		if input.Player >= replay.Players {
+			return nil, fmt.Errorf("input %d is for player %d, but there are only %d", i+1, input.Player+1,
+				replay.Players)
+		}
+	}
+	if replay.Winner >= replay.Players {
+		return nil, fmt.Errorf("the winner is player %d, but there are only %d", replay.Winner+1,
+			replay.Players)
+	}
+	return replay, nil
+}
+
+// Parse a single (non-header) line of a match replay file into the replay.
+func (replay *MatchReplay) parseLine(fields []string) error {
+	var err error
+	switch fields[0] {
+	case "input":
+		if len(fields) != 4 {
+			return fmt.Errorf("expected 'input <frame> <player> <event>'")
+		}
+		var input MatchInput
+		if input.Frame, err = strconv.Atoi(fields[1]); err != nil || input.Frame < 0 {
+			return fmt.Errorf("bad input frame %q", fields[1])
+		}
+		if input.Player, err = strconv.Atoi(fields[2]); err != nil || input.Player < 1 {
+			return fmt.Errorf("bad input player %q", fields[2])
+		}
+		input.Player--
+		var ok bool
+		if input.Event, ok = ParseGameEvent(fields[3]); !ok || !input.Event.IsMatchInput() {
+			return fmt.Errorf("bad input event %q", fields[3])
+		}
+		replay.Inputs = append(replay.Inputs, input)
+		return nil
+	case "options":
+		replay.Options, err = ParseModeOptions(fields[1:])
+		return err
+	case "seed":
+		if len(fields) == 2 {
+			replay.Seed, err = strconv.ParseInt(fields[1], 10, 64)
+		}
+	case "players":
+		if len(fields) == 2 {
+			replay.Players, err = strconv.Atoi(fields[1])
+		}
+	case "frames":
+		if len(fields) == 2 {
+			replay.Frames, err = strconv.Atoi(fields[1])
+		}
+	case "winner":
+		if len(fields) == 2 {
+			replay.Winner, err = strconv.Atoi(fields[1])
+			replay.Winner--
+		}
+	default:
+		return fmt.Errorf("unknown field %q", fields[0])
+	}
+	if len(fields) != 2 || err != nil {
+		return fmt.Errorf("expected '%s <number>'", fields[0])
+	}
+	return nil
+}
+
+// Run the match described by a replay headlessly until the end of the replay, applying each frame's inputs
+// and then advancing the match by a frame, as lockstep does. The inputs for the frame after the last one are
+// applied too: a player who leaves the match is knocked out before the next frame (see Lockstep.Leave). An
+// error is returned if the replay contains inputs that are out of order, or that come after the end of the
+// replay.
+func (replay *MatchReplay) Simulate() (*Match, error) {
+	match := NewMatch(replay.Seed, replay.Players, replay.Options)
+	next := 0
+	for frame := 0; ; frame++ {
+		for ; next < len(replay.Inputs) && replay.Inputs[next].Frame <= frame; next++ {
+			input := replay.Inputs[next]
+			if input.Frame < frame {
+				return nil, fmt.Errorf("input %d (%s by player %d in frame %d) is earlier than the previous "+
+					"input", next+1, input.Event, input.Player+1, input.Frame)
+			}
+			match.Handle(input.Player, input.Event)
+		}
+		if frame == replay.Frames {
+			break
+		}
+		match.Advance(LockstepFrameMillis)
+	}
+	if next < len(replay.Inputs) {
+		input := replay.Inputs[next]
+		return nil, fmt.Errorf("input %d (%s by player %d in frame %d) comes after the end of the replay at "+
+			"frame %d", next+1, input.Event, input.Player+1, input.Frame, replay.Frames)
+	}
+	return match, nil
+}
@@ -0,0 +1,368 @@
+package tetris
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Mode is a set of rules layered on top of the basic game: how the game is set up, when it ends (other than
+// by topping out), and what is shown in the sidebar and on the results screen.
+type Mode interface {
+	// The name used to choose the mode.
+	Name() string
+	Options() ModeOptions
+	// Set up a new game. This is called once, by NewGame.
+	Setup(game *Game)
+	// Called after every millisecond of game time and after every input. The mode can end the game here by
+	// calling game.finish().
+	Update(game *Game)
+	// Short lines of text to show in the sidebar during the game.
+	Status(game *Game) []string
+	// Lines of text for the results screen at the end of the game.
+	Results(game *Game) []string
+	// The game's result for the purpose of keeping personal bests (see Records). ok is false if the game doesn't
+	// count; for instance, because it didn't reach the mode's goal.
+	Result(game *Game) (result Result, ok bool)
+}
+
+// A Result is a mode-specific measure of how well a game went.
+type Result struct {
+	Value int
+	// Whether smaller values are better (e.g. times) rather than larger ones (e.g. scores).
+	LowerIsBetter bool
+	// How to show the value, e.g. "0:43.210".
+	Display string
+}
+
+// Whether this result is better than another one.
+func (result Result) betterThan(other Result) bool {
+	if result.LowerIsBetter {
+		return result.Value < other.Value
+	}
+	return result.Value > other.Value
+}
+
+// ModeOptions are settings for modes. Each mode only uses some of them (or none), except for Stack, Flash,
+// ClearGravity, and Finesse, which work with every mode.
+type ModeOptions struct {
+	// The number of lines to clear.
+	Lines int
+	// The time limit, in milliseconds.
+	TimeLimit int
+	// The starting level.
+	Level int
+	// Whether to keep going until the player tops out, rather than stopping after some number of lines.
+	Endless bool
+	// The percentage chance that each garbage row's hole is in a different column from the row below's. Unlike
+	// the other options, zero doesn't mean the default: it means all of the holes line up.
+	Messiness int
+	// How locked blocks are shown.
+	Stack StackVisibility
+	// Whether blocks are briefly shown as an outline when they disappear (if the stack isn't visible).
+	Flash bool
+	// How blocks fall after rows are cleared.
+	ClearGravity ClearGravity
+	// The name of a puzzle pack (see PuzzlePack), and the number of a puzzle in it, counting from 1.
+	Pack   string
+	Puzzle int
+	// Whether finesse faults are counted, and whether they end the game.
+	Finesse FinesseTracking
+}
+
+// Describe the options which are set, like "lines=40 time=120000" or "level=5 endless".
+func (options ModeOptions) String() string {
+	var parts []string
+	if options.Lines != 0 {
+		parts = append(parts, fmt.Sprintf("lines=%d", options.Lines))
+	}
+	if options.TimeLimit != 0 {
+		parts = append(parts, fmt.Sprintf("time=%d", options.TimeLimit))
+	}
+	if options.Level != 0 {
+		parts = append(parts, fmt.Sprintf("level=%d", options.Level))
+	}
+	if options.Endless {
+		parts = append(parts, "endless")
+	}
+	if options.Messiness != 0 {
+		parts = append(parts, fmt.Sprintf("messiness=%d", options.Messiness))
+	}
+	if options.Stack != StackVisible {
+		parts = append(parts, fmt.Sprintf("stack=%s", options.Stack))
+	}
+	if options.Flash {
+		parts = append(parts, "flash")
+	}
+	if options.ClearGravity != ClearNaive {
+		parts = append(parts, fmt.Sprintf("clear=%s", options.ClearGravity))
+	}
+	if options.Pack != "" {
+		parts = append(parts, fmt.Sprintf("pack=%s", options.Pack))
+	}
+	if options.Puzzle != 0 {
+		parts = append(parts, fmt.Sprintf("puzzle=%d", options.Puzzle))
+	}
+	if options.Finesse != FinesseOff {
+		parts = append(parts, fmt.Sprintf("finesse=%s", options.Finesse))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Parse options in the format produced by ModeOptions.String.
+func ParseModeOptions(fields []string) (ModeOptions, error) {
+	var options ModeOptions
+	for _, field := range fields {
+		switch field {
+		case "endless":
+			options.Endless = true
+			continue
+		case "flash":
+			options.Flash = true
+			continue
+		}
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return options, fmt.Errorf("bad mode option %q", field)
+		}
+		switch parts[0] {
+		case "stack":
+			stack, err := ParseStackVisibility(parts[1])
+			if err != nil {
+				return options, err
+			}
+			options.Stack = stack
+			continue
+		case "clear":
+			gravity, err := ParseClearGravity(parts[1])
+			if err != nil {
+				return options, err
+			}
+			options.ClearGravity = gravity
+			continue
+		case "pack":
+			options.Pack = parts[1]
+			continue
+		case "finesse":
+			tracking, err := ParseFinesseTracking(parts[1])
+			if err != nil {
+				return options, err
+			}
+			options.Finesse = tracking
+			continue
+		}
+		value, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return options, fmt.Errorf("bad mode option %q", field)
+		}
+		switch parts[0] {
+		case "lines":
+			options.Lines = value
+		case "time":
+			options.TimeLimit = value
+		case "level":
+			options.Level = value
+		case "messiness":
+			options.Messiness = value
+		case "puzzle":
+			options.Puzzle = value
+		default:
+			return options, fmt.Errorf("unknown mode option %q", parts[0])
+		}
+	}
+	return options, nil
+}
+
+// The parts of a Mode which are the same for every mode: its name and options, and default implementations
+// of Setup and Update which do nothing.
+type modeBase struct {
+	name    string
+	options ModeOptions
+}
+
+func (mode *modeBase) Name() string         { return mode.name }
+func (mode *modeBase) Options() ModeOptions { return mode.options }
+func (mode *modeBase) Setup(game *Game)     {}
+func (mode *modeBase) Update(game *Game)    {}
+
+// The available modes, by name. Each mode has default values for the numeric options and the Pack option that
+// it uses (the other options are ignored), whether it can be played endlessly, whether it uses the Messiness
+// option, and a function to make a new instance of it.
+var modes = map[string]struct {
+	defaults ModeOptions
+	endless  bool
+	messy    bool
+	new      func(base modeBase) Mode
+}{
+	"classic": {
+		new: func(base modeBase) Mode { return &classicMode{base} },
+	},
+	"sprint": {
+		defaults: ModeOptions{Lines: 40},
+		new:      func(base modeBase) Mode { return &sprintMode{base} },
+	},
+	"ultra": {
+		defaults: ModeOptions{TimeLimit: 3 * 60 * 1000},
+		new:      func(base modeBase) Mode { return &ultraMode{base} },
+	},
+	"marathon": {
+		defaults: ModeOptions{Lines: 150, Level: 1},
+		endless:  true,
+		new:      func(base modeBase) Mode { return &marathonMode{base} },
+	},
+	"cheese": {
+		defaults: ModeOptions{Lines: 10},
+		messy:    true,
+		new:      func(base modeBase) Mode { return &cheeseMode{modeBase: base, hole: -1} },
+	},
+	"survival": {
+		messy: true,
+		new:   func(base modeBase) Mode { return &survivalMode{modeBase: base, hole: -1} },
+	},
+	"master": {
+		new: func(base modeBase) Mode { return &masterMode{modeBase: base} },
+	},
+	"zen": {
+		new: func(base modeBase) Mode { return &zenMode{base} },
+	},
+	"puzzle": {
+		defaults: ModeOptions{Pack: builtinPuzzlePack.Name, Puzzle: 1},
+		new:      newPuzzleMode,
+	},
+	"drill": {
+		defaults: ModeOptions{Pack: builtinDrillPack.Name, Puzzle: 1},
+		new:      newPuzzleMode,
+	},
+}
+
+// Make a new instance of the mode with the given name. Options that are zero take the mode's default values
+// (except for Messiness, Stack, Flash, ClearGravity, and Finesse). Puzzles must be in a known pack (see
+// LoadPuzzlePack).
+func NewMode(name string, options ModeOptions) (Mode, error) {
+	info, ok := modes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mode %q", name)
+	}
+	if options.Lines < 0 || options.TimeLimit < 0 || options.Level < 0 || options.Puzzle < 0 {
+		return nil, fmt.Errorf("mode options must not be negative")
+	}
+	if options.Level > maxGravityLevel {
+		return nil, fmt.Errorf("the level must be at most %d", maxGravityLevel)
+	}
+	if options.Messiness < 0 || options.Messiness > 100 {
+		return nil, fmt.Errorf("the messiness must be a percentage from 0 to 100")
+	}
+	if _, ok := stackVisibilityNames[options.Stack]; !ok {
+		return nil, fmt.Errorf("unknown stack visibility %d", options.Stack)
+	}
+	if _, ok := clearGravityNames[options.ClearGravity]; !ok {
+		return nil, fmt.Errorf("unknown line clear gravity %d", options.ClearGravity)
+	}
+	if _, ok := finesseTrackingNames[options.Finesse]; !ok {
+		return nil, fmt.Errorf("unknown finesse tracking %d", options.Finesse)
+	}
+	// Only keep the options which the mode uses, so that equivalent modes are described the same way in replays
+	// and records.
+	useOption := func(value, defaultValue int) int {
+		if defaultValue == 0 || value == 0 {
+			return defaultValue
+		}
+		return value
+	}
+	normalized := ModeOptions{
+		Lines:        useOption(options.Lines, info.defaults.Lines),
+		TimeLimit:    useOption(options.TimeLimit, info.defaults.TimeLimit),
+		Level:        useOption(options.Level, info.defaults.Level),
+		Puzzle:       useOption(options.Puzzle, info.defaults.Puzzle),
+		Endless:      options.Endless && info.endless,
+		Stack:        options.Stack,
+		Flash:        options.Flash && options.Stack != StackVisible,
+		ClearGravity: options.ClearGravity,
+		Finesse:      options.Finesse,
+	}
+	if info.messy {
+		normalized.Messiness = options.Messiness
+	}
+	if normalized.Endless {
+		normalized.Lines = 0
+	}
+	if info.defaults.Pack != "" {
+		normalized.Pack = info.defaults.Pack
+		if options.Pack != "" {
+			normalized.Pack = options.Pack
+		}
+		pack, ok := puzzlePacks[normalized.Pack]
+		if !ok {
+			return nil, fmt.Errorf("unknown puzzle pack %q", normalized.Pack)
+		}
+		if normalized.Puzzle > len(pack.puzzles) {
+			return nil, fmt.Errorf("puzzle pack %q only has %d puzzles", pack.Name, len(pack.puzzles))
+		}
+	}
+	return info.new(modeBase{name, normalized}), nil
+}
+
+// The names of all of the available modes.
+func ModeNames() []string {
+	var names []string
+	for name := range modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Describe a mode with its options, like "sprint lines=40". This identifies the mode in replays and records.
+func describeMode(mode Mode) string {
+	return strings.TrimSpace(mode.Name() + " " + mode.Options().String())
+}
+
+// Make a new instance of a mode from its description (see describeMode).
+func parseMode(description string) (Mode, error) {
+	fields := strings.Fields(description)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("missing mode name")
+	}
+	options, err := ParseModeOptions(fields[1:])
+	if err != nil {
+		return nil, err
+	}
+	return NewMode(fields[0], options)
+}
+
+// Format a number of milliseconds as a time like "1:23.456".
+func FormatMillis(millis int) string {
+	return fmt.Sprintf("%d:%02d.%03d", millis/60000, millis/1000%60, millis%1000)
+}
+
+// Find the number of pieces placed per second of play.
+func piecesPerSecond(pieces, millis int) string {
+	if millis == 0 {
+		return "0.00"
+	}
+	return fmt.Sprintf("%.2f", float64(pieces)*1000/float64(millis))
+}
+
+// The classic mode is the basic game: it goes on until the player tops out, getting faster as the score
+// increases.
+type classicMode struct {
+	modeBase
+}
+
+func (mode *classicMode) Status(game *Game) []string {
+	return []string{fmt.Sprintf("Lines  %d", game.lines)}
+}
+
+func (mode *classicMode) Results(game *Game) []string {
+	return []string{
+		fmt.Sprintf("Score   %d", game.score),
+		fmt.Sprintf("Lines   %d", game.lines),
+		fmt.Sprintf("Pieces  %d", game.piecesPlaced),
+		fmt.Sprintf("Time    %s", FormatMillis(game.clock)),
+	}
+}
+
+func (mode *classicMode) Result(game *Game) (Result, bool) {
+	return Result{Value: game.score, Display: fmt.Sprint(game.score)}, true
+}
@@ -0,0 +1,541 @@
+package netplay
+
+import (
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The port that games are shown to spectators on (see Broadcast), unless another one is given.
+const SpectatorPort = 4413
+
+// The longest that spectators can be made to wait to see what happens in a game (see NewBroadcast).
+const MaxSpectatorDelay = 10 * time.Second
+
+// How long sending a frame to a spectator can take before they're given up on.
+const spectatorWriteTimeout = 10 * time.Second
+
+// A Broadcast is a Console which shows the game being played on it to spectators as well as to the player.
+// Any number of spectators can connect (see Serve and WatchBroadcast), and they see everything that the
+// player does, including the board, the next piece, and the stats: the broadcast sends them the screen
+// itself, as it's drawn, rather than the inputs.
+//
+// The player's game never waits for its spectators. Each frame is handed to them (after the broadcast's
+// delay, if it has one) by replacing the last one, and each spectator's connection is written to by its own
+// goroutine, as fast as it can take the frames. A spectator who falls behind skips the frames that they've
+// missed, and is sent whatever has changed since the last one they were sent.
+//
+// The protocol starts like a network match's (see netConn), and then the spectator asks to watch. The
+// broadcast sends each frame as the runs of cells in a row which have changed since the spectator's last one
+// (with their position and colors, followed by their characters). The first frame, and any after the screen
+// changes size, is a snapshot which starts with the size of the whole screen and sends every cell of it.
+// Once the game is over, it says goodbye:
+//
+//	watch (the spectator)
+//	screen 90 41
+//	cells 3 5 7 0 ▀▀▀▀ ▀▀
+//	flush
+//	bye
+type Broadcast struct {
+	tetris.Console
+	delay time.Duration
+	// What has been drawn on the console, which only the player's goroutine uses.
+	screen *tetris.Buffer
+
+	mu sync.Mutex
+	// The frames waiting out the delay, oldest first.
+	delayed []broadcastFrame
+	// The frame that spectators are being shown (which isn't changed once it's shown), or nil before the
+	// first one (or while nobody is watching), and the number of frames that have been shown.
+	frame  *tetris.Buffer
+	frames int
+	// The spectators' goroutines, which are woken when there's a new frame (see broadcastSpectator).
+	spectators map[*broadcastSpectator]bool
+	// A channel which is closed once the game is over, and the spectators' goroutines which are still going.
+	done     chan struct{}
+	finished sync.WaitGroup
+}
+
+// A frame of a Broadcast, and when it was drawn.
+type broadcastFrame struct {
+	screen *tetris.Buffer
+	at     time.Time
+}
+
+// Make a Broadcast of what's drawn on a console, which shows it to spectators after a delay (up to
+// MaxSpectatorDelay), or straight away if the delay is 0.
+func NewBroadcast(console tetris.Console, delay time.Duration) (*Broadcast, error) {
+	if delay < 0 || delay > MaxSpectatorDelay {
+		return nil, fmt.Errorf("the delay must be from 0 to %s", MaxSpectatorDelay)
+	}
+	broadcast := &Broadcast{
+		Console:    console,
+		delay:      delay,
+		screen:     tetris.NewBuffer(tetris.ScreenWidth, tetris.ScreenHeight),
+		spectators: make(map[*broadcastSpectator]bool),
+		done:       make(chan struct{}),
+	}
+	if delay > 0 {
+		go broadcast.release()
+	}
+	return broadcast, nil
+}
+
+// Set a cell. The screen shown to spectators grows to fit whatever is drawn.
+func (broadcast *Broadcast) SetCell(x, y int, ch rune, fg, bg tetris.Color) {
+	broadcast.Console.SetCell(x, y, ch, fg, bg)
+	screen := broadcast.screen
+	if x >= screen.Width || y >= screen.Height {
+		width, height := screen.Width, screen.Height
+		if x >= width {
+			width = x + 1
+		}
+		if y >= height {
+			height = y + 1
+		}
+		broadcast.screen = tetris.NewBuffer(width, height)
+		for y := 0; y < screen.Height; y++ {
+			for x := 0; x < screen.Width; x++ {
+				cell := screen.Cell(x, y)
+				broadcast.screen.SetCell(x, y, cell.Ch, cell.Fg, cell.Bg)
+			}
+		}
+	}
+	broadcast.screen.SetCell(x, y, ch, fg, bg)
+}
+
+func (broadcast *Broadcast) Clear(fg, bg tetris.Color) {
+	broadcast.Console.Clear(fg, bg)
+	broadcast.screen.Clear(fg, bg)
+}
+
+// Show everything drawn since the last Flush to the player, and then (once the delay is up) to the
+// spectators.
+func (broadcast *Broadcast) Flush() error {
+	err := broadcast.Console.Flush()
+	broadcast.mu.Lock()
+	defer broadcast.mu.Unlock()
+	if broadcast.delay == 0 && len(broadcast.spectators) == 0 {
+		// Nobody is watching, so the screen isn't copied. Whoever connects next waits for the next frame
+		// rather than being shown an old one.
+		broadcast.frame = nil
+		return err
+	}
+	frame := broadcast.screen.Copy()
+	if broadcast.delay == 0 {
+		broadcast.show(frame)
+	} else {
+		broadcast.delayed = append(broadcast.delayed, broadcastFrame{frame, time.Now()})
+	}
+	return err
+}
+
+// Show the spectators a new frame. The broadcast must be locked.
+func (broadcast *Broadcast) show(frame *tetris.Buffer) {
+	broadcast.frame = frame
+	broadcast.frames++
+	for spectator := range broadcast.spectators {
+		spectator.wake()
+	}
+}
+
+// Show the spectators the frames which have waited out the delay, as time passes, until the game is over.
+func (broadcast *Broadcast) release() {
+	ticker := time.NewTicker(tetris.FrameDelay)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-broadcast.done:
+			return
+		}
+		broadcast.mu.Lock()
+		// Only the latest frame that's due needs to be shown.
+		due := 0
+		for due < len(broadcast.delayed) && time.Since(broadcast.delayed[due].at) >= broadcast.delay {
+			due++
+		}
+		if due > 0 {
+			broadcast.show(broadcast.delayed[due-1].screen)
+			broadcast.delayed = append(broadcast.delayed[:0], broadcast.delayed[due:]...)
+		}
+		broadcast.mu.Unlock()
+	}
+}
+
+// Let spectators who connect to a listener watch the game, until it stops accepting connections.
+func (broadcast *Broadcast) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go broadcast.handle(conn)
+	}
+}
+
+// Greet a spectator, and if they want to watch, send them the game's frames (see broadcastSpectator).
+func (broadcast *Broadcast) handle(conn net.Conn) {
+	c := newNetConn(conn)
+	conn.SetDeadline(time.Now().Add(netHandshakeTimeout))
+	if err := c.exchangeVersions(); err != nil {
+		c.send("error", err)
+		conn.Close()
+		return
+	}
+	fields, err := c.receive()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	if len(fields) != 1 || fields[0] != "watch" {
+		c.send("error", "expected 'watch'")
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	spectator := &broadcastSpectator{
+		broadcast: broadcast,
+		conn:      c,
+		woken:     make(chan struct{}, 1),
+		left:      make(chan struct{}),
+	}
+	broadcast.mu.Lock()
+	broadcast.spectators[spectator] = true
+	broadcast.finished.Add(1)
+	broadcast.mu.Unlock()
+	spectator.wake()
+	go func() {
+		// Spectators don't say anything else, but this notices when they hang up.
+		for {
+			if _, err := c.receive(); err != nil {
+				close(spectator.left)
+				return
+			}
+		}
+	}()
+	spectator.watch()
+}
+
+// Stop showing the game to spectators, now that it's over. They're shown the last frame straight away, even
+// if the broadcast has a delay, and then told that the game is over. It waits a moment for that to be sent
+// to them, but it doesn't stop the listener (see Serve).
+func (broadcast *Broadcast) Close() {
+	broadcast.mu.Lock()
+	select {
+	case <-broadcast.done:
+		broadcast.mu.Unlock()
+		return
+	default:
+	}
+	if n := len(broadcast.delayed); n > 0 {
+		broadcast.show(broadcast.delayed[n-1].screen)
+		broadcast.delayed = nil
+	}
+	close(broadcast.done)
+	for spectator := range broadcast.spectators {
+		spectator.wake()
+	}
+	broadcast.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		broadcast.finished.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+	}
+}
+
+// A broadcastSpectator is somebody watching a Broadcast. Each has a goroutine which sends them the latest
+// frame whenever they're woken, so that a slow spectator only holds themselves up.
+type broadcastSpectator struct {
+	broadcast *Broadcast
+	conn      *netConn
+	// A channel which has a value when there's something new to send, and one which is closed once the
+	// spectator has hung up.
+	woken, left chan struct{}
+	// The last frame sent to the spectator (which only their goroutine uses), and its number.
+	shown  *tetris.Buffer
+	frames int
+}
+
+// Wake the spectator's goroutine, unless it's already been woken.
+func (spectator *broadcastSpectator) wake() {
+	select {
+	case spectator.woken <- struct{}{}:
+	default:
+	}
+}
+
+// Send the spectator the latest frame whenever there's a new one, until they hang up or the game is over.
+func (spectator *broadcastSpectator) watch() {
+	broadcast := spectator.broadcast
+	defer func() {
+		broadcast.mu.Lock()
+		delete(broadcast.spectators, spectator)
+		broadcast.mu.Unlock()
+		spectator.conn.conn.Close()
+		broadcast.finished.Done()
+	}()
+	for {
+		select {
+		case <-spectator.woken:
+		case <-spectator.left:
+			return
+		}
+		broadcast.mu.Lock()
+		frame, frames := broadcast.frame, broadcast.frames
+		broadcast.mu.Unlock()
+		var over bool
+		select {
+		case <-broadcast.done:
+			over = true
+		default:
+		}
+
+		var b strings.Builder
+		if frame != nil && frames != spectator.frames {
+			spectator.writeFrame(&b, frame)
+			spectator.frames = frames
+		}
+		if over {
+			b.WriteString("bye\n")
+		}
+		if b.Len() > 0 {
+			spectator.conn.conn.SetWriteDeadline(time.Now().Add(spectatorWriteTimeout))
+			if _, err := io.WriteString(spectator.conn.conn, b.String()); err != nil {
+				return
+			}
+		}
+		if over {
+			return
+		}
+	}
+}
+
+// Describe a frame for the spectator: the cells which differ from the last frame they were sent (however many
+// frames ago that was), or all of them if it's their first or the screen has changed size.
+func (spectator *broadcastSpectator) writeFrame(b *strings.Builder, frame *tetris.Buffer) {
+	shown := spectator.shown
+	if shown == nil || shown.Width != frame.Width || shown.Height != frame.Height {
+		fmt.Fprintf(b, "screen %d %d\n", frame.Width, frame.Height)
+		shown = tetris.NewBuffer(frame.Width, frame.Height)
+		// None of its cells match the frame's, since they don't even have a character.
+		for y := 0; y < shown.Height; y++ {
+			for x := 0; x < shown.Width; x++ {
+				shown.SetCell(x, y, 0, tetris.ColorDefault, tetris.ColorDefault)
+			}
+		}
+		spectator.shown = shown
+	}
+	// Neighboring cells with the same colors are sent together.
+	var run []rune
+	var runX, runY int
+	var runFg, runBg tetris.Color
+	endRun := func() {
+		if len(run) > 0 {
+			fmt.Fprintf(b, "cells %d %d %d %d %s\n", runX, runY, runFg, runBg, string(run))
+		}
+		run = run[:0]
+	}
+	frame.Update(shown, func(x, y int, cell tetris.Cell) {
+		if y != runY || x != runX+len(run) || cell.Fg != runFg || cell.Bg != runBg {
+			endRun()
+			runX, runY, runFg, runBg = x, y, cell.Fg, cell.Bg
+		}
+		run = append(run, cell.Ch)
+	})
+	endRun()
+	b.WriteString("flush\n")
+}
+
+// A BroadcastViewer is a connection to a Broadcast, for watching somebody else's game.
+type BroadcastViewer struct {
+	conn *netConn
+	// The screen of the game, as the broadcast has sent it so far.
+	screen *tetris.Buffer
+}
+
+// Ask a Broadcast to let this spectator watch its game.
+func WatchBroadcast(conn net.Conn) (*BroadcastViewer, error) {
+	viewer := &BroadcastViewer{conn: newNetConn(conn), screen: tetris.NewBuffer(0, 0)}
+	conn.SetDeadline(time.Now().Add(netHandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+	if err := viewer.conn.exchangeVersions(); err != nil {
+		return nil, err
+	}
+	if err := viewer.conn.send("watch"); err != nil {
+		return nil, err
+	}
+	return viewer, nil
+}
+
+// Wait for the next message from the broadcast. Unlike the other messages in network games, the characters
+// of a run of cells are the rest of the line, spaces and all, so the fields are split up here.
+func (viewer *BroadcastViewer) receive() ([]string, error) {
+	scanner := viewer.conn.scanner
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "cells ") {
+			return strings.SplitN(line, " ", 6), nil
+		}
+		if fields := strings.Fields(line); len(fields) > 0 {
+			return fields, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("the broadcast ended")
+}
+
+// Apply a message from the broadcast to the screen. Returns true if it ended a frame, which is ready to be
+// shown, and io.EOF once the game is over.
+func (viewer *BroadcastViewer) apply(fields []string) (bool, error) {
+	numbers := func(fields []string) ([]int, error) {
+		var ns []int
+		for _, field := range fields {
+			n, err := strconv.Atoi(field)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("bad number %q", field)
+			}
+			ns = append(ns, n)
+		}
+		return ns, nil
+	}
+	switch fields[0] {
+	case "screen":
+		if len(fields) != 3 {
+			return false, fmt.Errorf("expected 'screen <width> <height>'")
+		}
+		size, err := numbers(fields[1:])
+		if err != nil {
+			return false, err
+		}
+		if size[0] > 1000 || size[1] > 1000 {
+			return false, fmt.Errorf("the screen is too big (%dx%d)", size[0], size[1])
+		}
+		viewer.screen = tetris.NewBuffer(size[0], size[1])
+	case "cells":
+		if len(fields) != 6 {
+			return false, fmt.Errorf("expected 'cells <x> <y> <fg> <bg> <characters>'")
+		}
+		n, err := numbers(fields[1:5])
+		if err != nil {
+			return false, err
+		}
+		x := n[0]
+		for _, ch := range fields[5] {
+			viewer.screen.SetCell(x, n[1], ch, tetris.Color(n[2]), tetris.Color(n[3]))
+			x++
+		}
+	case "flush":
+		return true, nil
+	case "bye":
+		return false, io.EOF
+	case "error":
+		return false, fmt.Errorf("the broadcast refused: %s", strings.Join(fields[1:], " "))
+	default:
+		return false, fmt.Errorf("unexpected message %q", fields[0])
+	}
+	return false, nil
+}
+
+// Watch the game on a console until the viewer quits. The game's screen is drawn as the broadcast sends it,
+// with a status line below it.
+func (viewer *BroadcastViewer) Start(console tetris.Console) {
+	defer viewer.conn.conn.Close()
+	events := make(chan tetris.GameEvent, 100)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			event := tetris.UserEvent(console.PollEvent())
+			select {
+			case events <- event:
+			case <-done:
+				return
+			}
+			if event == tetris.Quit {
+				return
+			}
+		}
+	}()
+	// Frames are handed over whole, so that half of one is never drawn.
+	frames := make(chan *tetris.Buffer, 1)
+	var ended error
+	go func() {
+		for {
+			fields, err := viewer.receive()
+			flushed := false
+			if err == nil {
+				flushed, err = viewer.apply(fields)
+			}
+			if err != nil {
+				ended = err
+				close(frames)
+				return
+			}
+			if !flushed {
+				continue
+			}
+			frame := viewer.screen.Copy()
+			select {
+			case <-frames:
+			default:
+			}
+			frames <- frame
+		}
+	}()
+
+	status := "Watching " + viewer.conn.conn.RemoteAddr().String()
+	height := 0
+	draw := func(frame *tetris.Buffer, status string) {
+		console.Clear(tetris.ColorDefault, tetris.ColorDefault)
+		if frame != nil {
+			for y := 0; y < frame.Height; y++ {
+				for x := 0; x < frame.Width; x++ {
+					cell := frame.Cell(x, y)
+					console.SetCell(x, y, cell.Ch, cell.Fg, cell.Bg)
+				}
+			}
+			height = frame.Height
+		}
+		tetris.PrintString(console, 1, height, status+"   (quit with ctrl-c or 'q')")
+		console.Flush()
+	}
+	var last *tetris.Buffer
+	draw(nil, status)
+	for {
+		select {
+		case event := <-events:
+			switch event {
+			case tetris.Quit:
+				viewer.conn.send("bye")
+				return
+			case tetris.Redraw:
+				draw(last, status)
+			}
+		case frame, ok := <-frames:
+			if !ok {
+				frames = nil
+				status = "The game is over"
+				if ended != io.EOF {
+					status = fmt.Sprintf("Connection lost: %s", ended)
+				}
+			} else {
+				last = frame
+			}
+			draw(last, status)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package netplay
+
+import (
+	"github.com/cespare/go-tetris/tetris"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Make a Terminal which nobody reads, whose input comes from a reader.
+func unreadTerminal(input io.Reader) *tetris.Terminal {
+	return tetris.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{input, io.Discard}, 100, 50)
+}
+
+// Start broadcasting to spectators on the loopback interface, returning the broadcast and its address. The
+// console it draws on is a Terminal which nobody reads. It's stopped at the end of the test.
+func loopbackBroadcast(t *testing.T, delay time.Duration) (*Broadcast, string) {
+	input, typing := io.Pipe()
+	t.Cleanup(func() { typing.Close() })
+	broadcast, err := NewBroadcast(unreadTerminal(input), delay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+		broadcast.Close()
+	})
+	go broadcast.Serve(listener)
+	return broadcast, listener.Addr().String()
+}
+
+// Watch a broadcast, waiting until it has started sending to the new spectator.
+func watchLoopback(t *testing.T, broadcast *Broadcast, address string) *BroadcastViewer {
+	broadcast.mu.Lock()
+	watching := len(broadcast.spectators)
+	broadcast.mu.Unlock()
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	viewer, err := WatchBroadcast(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		broadcast.mu.Lock()
+		n := len(broadcast.spectators)
+		broadcast.mu.Unlock()
+		if n > watching {
+			return viewer
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the broadcast never started sending to the spectator")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Read the next frame that a broadcast sends, returning whether it was a snapshot of the whole screen, and
+// false for ok once the broadcast says goodbye.
+func (viewer *BroadcastViewer) readFrame(t *testing.T) (snapshot, ok bool) {
+	for {
+		fields, err := viewer.receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshot = snapshot || fields[0] == "screen"
+		flushed, err := viewer.apply(fields)
+		if err == io.EOF {
+			return snapshot, false
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if flushed {
+			return snapshot, true
+		}
+	}
+}
+
+// Check that a spectator's screen is the same as the one that was broadcast.
+func checkBroadcastScreen(t *testing.T, what string, viewer *BroadcastViewer, broadcast *Broadcast) {
+	t.Helper()
+	got, want := viewer.screen, broadcast.screen
+	if got.Width != want.Width || got.Height != want.Height || got.ANSI() != want.ANSI() {
+		t.Errorf("%s, the spectator sees\n%s\nwant\n%s", what, got.Text(), want.Text())
+	}
+}
+
+// Draw some text on a broadcast, and show it.
+func drawBroadcast(broadcast *Broadcast, x, y int, text string, fg tetris.Color) {
+	for _, ch := range text {
+		broadcast.SetCell(x, y, ch, fg, tetris.ColorDefault)
+		x++
+	}
+	broadcast.Flush()
+}
+
+func TestBroadcast(t *testing.T) {
+	broadcast, address := loopbackBroadcast(t, 0)
+	// Nothing is kept for spectators while there aren't any.
+	drawBroadcast(broadcast, 0, 0, "nobody", tetris.ColorRed)
+	broadcast.mu.Lock()
+	frame := broadcast.frame
+	broadcast.mu.Unlock()
+	if frame != nil {
+		t.Errorf("a frame was kept with nobody watching")
+	}
+
+	viewer := watchLoopback(t, broadcast, address)
+	drawBroadcast(broadcast, 2, 3, "first", tetris.ColorGreen)
+	if snapshot, _ := viewer.readFrame(t); !snapshot {
+		t.Errorf("a new spectator's first frame isn't a snapshot")
+	}
+	checkBroadcastScreen(t, "at first", viewer, broadcast)
+	for i := 0; i < 20; i++ {
+		drawBroadcast(broadcast, i, 10, "▀", tetris.Color(i%8+1))
+		if snapshot, _ := viewer.readFrame(t); snapshot {
+			t.Fatalf("frame %d is a snapshot; want just what changed", i+2)
+		}
+	}
+	checkBroadcastScreen(t, "after 20 frames", viewer, broadcast)
+
+	// The screen shown to spectators grows to fit what's drawn.
+	drawBroadcast(broadcast, 0, tetris.ScreenHeight, "below", tetris.ColorBlue)
+	if snapshot, _ := viewer.readFrame(t); !snapshot {
+		t.Errorf("the frame after the screen grew isn't a snapshot")
+	}
+	checkBroadcastScreen(t, "after the screen grew", viewer, broadcast)
+
+	go broadcast.Close()
+	if _, ok := viewer.readFrame(t); ok {
+		t.Errorf("the broadcast sent another frame when it was closed; want it to say goodbye")
+	}
+}
+
+func TestBroadcastSkippedFrames(t *testing.T) {
+	spectator := &broadcastSpectator{}
+	viewer := &BroadcastViewer{screen: tetris.NewBuffer(0, 0)}
+	send := func(frame *tetris.Buffer) (snapshot bool) {
+		var b strings.Builder
+		spectator.writeFrame(&b, frame)
+		for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+			fields := strings.Fields(line)
+			if fields[0] == "cells" {
+				fields = strings.SplitN(line, " ", 6)
+			}
+			snapshot = snapshot || fields[0] == "screen"
+			if _, err := viewer.apply(fields); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if viewer.screen.ANSI() != frame.ANSI() {
+			t.Fatalf("the spectator sees\n%s\nwant\n%s", viewer.screen.Text(), frame.Text())
+		}
+		return snapshot
+	}
+	frames := make([]*tetris.Buffer, 10)
+	for i := range frames {
+		frames[i] = tetris.NewBuffer(20, 5)
+		for j := 0; j <= i; j++ {
+			frames[i].SetCell(j, j%5, rune('a'+j), tetris.Color(j%8+1), tetris.ColorDefault)
+		}
+	}
+	send(frames[0])
+	// A spectator who misses frames is sent the difference from the last one they saw, not a snapshot.
+	for _, i := range []int{1, 4, 5, 9} {
+		if send(frames[i]) {
+			t.Errorf("frame %d was sent as a snapshot", i)
+		}
+	}
+}
+
+func TestBroadcastDelay(t *testing.T) {
+	terminal := unreadTerminal(strings.NewReader(""))
+	if _, err := NewBroadcast(terminal, MaxSpectatorDelay+time.Second); err == nil {
+		t.Errorf("a broadcast was made with a delay longer than %s", MaxSpectatorDelay)
+	}
+	broadcast, address := loopbackBroadcast(t, 200*time.Millisecond)
+	viewer := watchLoopback(t, broadcast, address)
+	start := time.Now()
+	drawBroadcast(broadcast, 0, 0, "delayed", tetris.ColorRed)
+	viewer.readFrame(t)
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("the spectator saw the frame after %s; want at least 200ms", elapsed)
+	}
+	checkBroadcastScreen(t, "after the delay", viewer, broadcast)
+
+	// Once the game is over, the spectators see how it ended without waiting.
+	drawBroadcast(broadcast, 0, 1, "the end", tetris.ColorBlue)
+	start = time.Now()
+	go broadcast.Close()
+	viewer.readFrame(t)
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf("the last frame was shown after %s; want it straight away", elapsed)
+	}
+	checkBroadcastScreen(t, "at the end", viewer, broadcast)
+	if _, ok := viewer.readFrame(t); ok {
+		t.Errorf("the broadcast didn't say goodbye after the last frame")
+	}
+}
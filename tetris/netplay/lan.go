@@ -0,0 +1,255 @@
+package netplay
+
+import (
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The UDP port that games are announced on to the local network (see AnnounceLAN), unless another one is
+// chosen.
+const LANPort = 4412
+
+const (
+	// How often a game is announced, and how long it stays listed after its last announcement.
+	lanAnnounceInterval = time.Second
+	lanGameTimeout      = 3 * lanAnnounceInterval
+)
+
+// A LANGame is a game announced on the local network: a network match waiting for another player (see
+// HostMatch), or a server (see Server).
+type LANGame struct {
+	// The address to connect to.
+	Addr string
+	// Whether the game is a server, rather than a match.
+	Server bool
+	// The name of the computer it's on.
+	Name string
+	// The number of players connected to it, and the most players that a match (or a server's room) holds.
+	Players, Size int
+	// The ruleset and options that its matches are played with.
+	Ruleset string
+	Options tetris.ModeOptions
+	// An identifier for the announcer, so that a game heard on more than one network interface is only listed
+	// once, and when it was last heard.
+	id   string
+	seen time.Time
+}
+
+// Describe a game for an announcement, which is a line like a network message:
+//
+//	go-tetris lan 3 <id> <port> <match or server> <players> <size> <ruleset> <name> [<option>=<value> ...]
+func (game LANGame) announcement(id string) (string, error) {
+	_, port, err := net.SplitHostPort(game.Addr)
+	if err != nil {
+		return "", err
+	}
+	kind := "match"
+	if game.Server {
+		kind = "server"
+	}
+	message := fmt.Sprintf("go-tetris lan %d %s %s %s %d %d %s %s %s", netProtocolVersion, id, port, kind,
+		game.Players, game.Size, game.Ruleset, game.Name, game.Options)
+	return strings.TrimSpace(message), nil
+}
+
+// Parse an announcement (see LANGame.announcement) heard from an address. Announcements from other
+// versions of go-tetris are ignored, since they can't be joined anyway.
+func parseLANAnnouncement(message string, from *net.UDPAddr) (LANGame, error) {
+	fields := strings.Fields(message)
+	if len(fields) < 10 || fields[0] != "go-tetris" || fields[1] != "lan" {
+		return LANGame{}, fmt.Errorf("not a go-tetris announcement")
+	}
+	if fields[2] != strconv.Itoa(netProtocolVersion) {
+		return LANGame{}, fmt.Errorf("announcement for protocol version %s", fields[2])
+	}
+	game := LANGame{id: fields[3], Server: fields[5] == "server", Ruleset: fields[8], Name: fields[9]}
+	port, err := strconv.Atoi(fields[4])
+	if err != nil || port < 1 || port > 65535 {
+		return LANGame{}, fmt.Errorf("bad port %q", fields[4])
+	}
+	game.Addr = net.JoinHostPort(from.IP.String(), strconv.Itoa(port))
+	if game.Players, err = strconv.Atoi(fields[6]); err != nil {
+		return LANGame{}, fmt.Errorf("bad number of players %q", fields[6])
+	}
+	if game.Size, err = strconv.Atoi(fields[7]); err != nil {
+		return LANGame{}, fmt.Errorf("bad size %q", fields[7])
+	}
+	if game.Options, err = tetris.ParseModeOptions(fields[10:]); err != nil {
+		return LANGame{}, err
+	}
+	return game, nil
+}
+
+// The name that this computer's games are announced with: its host name, cut down to fit in a message (see
+// checkPlayerName).
+func lanName() string {
+	hostname, _ := os.Hostname()
+	hostname = strings.Split(hostname, ".")[0]
+	if len(hostname) > 12 {
+		hostname = hostname[:12]
+	}
+	if checkPlayerName(hostname) != nil {
+		return "go-tetris"
+	}
+	return hostname
+}
+
+// Announce a game on the local network every second, until the returned function is called to stop. The
+// announcements are broadcast on each network interface that supports it, and sent to this computer's own
+// loopback address too, so that games on the same computer are found even without a network. describe is
+// called for every announcement, to get the game's latest details; its Name and Ruleset are filled in if
+// they're empty.
+func AnnounceLAN(lanPort int, describe func() LANGame) (stop func(), err error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	id := strconv.FormatUint(rand.New(rand.NewSource(time.Now().UnixNano())).Uint64(), 36)
+	name := lanName()
+	done := make(chan struct{})
+	go func() {
+		defer conn.Close()
+		ticker := time.NewTicker(lanAnnounceInterval)
+		defer ticker.Stop()
+		for {
+			game := describe()
+			if game.Name == "" {
+				game.Name = name
+			}
+			if game.Ruleset == "" {
+				game.Ruleset = netRuleset
+			}
+			if message, err := game.announcement(id); err == nil {
+				for _, addr := range lanBroadcastAddrs(lanPort) {
+					conn.WriteToUDP([]byte(message), addr)
+				}
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }, nil
+}
+
+// The addresses that announcements are sent to: the loopback address, and the broadcast address of each
+// IPv4 network that this computer is on.
+func lanBroadcastAddrs(lanPort int) []*net.UDPAddr {
+	addrs := []*net.UDPAddr{{IP: net.IPv4(127, 0, 0, 1), Port: lanPort}}
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return addrs
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagBroadcast == 0 {
+			continue
+		}
+		networks, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, network := range networks {
+			ipNet, ok := network.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil || len(ipNet.Mask) != net.IPv4len {
+				continue
+			}
+			broadcast := make(net.IP, net.IPv4len)
+			for i, b := range ipNet.IP.To4() {
+				broadcast[i] = b | ^ipNet.Mask[i]
+			}
+			addrs = append(addrs, &net.UDPAddr{IP: broadcast, Port: lanPort})
+		}
+	}
+	return addrs
+}
+
+// A LANBrowser listens for games announced on the local network (see AnnounceLAN).
+type LANBrowser struct {
+	conn  *net.UDPConn
+	mu    sync.Mutex
+	games map[string]LANGame
+}
+
+// Start listening for games announced on a UDP port. Only one browser on a computer can listen on a port at
+// once.
+func BrowseLAN(lanPort int) (*LANBrowser, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: lanPort})
+	if err != nil {
+		return nil, err
+	}
+	browser := &LANBrowser{conn: conn, games: make(map[string]LANGame)}
+	go browser.listen()
+	return browser, nil
+}
+
+// Record the games heard until the browser is closed.
+func (browser *LANBrowser) listen() {
+	buffer := make([]byte, 1500)
+	for {
+		n, from, err := browser.conn.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		game, err := parseLANAnnouncement(string(buffer[:n]), from)
+		if err != nil {
+			continue
+		}
+		game.seen = time.Now()
+		browser.mu.Lock()
+		// A game heard on several interfaces keeps the first address it was heard on.
+		if old, ok := browser.games[game.id]; ok && time.Since(old.seen) < lanGameTimeout {
+			game.Addr = old.Addr
+		}
+		browser.games[game.id] = game
+		browser.mu.Unlock()
+	}
+}
+
+// The games which have been announced recently, in order of name (and then address).
+func (browser *LANBrowser) Games() []LANGame {
+	browser.mu.Lock()
+	defer browser.mu.Unlock()
+	var games []LANGame
+	for id, game := range browser.games {
+		if time.Since(game.seen) > lanGameTimeout {
+			delete(browser.games, id)
+			continue
+		}
+		games = append(games, game)
+	}
+	sort.Slice(games, func(i, j int) bool {
+		if games[i].Name != games[j].Name {
+			return games[i].Name < games[j].Name
+		}
+		return games[i].Addr < games[j].Addr
+	})
+	return games
+}
+
+// Stop listening.
+func (browser *LANBrowser) Close() error {
+	return browser.conn.Close()
+}
+
+// Describe a game in a few words, for listing it: e.g. "server, 3 players in rooms of 8".
+func (game LANGame) String() string {
+	if game.Server {
+		players := "players"
+		if game.Players == 1 {
+			players = "player"
+		}
+		return fmt.Sprintf("server, %d %s in rooms of %d", game.Players, players, game.Size)
+	}
+	return fmt.Sprintf("match, %d of %d players", game.Players, game.Size)
+}
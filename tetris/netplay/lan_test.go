@@ -0,0 +1,140 @@
+package netplay
+
+import (
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLANAnnouncement(t *testing.T) {
+	from := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 20), Port: 51234}
+	for _, game := range []LANGame{
+		{Addr: "[::]:4411", Name: "desk", Players: 1, Size: 2, Ruleset: netRuleset,
+			Options: tetris.ModeOptions{Stack: tetris.StackFading, Flash: true}},
+		{Addr: ":5000", Server: true, Name: "lab-pc.2", Players: 5, Size: 8, Ruleset: netRuleset},
+	} {
+		message, err := game.announcement("abc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		heard, err := parseLANAnnouncement(message, from)
+		if err != nil {
+			t.Fatalf("parsing %q: %s", message, err)
+		}
+		_, port, _ := net.SplitHostPort(game.Addr)
+		game.Addr = "192.168.1.20:" + port
+		game.id = "abc"
+		if heard != game {
+			t.Errorf("the announcement %q was heard as %+v; want %+v", message, heard, game)
+		}
+	}
+
+	for _, message := range []string{
+		"hello",
+		fmt.Sprintf("go-tetris lan %d abc 4411 match 1 2 guideline", netProtocolVersion),
+		"go-tetris lan 1 abc 4411 match 1 2 guideline desk",
+		fmt.Sprintf("go-tetris lan %d abc port match 1 2 guideline desk", netProtocolVersion),
+		fmt.Sprintf("go-tetris lan %d abc -1 match 1 2 guideline desk", netProtocolVersion),
+		fmt.Sprintf("go-tetris lan %d abc 0 match 1 2 guideline desk", netProtocolVersion),
+		fmt.Sprintf("go-tetris lan %d abc 99999 match 1 2 guideline desk", netProtocolVersion),
+		fmt.Sprintf("go-tetris lan %d abc 4411 match 1 2 guideline desk stack=foggy", netProtocolVersion),
+	} {
+		if game, err := parseLANAnnouncement(message, from); err == nil {
+			t.Errorf("the announcement %q was heard as %+v; want an error", message, game)
+		}
+	}
+}
+
+// A UDP port on the loopback interface which nothing is listening on.
+func freeUDPPort(t *testing.T) int {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestBrowseLAN(t *testing.T) {
+	port := freeUDPPort(t)
+	browser, err := BrowseLAN(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer browser.Close()
+	games := []LANGame{
+		{Addr: "0.0.0.0:4411", Players: 1, Size: 2,
+			Options: tetris.ModeOptions{ClearGravity: tetris.ClearSticky}},
+		{Addr: "0.0.0.0:5000", Server: true, Name: "arcade", Players: 3, Size: 8},
+	}
+	var stops []func()
+	for _, game := range games {
+		game := game
+		stop, err := AnnounceLAN(port, func() LANGame { return game })
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stop()
+		stops = append(stops, stop)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(browser.Games()) < len(games) {
+		if time.Now().After(deadline) {
+			t.Fatalf("only heard %+v", browser.Games())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	heard := make(map[string]LANGame)
+	for _, game := range browser.Games() {
+		_, port, _ := net.SplitHostPort(game.Addr)
+		heard[port] = game
+	}
+	if match := heard["4411"]; match.Server || match.Name != lanName() || match.Ruleset != netRuleset ||
+		match.Options.ClearGravity != tetris.ClearSticky || match.String() != "match, 1 of 2 players" {
+		t.Errorf("the match was heard as %+v (%s)", match, match)
+	}
+	if server := heard["5000"]; !server.Server || server.Name != "arcade" ||
+		server.String() != "server, 3 players in rooms of 8" {
+		t.Errorf("the server was heard as %+v (%s)", server, server)
+	}
+
+	// Games which haven't been heard from for a while are forgotten.
+	stops[1]()
+	time.Sleep(50 * time.Millisecond)
+	browser.mu.Lock()
+	for id, game := range browser.games {
+		if game.Server {
+			game.seen = time.Now().Add(-2 * lanGameTimeout)
+			browser.games[id] = game
+		}
+	}
+	browser.mu.Unlock()
+	if games := browser.Games(); len(games) != 1 || games[0].Server {
+		t.Errorf("after the server went quiet, the games are %+v; want just the match", games)
+	}
+}
+
+func TestDrawLANSelect(t *testing.T) {
+	b := tetris.NewBuffer(tetris.ScreenWidth, tetris.ScreenHeight)
+	drawLANSelect(b, nil, 0)
+	if text := b.Text(); !strings.Contains(text, "Looking for games") {
+		t.Errorf("the empty list doesn't say that it's looking for games:\n%s", text)
+	}
+	games := []LANGame{
+		{Addr: "10.0.0.2:4411", Name: "desk", Players: 1, Size: 2, Ruleset: netRuleset},
+		{Addr: "10.0.0.3:4411", Server: true, Name: "lab", Players: 1, Size: 4, Ruleset: netRuleset,
+			Options: tetris.ModeOptions{Stack: tetris.StackInvisible}},
+	}
+	drawLANSelect(b, games, 1)
+	text := b.Text()
+	for _, want := range []string{"  desk         match, 1 of 2 players", "> lab          server, 1 player",
+		"10.0.0.3:4411", "stack=invisible"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("the list doesn't show %q:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package netplay
+
+import (
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"time"
+)
+
+// SelectLANGame shows the "Join LAN game" screen, listing the games that a browser hears announced on the
+// local network as they come and go. The player picks one with the arrow keys (or 'j' and 'k') and enter or
+// space, and it's returned. If the player quits instead, ok is false.
+func SelectLANGame(console tetris.Console, browser *LANBrowser) (game LANGame, ok bool, err error) {
+	// The list is redrawn every so often, so that new games show up while waiting for a key.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				console.Interrupt()
+			}
+		}
+	}()
+	selected := 0
+	for {
+		games := browser.Games()
+		if selected >= len(games) {
+			selected = len(games) - 1
+		}
+		if selected < 0 {
+			selected = 0
+		}
+		drawLANSelect(console, games, selected)
+		switch event := console.PollEvent(); {
+		case event.Type == tetris.EventError:
+			return LANGame{}, false, event.Err
+		case event.Type != tetris.EventKey:
+		case event.Key == tetris.KeyArrowUp || event.Ch == 'k':
+			if selected > 0 {
+				selected--
+			}
+		case event.Key == tetris.KeyArrowDown || event.Ch == 'j':
+			if selected < len(games)-1 {
+				selected++
+			}
+		case event.Key == tetris.KeyEnter || event.Key == tetris.KeySpace:
+			if len(games) > 0 {
+				return games[selected], true, nil
+			}
+		case event.Key == tetris.KeyCtrlC || event.Ch == 'q':
+			return LANGame{}, false, nil
+		}
+	}
+}
+
+// Draw the "Join LAN game" screen (see SelectLANGame) with the game at the given index selected.
+func drawLANSelect(screen tetris.Screen, games []LANGame, selected int) {
+	items := make([]string, len(games))
+	for i, game := range games {
+		items[i] = fmt.Sprintf("%-12s %s", game.Name, game)
+	}
+	var details []string
+	if len(games) == 0 {
+		// Nothing can be selected, so the list just says what's happening.
+		items = []string{"Looking for games on the local network..."}
+		selected = -1
+	} else {
+		game := games[selected]
+		options := game.Options.String()
+		if options == "" {
+			options = "(defaults)"
+		}
+		details = []string{
+			"Address  " + game.Addr,
+			"Rules    " + game.Ruleset,
+			"Options  " + options,
+			"",
+		}
+	}
+	details = append(details,
+		"Choose with up/down or 'k'/'j',",
+		"and join with enter or space.",
+		"Quit with ctrl-c or 'q'.",
+	)
+	tetris.DrawMenu(screen, "Join LAN game", items, selected, details)
+}
@@ -0,0 +1,245 @@
+package netplay
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The version of the network protocol. Players can only play each other if their versions of go-tetris speak
+// the same version.
+const netProtocolVersion = 3
+
+// The ruleset that network matches are played with: the guideline ruleset and attack table (see
+// tetris.Match).
+const netRuleset = "guideline"
+
+// How long the other player has to answer during the handshake.
+const netHandshakeTimeout = 10 * time.Second
+
+// A netConn is a connection to the other player in a network match. The protocol is line-based text, like
+// replays. Each player starts by sending its version, and then the host describes the match and the other
+// player accepts it (or rejects it, with a reason):
+//
+//	go-tetris net 3 (the protocol version, netProtocolVersion)
+//	match 1234 guideline stack=fading (host: the seed, ruleset, and mode options)
+//	ready (or "reject <reason>")
+//
+// During the match, each player sends their inputs for every frame (see tetris.Lockstep), even if there
+// aren't any, and hashes of the state of the match every so often:
+//
+//	inputs 12 left left drop
+//	inputs 13
+//	hash 60 8c2f1e4d9a0b7c36
+//	bye (the sender quit)
+type netConn struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+func newNetConn(conn net.Conn) *netConn {
+	return &netConn{conn: conn, scanner: bufio.NewScanner(conn)}
+}
+
+// Send a message: a line made of the fields, separated by spaces.
+func (c *netConn) send(fields ...interface{}) error {
+	_, err := fmt.Fprintln(c.conn, fields...)
+	return err
+}
+
+// Wait for the next message from the other player, returning its fields. Blank lines are skipped.
+func (c *netConn) receive() ([]string, error) {
+	for c.scanner.Scan() {
+		if fields := strings.Fields(c.scanner.Text()); len(fields) > 0 {
+			return fields, nil
+		}
+	}
+	if err := c.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("the other player disconnected")
+}
+
+// Send this side's protocol version and check that the other player speaks the same one.
+func (c *netConn) exchangeVersions() error {
+	if err := c.send("go-tetris net", netProtocolVersion); err != nil {
+		return err
+	}
+	fields, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if len(fields) != 3 || fields[0] != "go-tetris" || fields[1] != "net" {
+		return fmt.Errorf("the other side isn't go-tetris")
+	}
+	if version, err := strconv.Atoi(fields[2]); err != nil || version != netProtocolVersion {
+		return fmt.Errorf("the other player's go-tetris speaks protocol version %s (this one speaks %d)",
+			fields[2], netProtocolVersion)
+	}
+	return nil
+}
+
+// A NetMatch is a versus match against a player on another computer. Both players simulate the whole match,
+// and only send each other their inputs (see tetris.Lockstep).
+type NetMatch struct {
+	conn *netConn
+	step *tetris.Lockstep
+}
+
+// Start a network match as the host, on a connection from the other player. The host chooses the seed (so
+// both players get the same pieces) and the options.
+func HostMatch(conn net.Conn, seed int64, options tetris.ModeOptions) (*NetMatch, error) {
+	c := newNetConn(conn)
+	conn.SetDeadline(time.Now().Add(netHandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+	if err := c.exchangeVersions(); err != nil {
+		return nil, err
+	}
+	description := strings.TrimSpace(fmt.Sprintf("match %d %s %s", seed, netRuleset, options))
+	if err := c.send(description); err != nil {
+		return nil, err
+	}
+	fields, err := c.receive()
+	if err != nil {
+		return nil, err
+	}
+	switch fields[0] {
+	case "ready":
+		return newNetMatch(c, 0, seed, options), nil
+	case "reject":
+		return nil, fmt.Errorf("the other player rejected the match: %s", strings.Join(fields[1:], " "))
+	}
+	return nil, fmt.Errorf("unexpected message %q", fields[0])
+}
+
+// Join a network match on a connection to the host, agreeing to the seed and options that it chooses.
+func JoinMatch(conn net.Conn) (*NetMatch, error) {
+	c := newNetConn(conn)
+	conn.SetDeadline(time.Now().Add(netHandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+	if err := c.exchangeVersions(); err != nil {
+		return nil, err
+	}
+	fields, err := c.receive()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) < 3 || fields[0] != "match" {
+		return nil, fmt.Errorf("expected 'match <seed> <ruleset> [<option>=<value> ...]'")
+	}
+	reject := func(err error) (*NetMatch, error) {
+		c.send("reject", err)
+		return nil, err
+	}
+	seed, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return reject(fmt.Errorf("bad seed %q", fields[1]))
+	}
+	if fields[2] != netRuleset {
+		return reject(fmt.Errorf("unknown ruleset %q", fields[2]))
+	}
+	options, err := tetris.ParseModeOptions(fields[3:])
+	if err != nil {
+		return reject(err)
+	}
+	if err := c.send("ready"); err != nil {
+		return nil, err
+	}
+	return newNetMatch(c, 1, seed, options), nil
+}
+
+// Set up a network match, once the players have agreed on it. player is 0 for the host and 1 for the other
+// player.
+func newNetMatch(c *netConn, player int, seed int64, options tetris.ModeOptions) *NetMatch {
+	return &NetMatch{conn: c, step: tetris.NewLockstep(tetris.NewMatch(seed, 2, options), player)}
+}
+
+// Run the match up to a frame (or as far towards it as the other player's inputs allow), sending this
+// player's inputs and hashes to the other player as they're due.
+func (match *NetMatch) advanceTo(frame int) error {
+	step := match.step
+	for step.Frame() < frame && !step.Match().Over() {
+		for step.ScheduleDue() {
+			scheduled, events := step.Schedule()
+			fields := []interface{}{"inputs", scheduled}
+			for _, event := range events {
+				fields = append(fields, event)
+			}
+			if err := match.conn.send(fields...); err != nil {
+				return err
+			}
+		}
+		simulated := step.Frame()
+		if !step.Simulate() {
+			// Wait for the other player's inputs.
+			return nil
+		}
+		if hash, ok := step.Hash(simulated); ok {
+			if err := match.conn.send("hash", simulated, fmt.Sprintf("%016x", hash)); err != nil {
+				return err
+			}
+			step.Check(simulated)
+		}
+	}
+	return nil
+}
+
+// Apply a message from the other player.
+func (match *NetMatch) receive(fields []string) error {
+	switch fields[0] {
+	case "inputs":
+		if len(fields) < 2 {
+			return fmt.Errorf("expected 'inputs <frame> [<event> ...]'")
+		}
+		frame, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("bad frame %q", fields[1])
+		}
+		var events []tetris.GameEvent
+		for _, name := range fields[2:] {
+			event, ok := tetris.ParseGameEvent(name)
+			if !ok || !event.IsGameplay() {
+				return fmt.Errorf("bad input event %q", name)
+			}
+			events = append(events, event)
+		}
+		return match.step.ReceiveInputs(1-match.step.Player(), frame, events)
+	case "hash":
+		if len(fields) != 3 {
+			return fmt.Errorf("expected 'hash <frame> <hash>'")
+		}
+		frame, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("bad frame %q", fields[1])
+		}
+		hash, err := strconv.ParseUint(fields[2], 16, 64)
+		if err != nil {
+			return fmt.Errorf("bad hash %q", fields[2])
+		}
+		match.step.ReceiveHash(frame, hash)
+	case "bye":
+		match.forfeit(1-match.step.Player(), "The other player left")
+	default:
+		return fmt.Errorf("unexpected message %q", fields[0])
+	}
+	return nil
+}
+
+// End the match because the other player left or the connection was lost (which the message explains), with
+// this player as the winner.
+func (match *NetMatch) forfeit(loser int, message string) {
+	if match.step.Match().Over() {
+		return
+	}
+	match.step.Leave(loser)
+	match.step.Match().Game(match.step.Player()).SetMessage(message)
+}
+
+// A replay of the match so far, which can be saved once it's over.
+func (match *NetMatch) Replay() *tetris.MatchReplay {
+	return match.step.Replay()
+}
@@ -0,0 +1,213 @@
+package netplay
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Make a pair of connected TCP connections over the loopback interface.
+func loopback(t *testing.T) (net.Conn, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			conn = nil
+		}
+		accepted <- conn
+	}()
+	dialed, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := <-accepted
+	if conn == nil {
+		t.Fatal("couldn't accept a loopback connection")
+	}
+	t.Cleanup(func() {
+		dialed.Close()
+		conn.Close()
+	})
+	return conn, dialed
+}
+
+// Set up a network match over the loopback interface, returning the host's side and the other player's.
+func netPair(t *testing.T, seed int64, options tetris.ModeOptions) (*NetMatch, *NetMatch) {
+	hostConn, guestConn := loopback(t)
+	type result struct {
+		match *NetMatch
+		err   error
+	}
+	hosted := make(chan result)
+	go func() {
+		match, err := HostMatch(hostConn, seed, options)
+		hosted <- result{match, err}
+	}()
+	guest, err := JoinMatch(guestConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := <-hosted
+	if host.err != nil {
+		t.Fatal(host.err)
+	}
+	return host.match, guest
+}
+
+// Receive messages for a side of a network match in the background.
+func netMessages(match *NetMatch) <-chan []string {
+	messages := make(chan []string, 1000)
+	go func() {
+		defer close(messages)
+		for {
+			fields, err := match.conn.receive()
+			if err != nil {
+				return
+			}
+			messages <- fields
+		}
+	}()
+	return messages
+}
+
+// Play a network match for up to some number of frames, or until it's over or a side notices a desync. Before
+// each frame, inputs calls for each player's inputs for that frame. Both sides run in step on this goroutine:
+// each frame, they wait for each other's inputs for it.
+func playNet(t *testing.T, host, guest *NetMatch, frames int, inputs func(frame int)) {
+	sides := []*NetMatch{host, guest}
+	messages := []<-chan []string{netMessages(host), netMessages(guest)}
+	behind := func(side *NetMatch, frame int) bool {
+		return side.step.Frame() < frame && !side.step.Match().Over()
+	}
+	for frame := 1; frame <= frames && !host.step.Match().Over() && host.step.Desync() == "" &&
+		guest.step.Desync() == ""; frame++ {
+		inputs(frame)
+		deadline := time.Now().Add(5 * time.Second)
+		for behind(host, frame) || behind(guest, frame) {
+			for i, side := range sides {
+				if err := side.advanceTo(frame); err != nil {
+					t.Fatal(err)
+				}
+				select {
+				case fields, ok := <-messages[i]:
+					if !ok {
+						t.Fatalf("frame %d: the connection closed", frame)
+					}
+					if err := side.receive(fields); err != nil {
+						t.Fatal(err)
+					}
+				case <-time.After(time.Millisecond):
+				}
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("frame %d: the sides are stuck at frames %d and %d", frame, host.step.Frame(),
+					guest.step.Frame())
+			}
+		}
+	}
+}
+
+// Inputs for both players to drop pieces straight down, the other player four times as often as the host (so
+// that they top out first).
+func droppers(host, guest *NetMatch) func(frame int) {
+	return func(frame int) {
+		if frame%40 == 0 {
+			host.step.Input(tetris.QuickDrop)
+		}
+		if frame%10 == 0 {
+			guest.step.Input(tetris.QuickDrop)
+		}
+	}
+}
+
+func TestNetMatch(t *testing.T) {
+	host, guest := netPair(t, 42, tetris.ModeOptions{Stack: tetris.StackFading})
+	if options := guest.Replay().Options; options.Stack != tetris.StackFading {
+		t.Fatalf("the other player got the options %v; want stack=fading", options)
+	}
+	playNet(t, host, guest, 20000, droppers(host, guest))
+	if desync := host.step.Desync() + guest.step.Desync(); desync != "" {
+		t.Fatalf("the sides' simulations disagreed:\n%s", desync)
+	}
+	if !host.step.Match().Over() || !guest.step.Match().Over() {
+		t.Fatalf("the match isn't over after %d frames", host.step.Frame())
+	}
+	if host.step.Match().Winner() != 0 || guest.step.Match().Winner() != 0 {
+		t.Errorf("the host sees player %d win, and the other player sees player %d; want 0",
+			host.step.Match().Winner(), guest.step.Match().Winner())
+	}
+	if !reflect.DeepEqual(host.Replay(), guest.Replay()) {
+		t.Errorf("the sides recorded different replays")
+	}
+}
+
+func TestNetBye(t *testing.T) {
+	host, guest := netPair(t, 42, tetris.ModeOptions{})
+	guest.conn.send("bye")
+	fields, err := host.conn.receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := host.receive(fields); err != nil {
+		t.Fatal(err)
+	}
+	if match := host.step.Match(); !match.Over() || match.Winner() != 0 {
+		t.Fatalf("the host didn't win when the other player left")
+	}
+	inputs := host.Replay().Inputs
+	if last := inputs[len(inputs)-1]; last.Player != 1 || last.Event != tetris.Forfeit {
+		t.Errorf("the last input recorded is %+v; want the other player's forfeit", last)
+	}
+	screen := tetris.NewBuffer(tetris.ScreenWidth, tetris.ScreenHeight)
+	host.step.Match().Game(0).DrawDynamic(screen, false)
+	if text := screen.Text(); !strings.Contains(text, "The other player left") {
+		t.Errorf("the host wasn't told that the other player left:\n%s", text)
+	}
+}
+
+func TestNetHandshakeErrors(t *testing.T) {
+	for _, test := range []struct {
+		// The messages sent by the host (after reading the other player's version), and the error the other
+		// player should give.
+		messages []string
+		want     string
+	}{
+		{[]string{"go-tetris net 2"}, "protocol version 2"},
+		{[]string{"hello"}, "isn't go-tetris"},
+		{[]string{fmt.Sprintf("go-tetris net %d", netProtocolVersion), "match 1 classic"}, "unknown ruleset"},
+		{[]string{fmt.Sprintf("go-tetris net %d", netProtocolVersion), "match 1 guideline speed=9"},
+			"speed"},
+	} {
+		hostConn, guestConn := loopback(t)
+		rejected := make(chan string, 1)
+		go func() {
+			scanner := bufio.NewScanner(hostConn)
+			scanner.Scan()
+			for _, message := range test.messages {
+				fmt.Fprintln(hostConn, message)
+			}
+			scanner.Scan()
+			rejected <- scanner.Text()
+		}()
+		_, err := JoinMatch(guestConn)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("joining a host which sent %q gave the error %v; want one about %q", test.messages, err,
+				test.want)
+		}
+		if len(test.messages) > 1 {
+			if reply := <-rejected; !strings.HasPrefix(reply, "reject ") {
+				t.Errorf("the other player replied to %q with %q; want a rejection", test.messages, reply)
+			}
+		}
+	}
+}
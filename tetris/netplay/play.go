@@ -0,0 +1,372 @@
+package netplay
+
+import (
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"os"
+	"strings"
+	"time"
+)
+
+// The controls listed below this player's board in a network match.
+var netControls = []string{"Controls:",
+	"",
+	"Move left       left arrow or 'h'",
+	"Move right      right arrow or 'l'",
+	"Move down       down arrow or 'j'",
+	"Rotate piece    up arrow or 'k'",
+	"Quick drop      space",
+	"",
+	"Quit            ctrl-c or 'q'",
+}
+
+// A message from the other player in a network match, or the error that ended the connection.
+type netMessage struct {
+	fields []string
+	err    error
+}
+
+// Start running the network match, with this player's game beside the other player's, until the player
+// quits. The connection is closed at the end. If the players' simulations of the match disagree, the match
+// is abandoned and a diagnostic dump is saved (see saveDesync).
+func (match *NetMatch) Start(console tetris.Console) {
+	defer match.conn.conn.Close()
+	step := match.step
+	me := step.Player()
+
+	events := make(chan tetris.GameEvent, 100)
+	go func() {
+		for {
+			event := tetris.UserEvent(console.PollEvent())
+			events <- event
+			if event == tetris.Quit {
+				return
+			}
+		}
+	}()
+	messages := make(chan netMessage, 100)
+	go func() {
+		for {
+			fields, err := match.conn.receive()
+			messages <- netMessage{fields, err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	// This player's game is on the left.
+	panel := func(player int) tetris.Screen {
+		if player == me {
+			return console
+		}
+		return tetris.OffsetScreen(console, tetris.ScreenWidth, 0)
+	}
+	instructions := [2][]string{}
+	instructions[me] = netControls
+	instructions[1-me] = []string{"Playing against:", "", match.conn.conn.RemoteAddr().String()}
+	drawStatic := func() {
+		for player := 0; player < 2; player++ {
+			tetris.DrawStaticParts(panel(player), instructions[player])
+		}
+	}
+	draw := func() {
+		for player := 0; player < 2; player++ {
+			step.Match().Game(player).DrawDynamic(panel(player), false)
+		}
+	}
+	drawStatic()
+	draw()
+
+	ticker := time.NewTicker(tetris.FrameDelay)
+	defer ticker.Stop()
+
+	// The match can't be paused. It runs up to the frame for the wall time since it started, as far as the
+	// other player's inputs allow.
+	start := time.Now()
+	lostConnection := func(err error) {
+		match.forfeit(1-me, fmt.Sprintf("Connection lost: %s", err))
+	}
+	for !step.Match().Over() {
+		select {
+		case event := <-events:
+			switch event {
+			case tetris.Quit:
+				match.conn.send("bye")
+				return
+			case tetris.Redraw:
+				drawStatic()
+			case tetris.MoveLeft, tetris.MoveRight, tetris.MoveDown, tetris.Rotate, tetris.QuickDrop:
+				step.Input(event)
+			}
+		case message := <-messages:
+			err := message.err
+			if err == nil {
+				err = match.receive(message.fields)
+			}
+			if err != nil {
+				lostConnection(err)
+			}
+		case <-ticker.C:
+		}
+		frame := int(time.Since(start) / (tetris.LockstepFrameMillis * time.Millisecond))
+		if err := match.advanceTo(frame); err != nil {
+			lostConnection(err)
+		}
+		if desync := step.Desync(); desync != "" {
+			step.Match().Abandon()
+			step.Match().Game(me).SetMessage(saveDesync(desync))
+		}
+		draw()
+	}
+	draw()
+	for player := 0; player < 2; player++ {
+		step.Match().Game(player).DrawGameOver(panel(player))
+	}
+	for event := range events {
+		if event == tetris.Quit {
+			return
+		}
+	}
+}
+
+// Save the diagnostic dump for a desync (see tetris.Lockstep.Desync) to a file, after the protocol version,
+// returning a message saying how that went.
+func saveDesync(desync string) string {
+	filename := fmt.Sprintf("go-tetris-desync-%d.txt", time.Now().Unix())
+	dump := fmt.Sprintf("go-tetris net %d\n%s", netProtocolVersion, desync)
+	if err := os.WriteFile(filename, []byte(dump), 0644); err != nil {
+		return fmt.Sprintf("Desync! Error saving details: %s", err)
+	}
+	return fmt.Sprintf("Desync! Details saved to %s", filename)
+}
+
+// The controls listed below this player's board in a match on a server.
+var serverControls = []string{"Controls:",
+	"",
+	"Move left       left arrow or 'h'",
+	"Move right      right arrow or 'l'",
+	"Move down       down arrow or 'j'",
+	"Rotate piece    up arrow or 'k'",
+	"Quick drop      space",
+	"Change target   't'",
+	"",
+	"Rematch         'r' (at the end)",
+	"Quit            ctrl-c or 'q'",
+}
+
+// Start playing in (or watching) the matches in the server's room until the player quits. The connection is
+// closed at the end. This player's game is on the left, with miniatures of everybody else's beside it (and
+// spectators see miniatures of every game). Before the first match, the room's lobby is shown instead.
+func (client *ServerClient) Start(console tetris.Console) {
+	defer client.conn.conn.Close()
+
+	events := make(chan tetris.GameEvent, 100)
+	go func() {
+		for {
+			event := tetris.UserEvent(console.PollEvent())
+			events <- event
+			if event == tetris.Quit {
+				return
+			}
+		}
+	}()
+	messages := make(chan netMessage, 100)
+	go func() {
+		for {
+			fields, err := client.conn.receive()
+			messages <- netMessage{fields, err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	ticker := time.NewTicker(tetris.FrameDelay)
+	defer ticker.Stop()
+
+	// Like a network match, a match runs up to the frame for the wall time since it started (as far as the
+	// server's frames allow), except that spectators run it as far as they can.
+	var started time.Time
+	// The error that ended the connection, if it has ended.
+	var lost error
+	disconnect := func(err error) {
+		lost = err
+		if client.playing() {
+			client.step.Match().Abandon()
+			if player := client.step.Player(); player >= 0 {
+				client.step.Match().Game(player).SetMessage(fmt.Sprintf("Connection lost: %s", err))
+			}
+		}
+	}
+	redraw := true
+	for {
+		if redraw {
+			console.Clear(tetris.ColorDefault, tetris.BackgroundColor)
+		}
+		if client.step == nil {
+			if redraw {
+				client.drawLobby(console, lost)
+			}
+		} else {
+			client.draw(console, redraw, lost)
+		}
+		redraw = false
+
+		select {
+		case event := <-events:
+			switch {
+			case event == tetris.Quit:
+				client.conn.send("bye")
+				return
+			case event == tetris.Redraw:
+				redraw = true
+			case event == tetris.Retry:
+				if client.step != nil && !client.playing() && client.name != "" && lost == nil {
+					client.rematch()
+				}
+			case event.IsMatchInput() && event != tetris.Forfeit:
+				if client.playing() && client.step.Player() >= 0 {
+					client.step.Input(event)
+				}
+			}
+		case message := <-messages:
+			err := message.err
+			if err == nil {
+				var newMatch bool
+				if newMatch, err = client.receive(message.fields); newMatch {
+					started = time.Now()
+					redraw = true
+				}
+			}
+			if err != nil {
+				disconnect(err)
+			}
+			// Between matches, the screen only changes with the server's messages.
+			redraw = redraw || !client.playing()
+		case <-ticker.C:
+		}
+		if !client.playing() {
+			continue
+		}
+		match, player := client.step.Match(), client.step.Player()
+		wasOver := player >= 0 && match.Game(player).Over()
+		frame := int(time.Since(started) / (tetris.LockstepFrameMillis * time.Millisecond))
+		if player < 0 {
+			frame = int(^uint(0) >> 1)
+		}
+		if err := client.advanceTo(frame); err != nil {
+			disconnect(err)
+		}
+		if desync := client.step.Desync(); desync != "" {
+			match.Abandon()
+			if player >= 0 {
+				match.Game(player).SetMessage(saveDesync(desync))
+			}
+			lost = fmt.Errorf("the simulations disagreed")
+		}
+		// The results are drawn from scratch when this player is knocked out and when the match ends.
+		if !client.playing() || player >= 0 && match.Game(player).Over() != wasOver {
+			redraw = true
+		}
+	}
+}
+
+// Describe the room's lobby, and how the connection ended if it has.
+func (client *ServerClient) lobbyStatus(lost error) []string {
+	if lost != nil {
+		return []string{fmt.Sprintf("Disconnected: %s", lost)}
+	}
+	status := []string{fmt.Sprintf("Room %d: %d of %d players", client.room, len(client.lobby), client.size)}
+	waiting := false
+	for _, name := range client.lobby {
+		waiting = waiting || !strings.HasSuffix(name, "*")
+	}
+	switch {
+	case client.countdown >= 0:
+		status = append(status, fmt.Sprintf("Starting in %ds", client.countdown))
+	case waiting:
+		status = append(status, "Waiting for everybody to be ready")
+	default:
+		status = append(status, "Waiting for more players")
+	}
+	// List the players (with "*" after those who are ready) a few to a line.
+	line := ""
+	for _, name := range client.lobby {
+		if line != "" && len(line)+len(name) >= tetris.ScreenWidth-10 {
+			status = append(status, line)
+			line = ""
+		}
+		line += name + " "
+	}
+	return append(status, line)
+}
+
+// Draw the room's lobby, before the first match.
+func (client *ServerClient) drawLobby(screen tetris.Screen, lost error) {
+	instructions := append(client.lobbyStatus(lost), "", "Quit            ctrl-c or 'q'")
+	tetris.DrawStaticParts(screen, instructions)
+	screen.Flush()
+}
+
+// Draw the match: this player's game in full, and everybody else's as miniatures beside it (or everybody's,
+// for a spectator). If static is true, the whole screen is drawn, including the results of games which are
+// over.
+func (client *ServerClient) draw(screen tetris.Screen, static bool, lost error) {
+	match, me := client.step.Match(), client.step.Player()
+	x := 0
+	if me >= 0 {
+		game := match.Game(me)
+		if static {
+			tetris.DrawStaticParts(screen, serverControls)
+		}
+		if static || !game.Over() {
+			game.DrawDynamic(screen, false)
+		}
+		if static && game.Over() {
+			notes := []string{"", "Waiting for the match to end"}
+			if match.Over() {
+				notes = append([]string{""}, client.lobbyStatus(lost)...)
+				if lost == nil {
+					notes = append(notes, "", "Press 'r' for a rematch")
+				}
+			}
+			game.DrawGameOver(screen, notes...)
+		}
+		x = tetris.ScreenWidth
+	}
+
+	// The miniatures go in columns, as many to a column as fit on the screen.
+	perColumn := tetris.ScreenHeight / (tetris.MiniBoardHeight + 1)
+	i := 0
+	for player := 0; player < match.Players(); player++ {
+		if player == me {
+			continue
+		}
+		label := client.names[player]
+		if place := match.Place(player); place > 0 {
+			label = fmt.Sprintf("#%d %s", place, label)
+		}
+		targeted := me >= 0 && match.Target(me) == player
+		match.Game(player).DrawMiniBoard(screen, x+i/perColumn*(tetris.MiniBoardWidth+1),
+			i%perColumn*(tetris.MiniBoardHeight+1), label, targeted)
+		i++
+	}
+
+	// Spectators (and players who sat the match out) get the room's status below the miniatures.
+	if me < 0 && static {
+		status := client.lobbyStatus(lost)
+		if match.Over() {
+			winner := "nobody"
+			if match.Winner() >= 0 {
+				winner = client.names[match.Winner()]
+			}
+			status = append([]string{fmt.Sprintf("Won by %s", winner)}, status...)
+			if client.name != "" && lost == nil {
+				status = append(status, "", "Press 'r' to play in the next match")
+			}
+		}
+		for line, s := range append(status, "", "Quit            ctrl-c or 'q'") {
+			tetris.PrintString(screen, 1, perColumn*(tetris.MiniBoardHeight+1)+line, s)
+		}
+	}
+	screen.Flush()
+}
@@ -0,0 +1,707 @@
+package netplay
+
+import (
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The most players that a server's rooms can hold: as many as a match can have.
+const MaxRoomSize = tetris.MaxPlayers
+
+// How long a server waits for a player's inputs for the next frame before dropping them from the match, so
+// that a player whose connection has stalled doesn't hold everybody else up.
+const serverStallTimeout = 10 * time.Second
+
+// The most messages a server queues up for a client before deciding that it can't keep up and dropping it.
+const serverQueueLength = 4096
+
+// How far ahead of the frame that the server is waiting for a player can send inputs for. Players only
+// schedule their inputs the input delay ahead of the frames that they've simulated, which the server has
+// already sent them, so anything further ahead is an error.
+const serverInputWindow = 2 * tetris.LockstepInputDelay
+
+// How long the players in a room have to ask for a rematch after a match. After that, the next match starts
+// with the players who have (if there are at least two of them), and the others watch it.
+const serverReadyTimeout = 30 * time.Second
+
+// The most of a match's messages that a server keeps (in bytes) for catching up spectators who arrive partway
+// through it, which is over ten minutes of most matches. Spectators who arrive later than that wait for the
+// next match.
+const serverHistoryLimit = 1 << 20
+
+// A Server hosts versus matches for players who connect to it (see JoinServer), putting them into rooms as
+// they arrive. A room's match starts as soon as the room is full, or once it has had at least two players for
+// a while. After a match, the players in the room can ask for a rematch, and others can join them for it.
+// People can also watch a room's matches as spectators (see SpectateServer).
+//
+// The server runs each match in lockstep like the players do (see tetris.Lockstep), but it doesn't play: it
+// collects every player's inputs, decides which frame they go in, and sends each frame's inputs to everybody
+// in the room, along with hashes of its own simulation for them to check theirs against.
+//
+// The protocol starts like a network match's (see netConn), and then the client asks to play (with their
+// name), or to watch a room (or whichever room has a match on):
+//
+//	join alice (or "spectate 3", or "spectate")
+//
+// The server tells everybody in a room who's in it and how many seconds are left until the match starts ("-"
+// if it's waiting for more players, or for a match to end). Players who are ready to start are marked with a
+// "*" (everybody is, except players who haven't asked for a rematch yet, who are left out of the next match
+// if they don't ask in time):
+//
+//	lobby 3 8 12 alice* bob* carol
+//
+// When a match starts, the server names the players and describes the match, including the index of the
+// player receiving the message (or -1 for spectators):
+//
+//	players alice bob carol
+//	start 1234 guideline 0 stack=fading
+//
+// During the match, players send their inputs for every frame as in a network match, including "target" to
+// change their targeting strategy. The server sends each frame's inputs for every player ("-" for none, and
+// several joined with commas) and its hashes:
+//
+//	inputs 12 left drop
+//	frame 12 left,drop - rotate
+//	hash 60 8c2f1e4d9a0b7c36
+//
+// A player who leaves during a match forfeits it, in the frame after the last inputs they sent. After the
+// match, players send "rematch" to play again. Anybody can send "bye" to leave. If the server won't serve a
+// client, it sends "error <reason>" and hangs up.
+type Server struct {
+	options  tetris.ModeOptions
+	roomSize int
+	wait     time.Duration
+	// How long players have to ask for a rematch (see serverReadyTimeout).
+	readyTimeout time.Duration
+	logger       *log.Logger
+	// Everything that happens is handled in order by one goroutine (see run), which owns the rooms.
+	events   chan serverEvent
+	rooms    []*room
+	lastRoom int
+	// The number of players in the rooms, for other goroutines to read (see Players).
+	players int32
+}
+
+// Make a server with rooms for up to roomSize players (from 2 to MaxRoomSize), which start their matches
+// once they've waited for more players for the given time. Matches are played with the given options (see
+// tetris.NewMatch), and the server logs the comings and goings of its players to logger (if it isn't nil).
+func NewServer(options tetris.ModeOptions, roomSize int, wait time.Duration,
+	logger *log.Logger) (*Server, error) {
+	if roomSize < 2 || roomSize > MaxRoomSize {
+		return nil, fmt.Errorf("rooms must hold from 2 to %d players", MaxRoomSize)
+	}
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+	server := &Server{
+		options:      options,
+		roomSize:     roomSize,
+		wait:         wait,
+		readyTimeout: serverReadyTimeout,
+		logger:       logger,
+		events:       make(chan serverEvent, 100),
+	}
+	go server.run()
+	return server, nil
+}
+
+// Serve the clients who connect to a listener, until it stops accepting connections.
+func (server *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go server.handle(conn)
+	}
+}
+
+// A serverEvent is a message from a client, or the error that ended its connection.
+type serverEvent struct {
+	client *serverClient
+	fields []string
+	err    error
+}
+
+// Talk to a client: after the handshake, pass its messages to the server's goroutine until it disconnects.
+func (server *Server) handle(conn net.Conn) {
+	c := newNetConn(conn)
+	conn.SetDeadline(time.Now().Add(netHandshakeTimeout))
+	if err := c.exchangeVersions(); err != nil {
+		server.logger.Printf("%s: %s", conn.RemoteAddr(), err)
+		c.send("error", err)
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	client := &serverClient{conn: c, out: make(chan string, serverQueueLength), player: -1}
+	go client.write()
+	for {
+		fields, err := c.receive()
+		server.events <- serverEvent{client, fields, err}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Handle everything that happens to the server, one thing at a time.
+func (server *Server) run() {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case event := <-server.events:
+			server.receive(event)
+		case now := <-ticker.C:
+			for _, room := range server.rooms {
+				room.tick(now)
+			}
+		}
+		// Close the rooms that everybody has left.
+		open := server.rooms[:0]
+		for _, room := range server.rooms {
+			if len(room.players) > 0 || len(room.spectators) > 0 {
+				open = append(open, room)
+			} else {
+				server.logger.Printf("room %d: closed", room.id)
+			}
+		}
+		server.rooms = open
+		players := 0
+		for _, room := range server.rooms {
+			players += len(room.players)
+		}
+		atomic.StoreInt32(&server.players, int32(players))
+	}
+}
+
+// The number of players in the server's rooms.
+func (server *Server) Players() int {
+	return int(atomic.LoadInt32(&server.players))
+}
+
+// The most players that one of the server's rooms holds.
+func (server *Server) RoomSize() int {
+	return server.roomSize
+}
+
+// Apply a message from a client.
+func (server *Server) receive(event serverEvent) {
+	client := event.client
+	if event.err != nil || event.fields[0] == "bye" {
+		server.leave(client)
+		return
+	}
+	if client.gone {
+		return
+	}
+	var err error
+	if client.room == nil {
+		err = server.greet(client, event.fields)
+	} else {
+		err = client.room.receive(client, event.fields)
+	}
+	if err != nil {
+		client.send("error", err)
+		server.leave(client)
+	}
+}
+
+// Take a client out of its room (if it's in one) and hang up on it.
+func (server *Server) leave(client *serverClient) {
+	if client.room != nil {
+		client.room.leave(client)
+	}
+	client.hangUp()
+}
+
+// Put a new client into a room, as a player or a spectator as it asks.
+func (server *Server) greet(client *serverClient, fields []string) error {
+	switch fields[0] {
+	case "join":
+		if len(fields) != 2 {
+			return fmt.Errorf("expected 'join <name>'")
+		}
+		if err := checkPlayerName(fields[1]); err != nil {
+			return err
+		}
+		server.findRoom().join(client, fields[1])
+	case "spectate":
+		if len(fields) > 2 {
+			return fmt.Errorf("expected 'spectate [<room>]'")
+		}
+		room, err := server.findSpectatorRoom(fields[1:])
+		if err != nil {
+			return err
+		}
+		room.watch(client)
+	default:
+		return fmt.Errorf("expected 'join <name>' or 'spectate [<room>]'")
+	}
+	return nil
+}
+
+// Find a room for a new player: the first one with space that isn't in the middle of a match, or else a new
+// one.
+func (server *Server) findRoom() *room {
+	for _, room := range server.rooms {
+		if !room.matchOn() && len(room.players) < server.roomSize {
+			return room
+		}
+	}
+	server.lastRoom++
+	room := &room{server: server, id: server.lastRoom}
+	server.rooms = append(server.rooms, room)
+	server.logger.Printf("room %d: opened", room.id)
+	return room
+}
+
+// Find the room that a spectator asked to watch, or if they didn't say, the first one with a match on (or
+// just the first one).
+func (server *Server) findSpectatorRoom(args []string) (*room, error) {
+	if len(args) == 1 {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("bad room %q", args[0])
+		}
+		for _, room := range server.rooms {
+			if room.id == id {
+				return room, nil
+			}
+		}
+		return nil, fmt.Errorf("there's no room %d", id)
+	}
+	for _, room := range server.rooms {
+		if room.matchOn() {
+			return room, nil
+		}
+	}
+	if len(server.rooms) == 0 {
+		return nil, fmt.Errorf("there are no rooms to watch")
+	}
+	return server.rooms[0], nil
+}
+
+// Check that a player's name can be sent in messages and fits on the screen: from 1 to 12 letters, digits,
+// '-', '_', or '.'.
+func checkPlayerName(name string) error {
+	if len(name) == 0 || len(name) > 12 {
+		return fmt.Errorf("names must be from 1 to 12 characters long")
+	}
+	for _, ch := range name {
+		letter := ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+		if !letter && !(ch >= '0' && ch <= '9') && !strings.ContainsRune("-_.", ch) {
+			return fmt.Errorf("names can only have letters, digits, '-', '_', and '.'")
+		}
+	}
+	return nil
+}
+
+// A serverClient is somebody connected to a server. It's only used by the server's goroutine (apart from
+// the queue of messages for it).
+type serverClient struct {
+	conn *netConn
+	// Messages waiting to be sent, which are written to the connection in order by their own goroutine, so
+	// that a slow client doesn't hold up the server.
+	out  chan string
+	room *room
+	// The player's name, or "" for a spectator.
+	name string
+	// The player's index in the room's current match, or -1 if they aren't playing in it.
+	player int
+	// Whether the player is ready for the next match to start.
+	ready bool
+	// Whether a spectator has been sent the room's current match from its start, so that they can follow it.
+	following bool
+	// Whether the server has hung up on the client.
+	gone bool
+}
+
+// Send a message to the client. If it isn't keeping up with its messages, it's dropped.
+func (client *serverClient) send(fields ...interface{}) {
+	client.sendLine(fmt.Sprintln(fields...))
+}
+
+// Send some lines to the client at once.
+func (client *serverClient) sendLine(lines string) {
+	if client.gone {
+		return
+	}
+	select {
+	case client.out <- lines:
+	default:
+		client.conn.conn.Close()
+		client.hangUp()
+	}
+}
+
+// Hang up on the client, once the messages already queued for it have been sent.
+func (client *serverClient) hangUp() {
+	if !client.gone {
+		client.gone = true
+		close(client.out)
+	}
+}
+
+// Write the client's messages to its connection, and then close it once the client has been hung up on.
+func (client *serverClient) write() {
+	defer client.conn.conn.Close()
+	for lines := range client.out {
+		if _, err := io.WriteString(client.conn.conn, lines); err != nil {
+			return
+		}
+	}
+}
+
+// A room is a group of players who play matches together, and the spectators who watch them.
+type room struct {
+	server *Server
+	id     int
+	// The players in the room, in the order they arrived, and the spectators.
+	players, spectators []*serverClient
+	// The current match (or the last one, once it's over), and the players in it (in the match's order), who
+	// stay in it even if they leave the room.
+	step    *tetris.Lockstep
+	playing []*serverClient
+	// Everything sent to the room about the current match so far, for spectators who arrive late to catch up
+	// with, unless there's too much of it (see serverHistoryLimit).
+	history     strings.Builder
+	historyFull bool
+	// When the next match starts if nobody else joins, if the room is counting down to it.
+	startAt time.Time
+	// When the players who haven't asked for a rematch are left out of it, after a match.
+	readyBy time.Time
+	// When the room started waiting for the inputs for the next frame of the match.
+	waitingSince time.Time
+	// The last lobby message sent, so that it's only sent when it changes.
+	lobby string
+}
+
+// Whether the room's match is being played.
+func (room *room) matchOn() bool {
+	return room.step != nil && !room.step.Match().Over()
+}
+
+// Add a player to the room. If somebody in the room already has their name, they get a number after it.
+func (room *room) join(client *serverClient, name string) {
+	taken := make(map[string]bool)
+	for _, player := range room.players {
+		taken[player.name] = true
+	}
+	for n := 2; taken[name]; n++ {
+		suffix := strconv.Itoa(n)
+		if len(name)+len(suffix) > 12 {
+			name = name[:12-len(suffix)]
+		}
+		name = strings.TrimRight(name, "0123456789") + suffix
+	}
+	client.room = room
+	client.name = name
+	client.ready = true
+	room.players = append(room.players, client)
+	room.server.logger.Printf("room %d: %s joined from %s", room.id, name, client.conn.conn.RemoteAddr())
+	room.update(time.Now())
+}
+
+// Add a spectator to the room, catching them up with the match so far (if there is one, and it hasn't been
+// going on for too long).
+func (room *room) watch(client *serverClient) {
+	client.room = room
+	room.spectators = append(room.spectators, client)
+	room.server.logger.Printf("room %d: a spectator joined from %s", room.id, client.conn.conn.RemoteAddr())
+	client.sendLine(room.lobby)
+	if room.step != nil && !room.historyFull {
+		client.sendLine(room.history.String())
+		client.following = true
+	}
+}
+
+// Take a player or spectator out of the room. A player in the middle of a match forfeits it.
+func (room *room) leave(client *serverClient) {
+	client.room = nil
+	remove := func(clients []*serverClient) []*serverClient {
+		kept := clients[:0]
+		for _, c := range clients {
+			if c != client {
+				kept = append(kept, c)
+			}
+		}
+		return kept
+	}
+	if client.name == "" {
+		room.spectators = remove(room.spectators)
+		return
+	}
+	room.players = remove(room.players)
+	room.server.logger.Printf("room %d: %s left", room.id, client.name)
+	if room.matchOn() {
+		room.advance(time.Now())
+	}
+	room.update(time.Now())
+}
+
+// Apply a message from somebody in the room.
+func (room *room) receive(client *serverClient, fields []string) error {
+	switch fields[0] {
+	case "inputs":
+		if len(fields) < 2 {
+			return fmt.Errorf("expected 'inputs <frame> [<event> ...]'")
+		}
+		frame, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("bad frame %q", fields[1])
+		}
+		var events []tetris.GameEvent
+		for _, name := range fields[2:] {
+			event, ok := tetris.ParseGameEvent(name)
+			if !ok || !event.IsMatchInput() || event == tetris.Forfeit {
+				return fmt.Errorf("bad input event %q", name)
+			}
+			events = append(events, event)
+		}
+		// Inputs for a match that's over, or for frames that the server has already filled in because the
+		// player had been knocked out, don't matter any more.
+		if client.player < 0 || !room.matchOn() || frame < room.step.Frame() {
+			return nil
+		}
+		if frame > room.step.Frame()+serverInputWindow {
+			return fmt.Errorf("inputs for frame %d are too far ahead of frame %d", frame, room.step.Frame())
+		}
+		if _, ok := room.step.Inputs(client.player, frame); ok {
+			return nil
+		}
+		room.step.SetInputs(client.player, frame, events)
+		room.advance(time.Now())
+	case "rematch":
+		if client.name != "" && !room.matchOn() && !client.ready {
+			client.ready = true
+			room.update(time.Now())
+		}
+	default:
+		return fmt.Errorf("unexpected message %q", fields[0])
+	}
+	return nil
+}
+
+// Keep the room going as time passes: start its match when it's time, and drop players who have stalled it.
+func (room *room) tick(now time.Time) {
+	if !room.matchOn() {
+		room.update(now)
+		return
+	}
+	if now.Sub(room.waitingSince) < serverStallTimeout {
+		return
+	}
+	for player, client := range room.playing {
+		_, ok := room.step.Inputs(player, room.step.Frame())
+		if !ok && client.room == room && room.step.Match().Place(player) == 0 {
+			client.send("error", "your inputs stopped arriving")
+			room.server.leave(client)
+		}
+	}
+}
+
+// Tell everybody in the room who's in it and how long there is to wait, and start the room's next match if
+// it's time. A match starts once all of the players (and there are at least two) are ready, or at least two
+// of them are and the others have had long enough to ask for a rematch, and either the room is full or it has
+// waited long enough for more players.
+func (room *room) update(now time.Time) {
+	if room.matchOn() {
+		return
+	}
+	ready := 0
+	for _, player := range room.players {
+		if player.ready {
+			ready++
+		}
+	}
+	countdown := "-"
+	starting := false
+	if ready < 2 || ready < len(room.players) && now.Before(room.readyBy) {
+		room.startAt = time.Time{}
+	} else {
+		if room.startAt.IsZero() {
+			room.startAt = now.Add(room.server.wait)
+		}
+		starting = len(room.players) == room.server.roomSize || !now.Before(room.startAt)
+		countdown = "0"
+		if !starting {
+			countdown = strconv.Itoa(int(room.startAt.Sub(now)/time.Second) + 1)
+		}
+	}
+	fields := []string{"lobby", strconv.Itoa(room.id), strconv.Itoa(room.server.roomSize), countdown}
+	for _, player := range room.players {
+		name := player.name
+		if player.ready {
+			name += "*"
+		}
+		fields = append(fields, name)
+	}
+	if lobby := strings.Join(fields, " ") + "\n"; lobby != room.lobby {
+		room.lobby = lobby
+		room.broadcast(lobby)
+	}
+	if starting {
+		room.start(now)
+	}
+}
+
+// Send some lines to everybody in the room.
+func (room *room) broadcast(lines string) {
+	for _, client := range room.players {
+		client.sendLine(lines)
+	}
+	for _, client := range room.spectators {
+		client.sendLine(lines)
+	}
+}
+
+// Send some lines about the current match to everybody in the room who is following it (everybody but the
+// spectators who arrived too late to catch up with it), and keep them for spectators who arrive later.
+func (room *room) broadcastMatch(lines string) {
+	for _, client := range room.players {
+		client.sendLine(lines)
+	}
+	for _, client := range room.spectators {
+		if client.following {
+			client.sendLine(lines)
+		}
+	}
+	if room.historyFull {
+		return
+	}
+	if room.history.Len()+len(lines) > serverHistoryLimit {
+		room.history.Reset()
+		room.historyFull = true
+		return
+	}
+	room.history.WriteString(lines)
+}
+
+// Start a match between the players in the room who are ready. Those who aren't watch it.
+func (room *room) start(now time.Time) {
+	seed := now.UnixNano()
+	room.playing = nil
+	names := []string{"players"}
+	for _, client := range room.players {
+		if client.ready {
+			client.player = len(room.playing)
+			client.ready = false
+			room.playing = append(room.playing, client)
+			names = append(names, client.name)
+		}
+	}
+	match := tetris.NewMatch(seed, len(room.playing), room.server.options)
+	match.SetNames(names[1:])
+	room.step = tetris.NewLockstep(match, -1)
+	room.startAt = time.Time{}
+	room.readyBy = time.Time{}
+	room.waitingSince = now
+	room.lobby = ""
+
+	header := strings.Join(names, " ") + "\n"
+	description := func(player int) string {
+		start := fmt.Sprintf("start %d %s %d %s", seed, netRuleset, player, room.server.options)
+		return strings.TrimSpace(start) + "\n"
+	}
+	for _, client := range room.players {
+		client.sendLine(header + description(client.player))
+	}
+	room.history.Reset()
+	room.history.WriteString(header + description(-1))
+	room.historyFull = false
+	for _, client := range room.spectators {
+		client.sendLine(header + description(-1))
+		client.following = true
+	}
+	room.server.logger.Printf("room %d: match started between %s", room.id, strings.Join(names[1:], ", "))
+	room.advance(now)
+}
+
+// Run the match for as many frames as the players' inputs allow, sending each frame's inputs (and hashes of
+// the simulation) to everybody in the room. Players who have been knocked out don't have inputs any more, and
+// players who have left forfeit in the frame after their last inputs.
+func (room *room) advance(now time.Time) {
+	step := room.step
+	match := step.Match()
+	for !match.Over() {
+		frame := step.Frame()
+		fields := []string{"frame", strconv.Itoa(frame)}
+		for player := 0; player < match.Players(); player++ {
+			events, ok := step.Inputs(player, frame)
+			switch {
+			case ok:
+			case match.Place(player) > 0:
+				step.SetInputs(player, frame, nil)
+			case room.playing[player].room != room:
+				events = []tetris.GameEvent{tetris.Forfeit}
+				step.SetInputs(player, frame, events)
+			default:
+				return
+			}
+			fields = append(fields, formatFrameInputs(events))
+		}
+		step.Simulate()
+		room.waitingSince = now
+		lines := strings.Join(fields, " ") + "\n"
+		if hash, ok := step.Hash(frame); ok {
+			lines += fmt.Sprintf("hash %d %016x\n", frame, hash)
+			step.DropHash(frame)
+		}
+		room.broadcastMatch(lines)
+	}
+	room.finish()
+}
+
+// Log how the room's match went, and let the players get ready for a rematch.
+func (room *room) finish() {
+	match := room.step.Match()
+	winner := "nobody"
+	if match.Winner() >= 0 {
+		winner = room.playing[match.Winner()].name
+	}
+	room.server.logger.Printf("room %d: match won by %s after %s", room.id, winner,
+		tetris.FormatMillis(room.step.Frame()*tetris.LockstepFrameMillis))
+	for _, client := range room.playing {
+		client.player = -1
+	}
+	room.readyBy = time.Now().Add(room.server.readyTimeout)
+	room.update(time.Now())
+}
+
+// Describe a player's inputs for a frame in a frame message: "-" if there aren't any, or else the events
+// joined by commas.
+func formatFrameInputs(events []tetris.GameEvent) string {
+	if len(events) == 0 {
+		return "-"
+	}
+	names := make([]string, len(events))
+	for i, event := range events {
+		names[i] = event.String()
+	}
+	return strings.Join(names, ",")
+}
+
+// Parse a player's inputs for a frame from a frame message (see formatFrameInputs).
+func parseFrameInputs(s string) ([]tetris.GameEvent, error) {
+	if s == "-" {
+		return nil, nil
+	}
+	var events []tetris.GameEvent
+	for _, name := range strings.Split(s, ",") {
+		event, ok := tetris.ParseGameEvent(name)
+		if !ok || !event.IsMatchInput() {
+			return nil, fmt.Errorf("bad input event %q", name)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,343 @@
+package netplay
+
+import (
+	"github.com/cespare/go-tetris/tetris"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Start a server on the loopback interface, returning it and its address. It's stopped at the end of the
+// test.
+func loopbackServer(t *testing.T, roomSize int, wait time.Duration) (*Server, string) {
+	server, err := NewServer(tetris.ModeOptions{}, roomSize, wait, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go server.Serve(listener)
+	return server, listener.Addr().String()
+}
+
+// A serverBot is a client of a server in a test, which applies the server's messages as they arrive.
+type serverBot struct {
+	*ServerClient
+	messages chan netMessage
+}
+
+// Connect to a server as a player called name, or as a spectator if name is "".
+func connectBot(t *testing.T, address, name string) *serverBot {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	var client *ServerClient
+	if name == "" {
+		client, err = SpectateServer(conn, 0)
+	} else {
+		client, err = JoinServer(conn, name)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot := &serverBot{client, make(chan netMessage, serverQueueLength)}
+	go func() {
+		for {
+			fields, err := client.conn.receive()
+			bot.messages <- netMessage{fields, err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return bot
+}
+
+// Apply the messages which have arrived from the server.
+func (bot *serverBot) pump(t *testing.T) {
+	for {
+		select {
+		case message := <-bot.messages:
+			if message.err != nil {
+				return
+			}
+			if _, err := bot.receive(message.fields); err != nil {
+				t.Fatalf("%s: %s", bot.name, err)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Wait for something to be true of some bots, applying the server's messages in the meantime (and letting
+// those who aren't playing follow the match).
+func waitForBots(t *testing.T, what string, bots []*serverBot, done func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+		for _, bot := range bots {
+			bot.pump(t)
+			if bot.step != nil && bot.step.Player() < 0 {
+				bot.advanceTo(1 << 30)
+			}
+		}
+	}
+}
+
+// Whether every bot has started a match other than the ones given.
+func startedNew(bots []*serverBot, old []*tetris.Lockstep) func() bool {
+	return func() bool {
+		for i, bot := range bots {
+			if bot.step == nil || bot.step == old[i] {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// The bots' current matches.
+func botMatches(bots []*serverBot) []*tetris.Lockstep {
+	matches := make([]*tetris.Lockstep, len(bots))
+	for i, bot := range bots {
+		matches[i] = bot.step
+	}
+	return matches
+}
+
+// Play the bots' match on a server for up to some number of frames more, or until it's over. Before each
+// frame, inputs calls for the players' inputs for that frame. Spectators just follow the match as far as they
+// can.
+func playServer(t *testing.T, bots []*serverBot, frames int, inputs func(frame int)) {
+	playing := func(bot *serverBot) bool {
+		return bot.step.Player() >= 0 && !bot.step.Match().Over()
+	}
+	first := bots[0].step.Frame() + 1
+	for frame := first; frame < first+frames; frame++ {
+		over := true
+		for _, bot := range bots {
+			over = over && !playing(bot)
+		}
+		if over {
+			return
+		}
+		inputs(frame)
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			behind := false
+			for _, bot := range bots {
+				bot.pump(t)
+				target := frame
+				if bot.step.Player() < 0 {
+					target = 1 << 30
+				}
+				if err := bot.advanceTo(target); err != nil {
+					t.Fatal(err)
+				}
+				if desync := bot.step.Desync(); desync != "" {
+					t.Fatalf("%s noticed a desync:\n%s", bot.name, desync)
+				}
+				behind = behind || playing(bot) && bot.step.Frame() < frame
+			}
+			if !behind {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("frame %d: the players are stuck", frame)
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	}
+}
+
+// Inputs for players on a server to drop pieces straight down, the others four times as often as the first
+// (so that they top out first).
+func serverDroppers(bots []*serverBot) func(frame int) {
+	return func(frame int) {
+		for i, bot := range bots {
+			player := bot.step.Player()
+			if player < 0 || bot.step.Match().Over() || bot.step.Match().Game(player).Over() {
+				continue
+			}
+			if i == 0 && frame%144 == 0 || i > 0 && frame%36 == 0 {
+				bot.step.Input(tetris.QuickDrop)
+			}
+		}
+	}
+}
+
+func TestServerMatch(t *testing.T) {
+	_, address := loopbackServer(t, 3, time.Minute)
+	var bots []*serverBot
+	for _, name := range []string{"alice", "bob", "alice"} {
+		bots = append(bots, connectBot(t, address, name))
+	}
+	// The room is full, so the match starts right away.
+	waitForBots(t, "the match to start", bots, startedNew(bots, make([]*tetris.Lockstep, 3)))
+	if want := []string{"alice", "bob", "alice2"}; !reflect.DeepEqual(bots[0].names, want) {
+		t.Errorf("the players are called %q; want %q", bots[0].names, want)
+	}
+	for i, bot := range bots {
+		if player := bot.step.Player(); player != i {
+			t.Errorf("%s is player %d; want %d", bot.name, player, i)
+		}
+	}
+
+	players := bots
+	bots[1].step.Input(tetris.Target)
+	playServer(t, bots, 300, serverDroppers(players))
+	// A spectator arriving partway through catches up with the match from the start.
+	spectator := connectBot(t, address, "")
+	bots = append(bots, spectator)
+	waitForBots(t, "the spectator to catch up", bots, startedNew(bots[3:], []*tetris.Lockstep{nil}))
+	playServer(t, bots, 30000, serverDroppers(players))
+
+	if match := bots[0].step.Match(); !match.Over() || match.Winner() != 0 {
+		t.Fatalf("the match isn't over with alice winning after %d frames", bots[0].step.Frame())
+	}
+	targeted := false
+	for _, input := range bots[0].step.Replay().Inputs {
+		targeted = targeted || input.Player == 1 && input.Event == tetris.Target
+	}
+	if !targeted {
+		t.Errorf("bob's change of target wasn't played")
+	}
+	waitForBots(t, "the spectator to see the end", bots, func() bool { return spectator.step.Match().Over() })
+	for _, bot := range bots[1:] {
+		if !reflect.DeepEqual(bot.step.Replay(), bots[0].step.Replay()) {
+			t.Errorf("%s's match went differently from alice's", bot.name)
+		}
+	}
+}
+
+func TestServerForfeit(t *testing.T) {
+	_, address := loopbackServer(t, 2, time.Minute)
+	bots := []*serverBot{connectBot(t, address, "alice"), connectBot(t, address, "bob")}
+	waitForBots(t, "the match to start", bots, startedNew(bots, make([]*tetris.Lockstep, 2)))
+	playServer(t, bots, 100, func(int) {})
+	bots[1].conn.send("bye")
+	bots = bots[:1]
+	waitForBots(t, "bob to forfeit", bots, func() bool {
+		bots[0].advanceTo(1 << 30)
+		return bots[0].step.Match().Over()
+	})
+	inputs := bots[0].step.Replay().Inputs
+	last := inputs[len(inputs)-1]
+	winner := bots[0].step.Match().Winner()
+	if winner != 0 || last.Player != 1 || last.Event != tetris.Forfeit {
+		t.Errorf("after bob left, player %d won; want alice, with bob forfeiting", winner)
+	}
+}
+
+func TestServerRematch(t *testing.T) {
+	server, address := loopbackServer(t, 3, 50*time.Millisecond)
+	server.readyTimeout = 200 * time.Millisecond
+	bots := []*serverBot{connectBot(t, address, "alice"), connectBot(t, address, "bob"),
+		connectBot(t, address, "carol")}
+	waitForBots(t, "the match to start", bots, startedNew(bots, make([]*tetris.Lockstep, 3)))
+	playServer(t, bots, 5000, func(frame int) {
+		for _, bot := range bots {
+			if frame%5 == 0 && !bot.step.Match().Game(bot.step.Player()).Over() {
+				bot.step.Input(tetris.QuickDrop)
+			}
+		}
+	})
+	if !bots[0].step.Match().Over() {
+		t.Fatalf("the match isn't over")
+	}
+
+	// Carol doesn't ask for a rematch, so the next match starts without her once the others have waited for
+	// her long enough, and she watches it.
+	first := botMatches(bots)
+	bots[0].rematch()
+	bots[1].rematch()
+	waitForBots(t, "the rematch to start", bots, startedNew(bots, first))
+	if want := []string{"alice", "bob"}; !reflect.DeepEqual(bots[2].names, want) {
+		t.Errorf("the rematch is between %q; want %q", bots[2].names, want)
+	}
+	if player := bots[2].step.Player(); player != -1 {
+		t.Errorf("carol is player %d in the rematch; want her watching it", player)
+	}
+	playServer(t, bots, 5000, func(frame int) {
+		for _, bot := range bots[:2] {
+			if frame%5 == 0 && !bot.step.Match().Game(bot.step.Player()).Over() {
+				bot.step.Input(tetris.QuickDrop)
+			}
+		}
+	})
+	waitForBots(t, "carol to see the end", bots, func() bool { return bots[2].step.Match().Over() })
+
+	// Now carol is ready, and is back in the next one.
+	second := botMatches(bots)
+	for _, bot := range bots {
+		bot.rematch()
+	}
+	waitForBots(t, "the third match to start", bots, startedNew(bots, second))
+	if player := bots[2].step.Player(); player != 2 {
+		t.Errorf("carol is player %d in the third match; want 2", player)
+	}
+}
+
+func TestServerInputWindow(t *testing.T) {
+	_, address := loopbackServer(t, 2, time.Minute)
+	bots := []*serverBot{connectBot(t, address, "alice"), connectBot(t, address, "bob")}
+	waitForBots(t, "the match to start", bots, startedNew(bots, make([]*tetris.Lockstep, 2)))
+	bots[0].conn.send("inputs", 1000000, "left")
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case message := <-bots[0].messages:
+			if message.err != nil {
+				t.Fatalf("the server hung up without an error")
+			}
+			if message.fields[0] == "error" {
+				if said := strings.Join(message.fields, " "); !strings.Contains(said, "too far ahead") {
+					t.Errorf("the server said %q; want an error about inputs too far ahead", said)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatalf("the server accepted inputs for a frame far in the future")
+		}
+	}
+}
+
+func TestServerGreetingErrors(t *testing.T) {
+	_, address := loopbackServer(t, 2, time.Minute)
+	for _, test := range []struct {
+		name string
+		room int
+		want string
+	}{
+		{"bad!name", 0, "names can only have"},
+		{"averyveryverylongname", 0, "from 1 to 12"},
+		{"", 0, "no rooms"},
+		{"", 5, "no room 5"},
+	} {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.name != "" {
+			_, err = JoinServer(conn, test.name)
+		} else {
+			_, err = SpectateServer(conn, test.room)
+		}
+		conn.Close()
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("joining as %q (room %d) gave the error %v; want one about %q", test.name, test.room,
+				err, test.want)
+		}
+	}
+}
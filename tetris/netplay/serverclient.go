@@ -0,0 +1,209 @@
+package netplay
+
+import (
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A ServerClient is a connection to a server (see Server), playing in the matches of a room or watching them.
+type ServerClient struct {
+	conn *netConn
+	// The name that the server knows this player by, or "" for a spectator.
+	name string
+	// The room's last lobby message: the room's number and size, the seconds left until the next match starts
+	// (or -1 if it isn't counting down), and the players in it (with "*" after the names of those who are
+	// ready).
+	room, size, countdown int
+	lobby                 []string
+	// The current match (or the last one), run in lockstep with the server's frames, and the players in it.
+	// It's nil until the first match starts.
+	step  *tetris.Lockstep
+	names []string
+	// Replays of the matches before the current one.
+	replays []*tetris.MatchReplay
+}
+
+// Join a server as a player called name, to play in whichever room it puts the player in.
+func JoinServer(conn net.Conn, name string) (*ServerClient, error) {
+	return connectToServer(conn, name, "join", name)
+}
+
+// Join a server as a spectator, to watch the matches of a room (or if room is 0, whichever room the server
+// chooses).
+func SpectateServer(conn net.Conn, room int) (*ServerClient, error) {
+	if room == 0 {
+		return connectToServer(conn, "", "spectate")
+	}
+	return connectToServer(conn, "", "spectate", room)
+}
+
+// Connect to a server, greeting it with a message, and wait for its answer.
+func connectToServer(conn net.Conn, name string, greeting ...interface{}) (*ServerClient, error) {
+	client := &ServerClient{conn: newNetConn(conn), name: name, countdown: -1}
+	conn.SetDeadline(time.Now().Add(netHandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+	if err := client.conn.exchangeVersions(); err != nil {
+		return nil, err
+	}
+	if err := client.conn.send(greeting...); err != nil {
+		return nil, err
+	}
+	fields, err := client.conn.receive()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.receive(fields); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Whether a match is being played.
+func (client *ServerClient) playing() bool {
+	return client.step != nil && !client.step.Match().Over()
+}
+
+// Apply a message from the server. Returns true if it started a new match.
+func (client *ServerClient) receive(fields []string) (bool, error) {
+	parseFrame := func(s string) (int, error) {
+		frame, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("bad frame %q", s)
+		}
+		return frame, nil
+	}
+	switch fields[0] {
+	case "lobby":
+		if len(fields) < 4 {
+			return false, fmt.Errorf("expected 'lobby <room> <size> <countdown> [<player> ...]'")
+		}
+		room, err1 := strconv.Atoi(fields[1])
+		size, err2 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil {
+			return false, fmt.Errorf("bad lobby message")
+		}
+		client.room, client.size, client.lobby = room, size, fields[4:]
+		client.countdown = -1
+		if countdown, err := strconv.Atoi(fields[3]); err == nil {
+			client.countdown = countdown
+		}
+	case "players":
+		if len(fields) < 3 {
+			return false, fmt.Errorf("expected 'players <name> <name> [<name> ...]'")
+		}
+		client.names = fields[1:]
+	case "start":
+		return true, client.start(fields)
+	case "frame":
+		if client.step == nil || len(fields) != len(client.names)+2 {
+			return false, fmt.Errorf("expected 'frame <frame> <inputs>' with inputs for each player")
+		}
+		frame, err := parseFrame(fields[1])
+		if err != nil {
+			return false, err
+		}
+		if frame < client.step.Frame() {
+			// Only the frames before any inputs could be scheduled (which nobody has inputs in) are simulated
+			// before the server sends them.
+			return false, nil
+		}
+		for player, s := range fields[2:] {
+			events, err := parseFrameInputs(s)
+			if err != nil {
+				return false, err
+			}
+			client.step.SetInputs(player, frame, events)
+		}
+	case "hash":
+		if client.step == nil || len(fields) != 3 {
+			return false, fmt.Errorf("expected 'hash <frame> <hash>'")
+		}
+		frame, err := parseFrame(fields[1])
+		if err != nil {
+			return false, err
+		}
+		hash, err := strconv.ParseUint(fields[2], 16, 64)
+		if err != nil {
+			return false, fmt.Errorf("bad hash %q", fields[2])
+		}
+		client.step.ReceiveHash(frame, hash)
+	case "error":
+		return false, fmt.Errorf("the server says: %s", strings.Join(fields[1:], " "))
+	default:
+		return false, fmt.Errorf("unexpected message %q", fields[0])
+	}
+	return false, nil
+}
+
+// Start a new match, from the server's description of it.
+func (client *ServerClient) start(fields []string) error {
+	if len(fields) < 4 {
+		return fmt.Errorf("expected 'start <seed> <ruleset> <player> [<option>=<value> ...]'")
+	}
+	seed, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad seed %q", fields[1])
+	}
+	if fields[2] != netRuleset {
+		return fmt.Errorf("unknown ruleset %q", fields[2])
+	}
+	player, err := strconv.Atoi(fields[3])
+	if err != nil || player < -1 || player >= len(client.names) {
+		return fmt.Errorf("bad player %q", fields[3])
+	}
+	options, err := tetris.ParseModeOptions(fields[4:])
+	if err != nil {
+		return err
+	}
+	match := tetris.NewMatch(seed, len(client.names), options)
+	match.SetNames(client.names)
+	if client.step != nil {
+		client.replays = append(client.replays, client.step.Replay())
+	}
+	client.step = tetris.NewLockstep(match, player)
+	return nil
+}
+
+// Replays of every match played (or watched) so far, including the current one, which can be saved once
+// they're over.
+func (client *ServerClient) Replays() []*tetris.MatchReplay {
+	if client.step == nil {
+		return nil
+	}
+	replays := append([]*tetris.MatchReplay{}, client.replays...)
+	return append(replays, client.step.Replay())
+}
+
+// Run the match up to a frame (or as far towards it as the server's frames allow), sending this player's
+// inputs to the server as they're due. Spectators just run it as far as they can.
+func (client *ServerClient) advanceTo(frame int) error {
+	step := client.step
+	for step.Frame() < frame && !step.Match().Over() {
+		for step.ScheduleDue() {
+			scheduled, events := step.Schedule()
+			fields := []interface{}{"inputs", scheduled}
+			for _, event := range events {
+				fields = append(fields, event)
+			}
+			if err := client.conn.send(fields...); err != nil {
+				return err
+			}
+		}
+		simulated := step.Frame()
+		if !step.Simulate() {
+			// Wait for the server's next frame.
+			return nil
+		}
+		step.Check(simulated)
+	}
+	return nil
+}
+
+// Ask for a rematch, once the match is over.
+func (client *ServerClient) rematch() error {
+	return client.conn.send("rematch")
+}
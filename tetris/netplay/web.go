@@ -0,0 +1,62 @@
+package netplay
+
+import (
+	_ "embed"
+	"github.com/cespare/go-tetris/tetris"
+	"github.com/gorilla/websocket"
+	"log"
+	"net/http"
+	"time"
+)
+
+// The web page that games are played in (see WebHandler).
+//
+//go:embed web/index.html
+var webPage []byte
+
+// Make an HTTP handler which serves a web page for playing games in a browser. The page draws the game on a
+// canvas, and sends the player's key presses over a WebSocket (at "play", beside the page) to a game running
+// on the server, which sends back the cells of the screen that change as it's drawn, just as it would be in a
+// terminal. Each connection plays its own game, with a mode made by newMode, until the player quits or
+// leaves the page. Up to maxGames games are played at once: while that many are, pages which connect are
+// told to try again later.
+func WebHandler(newMode func() tetris.Mode, maxGames int, logger *log.Logger) http.Handler {
+	upgrader := websocket.Upgrader{}
+	games := make(chan struct{}, maxGames)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(webPage)
+	})
+	mux.HandleFunc("/play", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader has already replied with an error.
+			return
+		}
+		defer conn.Close()
+		select {
+		case games <- struct{}{}:
+			defer func() { <-games }()
+		default:
+			logger.Printf("%s turned away: %d games are being played", r.RemoteAddr, maxGames)
+			full := websocket.FormatCloseMessage(websocket.CloseTryAgainLater,
+				"The server is full. Reload the page to try again.")
+			conn.WriteControl(websocket.CloseMessage, full, time.Now().Add(time.Second))
+			return
+		}
+		logger.Printf("%s connected", r.RemoteAddr)
+		console := tetris.NewWebConsole(conn)
+		game := tetris.NewGame(time.Now().UnixNano(), newMode())
+		game.Start(console, tetris.PlayOptions{})
+		console.Close()
+		bye := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		conn.WriteControl(websocket.CloseMessage, bye, time.Now().Add(time.Second))
+		logger.Printf("%s left", r.RemoteAddr)
+	})
+	return mux
+}
@@ -0,0 +1,159 @@
+package netplay
+
+import (
+	"github.com/cespare/go-tetris/tetris"
+	"github.com/gorilla/websocket"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Serve sprint games in web pages, up to maxGames at once, returning the server's URL. The server is stopped
+// at the end of the test.
+func webServer(t *testing.T, maxGames int) string {
+	newMode := func() tetris.Mode {
+		mode, err := tetris.NewMode("sprint", tetris.ModeOptions{})
+		if err != nil {
+			t.Error(err)
+		}
+		return mode
+	}
+	server := httptest.NewServer(WebHandler(newMode, maxGames, log.New(io.Discard, "", 0)))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestWebPage(t *testing.T) {
+	url := webServer(t, 1)
+	for _, test := range []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/", http.StatusOK, "<canvas"},
+		{"/elsewhere", http.StatusNotFound, "not found"},
+	} {
+		response, err := http.Get(url + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != test.status || !strings.Contains(string(body), test.want) {
+			t.Errorf("%s gave %d %.40q; want %d with %q", test.path, response.StatusCode, body, test.status,
+				test.want)
+		}
+	}
+}
+
+// Connect to the WebSocket that a web page plays its game over. The connection is closed at the end of the
+// test.
+func dialWebPlay(t *testing.T, url string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/play", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func TestWebPlay(t *testing.T) {
+	conn := dialWebPlay(t, webServer(t, 1))
+
+	// The page is sent the whole screen to start with, and then the cells which change.
+	var screen *tetris.Buffer
+	readUpdate := func() error {
+		// The page's side of the protocol (see tetris.WebConsole).
+		var update struct {
+			Width  int             `json:"width"`
+			Height int             `json:"height"`
+			Cells  [][]interface{} `json:"cells"`
+		}
+		if err := conn.ReadJSON(&update); err != nil {
+			return err
+		}
+		if screen == nil {
+			screen = tetris.NewBuffer(update.Width, update.Height)
+			if len(update.Cells) != update.Width*update.Height {
+				t.Errorf("the first update has %d cells; want all %d", len(update.Cells),
+					update.Width*update.Height)
+			}
+		}
+		for _, cell := range update.Cells {
+			if len(cell) != 5 {
+				t.Fatalf("the update has the cell %v; want [x, y, character, foreground, background]", cell)
+			}
+			x, y, ch := int(cell[0].(float64)), int(cell[1].(float64)), []rune(cell[2].(string))[0]
+			for _, color := range cell[3:] {
+				if s, ok := color.(string); !ok || len(s) != 7 || s[0] != '#' {
+					t.Fatalf("the update has the cell %v; want CSS colors", cell)
+				}
+			}
+			screen.SetCell(x, y, ch, tetris.ColorDefault, tetris.ColorDefault)
+		}
+		return nil
+	}
+	if err := readUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(screen.Text(), "SCORE") {
+		t.Errorf("the page doesn't show the game:\n%s", screen.Text())
+	}
+
+	for _, key := range []string{"ArrowLeft", "Shift", " ", "q"} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for {
+		err := readUpdate()
+		if err == nil {
+			continue
+		}
+		if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			t.Fatalf("the connection ended with %v; want it closed normally after quitting", err)
+		}
+		break
+	}
+}
+
+func TestWebFull(t *testing.T) {
+	url := webServer(t, 1)
+	first := dialWebPlay(t, url)
+	if _, _, err := first.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+	// While the first game is being played, there's no room for another.
+	_, _, err := dialWebPlay(t, url).ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+		t.Fatalf("a second page got %v; want to be told to try again later", err)
+	}
+	if err := first.WriteMessage(websocket.TextMessage, []byte("q")); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, _, err := first.ReadMessage(); err != nil {
+			break
+		}
+	}
+	// Once it's over, there's room again (as soon as the handler has finished with it).
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, _, err := dialWebPlay(t, url).ReadMessage()
+		if err == nil {
+			break
+		}
+		if !websocket.IsCloseError(err, websocket.CloseTryAgainLater) || time.Now().After(deadline) {
+			t.Fatalf("a page got %v after the first game ended; want to play", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
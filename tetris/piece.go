@@ -1,9 +1,5 @@
 package tetris
 
-import (
-	"github.com/nsf/termbox-go"
-)
-
 // A particular rotational instance of a piece.
 type PieceInstance []Vector
 
@@ -13,7 +9,9 @@ type Piece struct {
 	rotations       []PieceInstance
 	currentRotation int
 	initialLocation Vector
-	color           termbox.Attribute
+	color           Color
+	// The standard letter name of the piece (I, O, T, S, Z, J, or L).
+	name string
 }
 
 // Find the current PieceInstance of this piece.
@@ -50,41 +48,41 @@ func tetrisPieces() []Piece {
 	// ##
 	// ##
 	return []Piece{Piece{[]PieceInstance{[]Vector{Vector{0, 0}, Vector{1, 0}, Vector{0, 1}, Vector{1, 1}}},
-		0, Vector{4, 0}, termbox.ColorYellow},
+		0, Vector{4, 0}, ColorYellow, "O"},
 		// ##
 		//  ##
 		Piece{[]PieceInstance{[]Vector{Vector{0, 0}, Vector{1, 0}, Vector{1, 1}, Vector{2, 1}},
 			[]Vector{Vector{1, 0}, Vector{0, 1}, Vector{1, 1}, Vector{0, 2}},
-		}, 0, Vector{3, 0}, termbox.ColorRed},
+		}, 0, Vector{3, 0}, ColorRed, "Z"},
 		//  ##
 		// ##
 		Piece{[]PieceInstance{[]Vector{Vector{1, 0}, Vector{2, 0}, Vector{0, 1}, Vector{1, 1}},
 			[]Vector{Vector{0, 0}, Vector{0, 1}, Vector{1, 1}, Vector{1, 2}},
-		}, 0, Vector{3, 0}, termbox.ColorGreen},
+		}, 0, Vector{3, 0}, ColorGreen, "S"},
 		// ###
 		//  #
 		Piece{[]PieceInstance{[]Vector{Vector{0, 0}, Vector{1, 0}, Vector{2, 0}, Vector{1, 1}},
 			[]Vector{Vector{1, 0}, Vector{0, 1}, Vector{1, 1}, Vector{1, 2}},
 			[]Vector{Vector{1, 0}, Vector{0, 1}, Vector{1, 1}, Vector{2, 1}},
 			[]Vector{Vector{0, 0}, Vector{0, 1}, Vector{1, 1}, Vector{0, 2}},
-		}, 0, Vector{3, 0}, termbox.ColorMagenta},
+		}, 0, Vector{3, 0}, ColorMagenta, "T"},
 		// ###
 		// #
 		Piece{[]PieceInstance{[]Vector{Vector{0, 1}, Vector{1, 1}, Vector{2, 1}, Vector{0, 2}},
 			[]Vector{Vector{0, 0}, Vector{1, 0}, Vector{1, 1}, Vector{1, 2}},
 			[]Vector{Vector{2, 0}, Vector{0, 1}, Vector{1, 1}, Vector{2, 1}},
 			[]Vector{Vector{1, 0}, Vector{1, 1}, Vector{1, 2}, Vector{2, 2}},
-		}, 0, Vector{3, -1}, termbox.ColorWhite},
+		}, 0, Vector{3, -1}, ColorWhite, "L"},
 		// ###
 		//   #
 		Piece{[]PieceInstance{[]Vector{Vector{0, 1}, Vector{1, 1}, Vector{2, 1}, Vector{2, 2}},
 			[]Vector{Vector{1, 0}, Vector{1, 1}, Vector{1, 2}, Vector{0, 2}},
 			[]Vector{Vector{0, 1}, Vector{1, 1}, Vector{2, 1}, Vector{0, 0}},
 			[]Vector{Vector{1, 0}, Vector{2, 0}, Vector{1, 1}, Vector{1, 2}},
-		}, 0, Vector{3, -1}, termbox.ColorBlue},
+		}, 0, Vector{3, -1}, ColorBlue, "J"},
 		// ####
 		Piece{[]PieceInstance{[]Vector{Vector{0, 1}, Vector{1, 1}, Vector{2, 1}, Vector{3, 1}},
 			[]Vector{Vector{1, 0}, Vector{1, 1}, Vector{1, 2}, Vector{1, 3}},
-		}, 0, Vector{3, -1}, termbox.ColorCyan},
+		}, 0, Vector{3, -1}, ColorCyan, "I"},
 	}
 }
@@ -0,0 +1,250 @@
+package tetris
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// How often the interactive game advances the game clock and redraws the screen.
+const FrameDelay = 16 * time.Millisecond
+
+// Options for an interactive game.
+type PlayOptions struct {
+	// The file that the board is appended to, as fumen data, when the player presses 'f'. If it's empty, the
+	// board can't be saved.
+	FumenFile string
+	// Personal bests, which are updated and saved at the end of the game (if not nil).
+	Records *Records
+}
+
+// Start running the game on a console, until the player quits (or the console's input ends).
+func (game *Game) Start(console Console, options PlayOptions) {
+	eventQueue := make(chan GameEvent, 100)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			event := UserEvent(console.PollEvent())
+			select {
+			case eventQueue <- event:
+			case <-done:
+				return
+			}
+			if event == Quit {
+				return
+			}
+		}
+	}()
+	for game.play(console, eventQueue, options) {
+		failed := game.finesseFailed
+		game.restart()
+		if failed {
+			game.message = "Finesse fault: starting over"
+		}
+	}
+}
+
+// Play the game until it's over, then show the results until the player quits. Returns true if the game
+// should start over instead: because the player asked to retry (see practicer) or because of a finesse fault
+// (see FinesseStrict).
+func (game *Game) play(screen Screen, events <-chan GameEvent, options PlayOptions) bool {
+	_, canRetry := game.mode.(practicer)
+	_, canUndo := game.mode.(rewinder)
+	drawStaticBoardParts(screen)
+	game.DrawDynamic(screen, false)
+
+	ticker := time.NewTicker(FrameDelay)
+	defer ticker.Stop()
+
+	// The game clock is advanced by the wall time that has passed since lastAdvance, excluding any time spent
+	// paused.
+	lastAdvance := time.Now()
+	advance := func() {
+		elapsed := time.Since(lastAdvance) / time.Millisecond
+		lastAdvance = lastAdvance.Add(elapsed * time.Millisecond)
+		game.Advance(int(elapsed))
+	}
+	for !game.over {
+		select {
+		case event := <-events:
+			advance()
+			switch event {
+			case Quit:
+				return false
+			case Retry:
+				if canRetry {
+					return true
+				}
+			case Undo, Redo:
+				if canUndo {
+					game.Handle(event)
+				}
+			case Pause:
+				game.PauseToggle()
+				lastAdvance = time.Now()
+				drawStaticBoardParts(screen)
+			case Redraw:
+				drawStaticBoardParts(screen)
+			case SaveFumen:
+				game.message = game.saveFumen(options.FumenFile)
+			default:
+				game.Handle(event)
+			}
+			// While the game is paused, all commands except for Pause, Quit, Retry, and Redraw are ignored
+			// (Handle ignores gameplay events) and the screen only needs to be redrawn when Pause or Redraw
+			// happens.
+			if game.paused {
+				if event == Pause || event == Redraw {
+					game.DrawPauseScreen(screen)
+				}
+				continue
+			}
+		case <-ticker.C:
+			if game.paused {
+				continue
+			}
+			advance()
+		}
+		game.DrawDynamic(screen, false)
+	}
+	// With strict finesse, a fault starts the game over straight away.
+	if game.finesseFailed {
+		return true
+	}
+	game.DrawDynamic(screen, false)
+	notes := game.updateRecords(options.Records)
+	if canRetry {
+		notes = append(notes, "", "Press 'r' to try again")
+	}
+	game.DrawGameOver(screen, notes...)
+	for event := range events {
+		switch {
+		case event == Quit:
+			return false
+		case event == Retry && canRetry:
+			return true
+		}
+	}
+	return false
+}
+
+// Modes can implement practicer to let the player start the game over whenever they like, e.g. to have
+// another go at a puzzle.
+type practicer interface {
+	practice()
+}
+
+// Start the game over with the same seed and mode, and from the same fumen if it was set up from one. The
+// new game has its own replay.
+func (game *Game) restart() {
+	mode, err := parseMode(describeMode(game.mode))
+	if err != nil {
+		panic(err) // The game's own mode description can always be parsed.
+	}
+	fresh := NewGame(game.replay.Seed, mode)
+	if game.replay.Fumen != "" {
+		if err := fresh.LoadFumen(game.replay.Fumen); err != nil {
+			panic(err) // It loaded before, so it will again.
+		}
+	}
+	*game = *fresh
+}
+
+// Add the finished game to the personal bests and save them, returning notes about it for the results screen.
+func (game *Game) updateRecords(records *Records) []string {
+	if records == nil {
+		return nil
+	}
+	previous, hadPrevious := records.Best(game.mode)
+	if !records.Add(game) {
+		if hadPrevious {
+			return []string{"", "Personal best  " + previous.Display}
+		}
+		return nil
+	}
+	notes := []string{"", "New personal best!"}
+	if err := records.Save(); err != nil {
+		notes = append(notes, fmt.Sprintf("Error saving: %s", err))
+	}
+	return notes
+}
+
+// Append the board as fumen data to a file, returning a message saying how that went.
+func (game *Game) saveFumen(filename string) string {
+	if filename == "" {
+		return "Saving fumen is turned off"
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err == nil {
+		_, err = fmt.Fprintln(f, game.Fumen())
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Sprintf("Error saving fumen: %s", err)
+	}
+	return fmt.Sprintf("Saved fumen to %s", filename)
+}
+
+// Find the GameEvent for a console's event. An error reading the input (e.g. because the connection to a
+// remote terminal was lost) quits.
+func UserEvent(event Event) GameEvent {
+	switch event.Type {
+	// Movement: arrow keys or vim controls (h, j, k, l)
+	// Pause: 'p'
+	// Save fumen: 'f'
+	// Retry: 'r'
+	// Undo: 'u', redo: ctrl-r
+	// Change target: 't'
+	// Exit: 'q' or ctrl-c.
+	case EventKey:
+		if event.Ch == 0 { // A special key combo was pressed
+			switch event.Key {
+			case KeyCtrlC:
+				return Quit
+			case KeyCtrlR:
+				return Redo
+			case KeyArrowLeft:
+				return MoveLeft
+			case KeyArrowUp:
+				return Rotate
+			case KeyArrowRight:
+				return MoveRight
+			case KeyArrowDown:
+				return MoveDown
+			case KeySpace:
+				return QuickDrop
+			}
+		} else {
+			switch event.Ch {
+			case 'p':
+				return Pause
+			case 'f':
+				return SaveFumen
+			case 'r':
+				return Retry
+			case 'u':
+				return Undo
+			case 't':
+				return Target
+			case 'q':
+				return Quit
+			case 'h':
+				return MoveLeft
+			case 'k':
+				return Rotate
+			case 'l':
+				return MoveRight
+			case 'j':
+				return MoveDown
+			}
+		}
+	case EventResize:
+		return Redraw
+	case EventError:
+		return Quit
+	}
+	return Redraw // Should never be reached
+}
@@ -0,0 +1,509 @@
+package tetris
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A PuzzlePack is a set of puzzles for puzzle mode (or drills for drill mode, which are puzzles whose goal is
+// to build a particular shape). Each puzzle has a starting board, a fixed sequence of pieces, and a goal to
+// reach with them. Packs are read from files in the format described at ReadPuzzlePack, and the game comes
+// with built-in packs of puzzles and of opener drills.
+type PuzzlePack struct {
+	// A short name (a single word) which identifies the pack in mode descriptions (see ModeOptions.Pack).
+	Name string
+	// A longer name to show the player.
+	Title   string
+	puzzles []*puzzle
+}
+
+// A single puzzle of a PuzzlePack.
+type puzzle struct {
+	title string
+	goal  puzzleGoal
+	// The number of lines to clear (for goalLines) or pieces to place (for goalSurvive).
+	count int
+	// The names of the pieces that the player gets, in order.
+	pieces []string
+	// The rows at the bottom of the board, from top to bottom, with a character for each column (see
+	// puzzleCells).
+	rows []string
+	// For goalBuild, the rows at the bottom of the board once the shape is built, in the same format.
+	target []string
+}
+
+// What the player has to do to solve a puzzle.
+type puzzleGoal int
+
+const (
+	goalNone puzzleGoal = iota
+	// Clear some number of lines.
+	goalLines
+	// Clear every block from the board.
+	goalPerfectClear
+	// Clear three lines at once with a T-spin (see Game.isTSpin).
+	goalTSpinTriple
+	// Place some number of pieces without topping out.
+	goalSurvive
+	// Place every piece where the puzzle's target shows it, without any line clears.
+	goalBuild
+)
+
+var puzzleGoalNames = map[puzzleGoal]string{
+	goalLines:        "lines",
+	goalPerfectClear: "perfect",
+	goalTSpinTriple:  "tspin-triple",
+	goalSurvive:      "survive",
+	goalBuild:        "build",
+}
+
+// The characters that make up the rows of a puzzle's board: an empty cell, garbage, or a block of one of the
+// pieces (in that piece's color).
+const puzzleCells = ".#IOTSZJL"
+
+// The first line of every puzzle pack file.
+const puzzlePackHeader = "go-tetris puzzles 1"
+
+// The number of rows at the top of the board which a puzzle's board has to leave empty, so that there's room
+// for the pieces to come in.
+const puzzleHeadroom = 4
+
+// The built-in packs: puzzles, and drills for common openers.
+var (
+	//go:embed puzzles/basics.txt
+	builtinPuzzles string
+	//go:embed puzzles/openers.txt
+	builtinDrills string
+
+	builtinPuzzlePack = mustReadPuzzlePack(builtinPuzzles)
+	builtinDrillPack  = mustReadPuzzlePack(builtinDrills)
+)
+
+// The puzzle packs which can be played, by name: the built-in packs and any that have been loaded with
+// LoadPuzzlePack.
+var puzzlePacks = map[string]*PuzzlePack{
+	builtinPuzzlePack.Name: builtinPuzzlePack,
+	builtinDrillPack.Name:  builtinDrillPack,
+}
+
+// Read a puzzle pack which is known to be valid.
+func mustReadPuzzlePack(data string) *PuzzlePack {
+	pack, err := ReadPuzzlePack(strings.NewReader(data))
+	if err != nil {
+		panic(err)
+	}
+	return pack
+}
+
+// Find the puzzle pack with the given name, if it's one of the built-in packs or it has been loaded.
+func PuzzlePackNamed(name string) (*PuzzlePack, bool) {
+	pack, ok := puzzlePacks[name]
+	return pack, ok
+}
+
+// Load a puzzle pack from a file, so that its puzzles can be played (and replayed) by the pack's name. A
+// pack can't replace a built-in one.
+func LoadPuzzlePack(filename string) (*PuzzlePack, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pack, err := ReadPuzzlePack(f)
+	if err != nil {
+		return nil, err
+	}
+	if pack.Name == builtinPuzzlePack.Name || pack.Name == builtinDrillPack.Name {
+		return nil, fmt.Errorf("the pack name %q is taken by a built-in pack", pack.Name)
+	}
+	puzzlePacks[pack.Name] = pack
+	return pack, nil
+}
+
+// Read a puzzle pack in a simple line-based text format:
+//
+//	go-tetris puzzles 1
+//	pack basics
+//	title The basics
+//
+//	puzzle Tetris
+//	goal lines 4
+//	pieces I
+//	row #########.
+//	row #########.
+//	...
+//
+// Each puzzle starts with a 'puzzle' line giving its title. Its goal is one of 'lines <n>' (clear n lines),
+// 'perfect' (clear every block from the board), 'tspin-triple' (clear three lines at once with a T-spin),
+// 'survive <n>' (place n pieces without topping out), or 'build' (place every piece where the puzzle's
+// target shows it). The 'pieces' line lists the pieces that the player gets, in order, and the 'row' lines
+// give the rows at the bottom of the board from top to bottom: '.' is an empty cell, '#' is garbage, and a
+// piece's letter is a block of that piece's color. Puzzles with the 'build' goal also have 'target' lines in
+// the same format, giving the rows at the bottom of the board once every piece is in place. Instead of 'row'
+// and 'target' lines, a puzzle can have a 'fumen <data>' line: the first page's field is the board, and the
+// second page's (if there is one) is the target. Blank lines and lines starting with ';' are ignored.
+func ReadPuzzlePack(r io.Reader) (*PuzzlePack, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || scanner.Text() != puzzlePackHeader {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("not a puzzle pack (missing %q header)", puzzlePackHeader)
+	}
+	pack := new(PuzzlePack)
+	for lineNumber := 2; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if err := pack.parseLine(strings.Fields(line)); err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if pack.Name == "" {
+		return nil, fmt.Errorf("missing 'pack <name>' line")
+	}
+	if len(pack.puzzles) == 0 {
+		return nil, fmt.Errorf("the pack has no puzzles")
+	}
+	for i, puzzle := range pack.puzzles {
+		if err := puzzle.check(); err != nil {
+			return nil, fmt.Errorf("puzzle %d (%s): %s", i+1, puzzle.title, err)
+		}
+	}
+	return pack, nil
+}
+
+// Parse a single (non-header) line of a puzzle pack file into the pack.
+func (pack *PuzzlePack) parseLine(fields []string) error {
+	switch fields[0] {
+	case "pack":
+		if len(fields) != 2 || strings.Contains(fields[1], "=") {
+			return fmt.Errorf("expected 'pack <name>', with a name which is a single word")
+		}
+		pack.Name = fields[1]
+		return nil
+	case "title":
+		pack.Title = strings.Join(fields[1:], " ")
+		return nil
+	case "puzzle":
+		pack.puzzles = append(pack.puzzles, &puzzle{title: strings.Join(fields[1:], " ")})
+		return nil
+	}
+	if len(pack.puzzles) == 0 {
+		return fmt.Errorf("%q before the first 'puzzle' line", fields[0])
+	}
+	puzzle := pack.puzzles[len(pack.puzzles)-1]
+	switch fields[0] {
+	case "goal":
+		if len(fields) < 2 {
+			return fmt.Errorf("expected 'goal <goal>'")
+		}
+		for goal, name := range puzzleGoalNames {
+			if name == fields[1] {
+				puzzle.goal = goal
+			}
+		}
+		switch puzzle.goal {
+		case goalNone:
+			return fmt.Errorf("unknown goal %q", fields[1])
+		case goalLines, goalSurvive:
+			if len(fields) != 3 {
+				return fmt.Errorf("expected 'goal %s <n>'", fields[1])
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 1 {
+				return fmt.Errorf("bad count %q", fields[2])
+			}
+			puzzle.count = count
+		default:
+			if len(fields) != 2 {
+				return fmt.Errorf("expected 'goal %s'", fields[1])
+			}
+		}
+	case "pieces":
+		for _, name := range strings.Join(fields[1:], "") {
+			if !strings.ContainsRune("IOTSZJL", name) {
+				return fmt.Errorf("unknown piece %q", name)
+			}
+			puzzle.pieces = append(puzzle.pieces, string(name))
+		}
+	case "row", "target":
+		if len(fields) != 2 || len(fields[1]) != width {
+			return fmt.Errorf("expected '%s <cells>', with %d cells", fields[0], width)
+		}
+		for _, cell := range fields[1] {
+			if !strings.ContainsRune(puzzleCells, cell) {
+				return fmt.Errorf("bad cell %q", cell)
+			}
+		}
+		if fields[0] == "row" {
+			puzzle.rows = append(puzzle.rows, fields[1])
+		} else {
+			puzzle.target = append(puzzle.target, fields[1])
+		}
+	case "fumen":
+		if len(fields) != 2 {
+			return fmt.Errorf("expected 'fumen <data>'")
+		}
+		pages, err := decodeFumen(fields[1])
+		if err != nil {
+			return fmt.Errorf("bad fumen: %s", err)
+		}
+		puzzle.rows = pages[0].field.puzzleRows()
+		if len(pages) > 1 {
+			puzzle.target = pages[1].field.puzzleRows()
+		}
+	default:
+		return fmt.Errorf("unknown field %q", fields[0])
+	}
+	return nil
+}
+
+// Check that a puzzle has everything it needs.
+func (puzzle *puzzle) check() error {
+	switch {
+	case puzzle.goal == goalNone:
+		return fmt.Errorf("missing 'goal' line")
+	case len(puzzle.pieces) == 0:
+		return fmt.Errorf("missing 'pieces' line")
+	case puzzle.goal == goalSurvive && puzzle.count > len(puzzle.pieces):
+		return fmt.Errorf("there are fewer pieces than the player has to place")
+	case len(puzzle.rows) > height-puzzleHeadroom || len(puzzle.target) > height-puzzleHeadroom:
+		return fmt.Errorf("the board has more than %d rows", height-puzzleHeadroom)
+	case (puzzle.goal == goalBuild) != (puzzle.target != nil):
+		return fmt.Errorf("puzzles have 'target' lines if and only if their goal is 'build'")
+	}
+	if puzzle.goal == goalBuild {
+		return puzzle.checkTarget()
+	}
+	return nil
+}
+
+// Check that a puzzle's target can be built from its board and pieces, as far as counting blocks goes: it
+// has the same blocks as the board plus four of each piece's letter for each time the piece comes, and no
+// complete rows.
+func (puzzle *puzzle) checkTarget() error {
+	board := boardCells(puzzle.rows)
+	target := boardCells(puzzle.target)
+	for position, cell := range board {
+		if target[position] != cell {
+			return fmt.Errorf("the target doesn't have all of the board's blocks")
+		}
+	}
+	counts := make(map[rune]int)
+	for position, cell := range target {
+		if _, ok := board[position]; !ok {
+			counts[cell]++
+		}
+	}
+	for _, name := range puzzle.pieces {
+		counts[rune(name[0])] -= 4
+	}
+	for cell, count := range counts {
+		if count != 0 {
+			return fmt.Errorf("the target's %c blocks don't match the pieces", cell)
+		}
+	}
+	for _, row := range puzzle.target {
+		if !strings.Contains(row, ".") {
+			return fmt.Errorf("the target has a complete row")
+		}
+	}
+	return nil
+}
+
+// Find the non-empty cells of rows at the bottom of the board (like a puzzle's rows), by position.
+func boardCells(rows []string) map[Vector]rune {
+	cells := make(map[Vector]rune)
+	top := height - len(rows)
+	for i, row := range rows {
+		for x, cell := range row {
+			if cell != '.' {
+				cells[Vector{x, top + i}] = cell
+			}
+		}
+	}
+	return cells
+}
+
+// Find the color of each non-empty cell of rows at the bottom of the board (like a puzzle's rows), coloring
+// blocks like the given pieces.
+func boardColors(rows []string, pieces []Piece) ColorMap {
+	colors := make(ColorMap)
+	for position, cell := range boardCells(rows) {
+		colors[position] = garbageColor
+		for _, piece := range pieces {
+			if piece.name == string(cell) {
+				colors[position] = piece.color
+			}
+		}
+	}
+	return colors
+}
+
+// Describe the puzzle's goal, like "Clear 4 lines".
+func (puzzle *puzzle) goalText() string {
+	switch puzzle.goal {
+	case goalLines:
+		return fmt.Sprintf("Clear %d lines", puzzle.count)
+	case goalPerfectClear:
+		return "Perfect clear"
+	case goalTSpinTriple:
+		return "T-spin triple"
+	case goalSurvive:
+		return fmt.Sprintf("Place %d pieces", puzzle.count)
+	case goalBuild:
+		return "Build the shape"
+	}
+	return ""
+}
+
+// In puzzle mode, the player is given a board, a fixed sequence of pieces, and a goal to reach with them
+// (see PuzzlePack). The game ends when the goal is reached or the pieces run out. Pieces rotate with SRS, so
+// that T-spins are possible.
+//
+// Drill mode is the same mode with the built-in pack of opener drills. Their goal is to build a target
+// shape, which is shown in outline: the board is checked against the target whenever a piece locks, and
+// blocks in the wrong places are marked. Either way, the player can try again straight away.
+type puzzleMode struct {
+	modeBase
+	puzzle *puzzle
+	// For goalBuild, the colors of the target's cells, the cells which don't match the target, and the
+	// numbers of pieces placed and lines cleared when the board was last checked against the target.
+	target        ColorMap
+	mistakes      map[Vector]bool
+	checkedPieces int
+	checkedLines  int
+}
+
+// Make a puzzle mode. The pack and puzzle in the options must exist; NewMode checks that.
+func newPuzzleMode(base modeBase) Mode {
+	return &puzzleMode{modeBase: base, puzzle: puzzlePacks[base.options.Pack].puzzles[base.options.Puzzle-1]}
+}
+
+func (mode *puzzleMode) Setup(game *Game) {
+	game.setRuleset(guidelineRuleset)
+	game.board.cells = boardColors(mode.puzzle.rows, game.pieces)
+	if mode.puzzle.goal == goalBuild {
+		mode.target = boardColors(mode.puzzle.target, game.pieces)
+	}
+	game.queue = append([]string{}, mode.puzzle.pieces...)
+	game.board.currentPiece = game.GeneratePiece()
+	game.board.currentPosition = game.board.currentPiece.initialLocation
+	game.nextPiece = game.GeneratePiece()
+}
+
+func (mode *puzzleMode) Update(game *Game) {
+	changed := game.piecesPlaced != mode.checkedPieces || game.lines != mode.checkedLines
+	if mode.puzzle.goal == goalBuild && changed {
+		mode.checkedPieces = game.piecesPlaced
+		mode.checkedLines = game.lines
+		mode.checkTarget(game)
+	}
+	if mode.solved(game) {
+		game.finish()
+	}
+}
+
+// Compare the board with the target, noting any blocks which aren't where the target has them.
+func (mode *puzzleMode) checkTarget(game *Game) {
+	mode.mistakes = make(map[Vector]bool)
+	for position, color := range game.board.cells {
+		if target, ok := mode.target[position]; !ok || target != color {
+			mode.mistakes[position] = true
+		}
+	}
+}
+
+// Show the blocks which don't match the target as mistakes, and the parts of the target which haven't been
+// filled in yet as outlines.
+func (mode *puzzleMode) markCell(game *Game, position Vector) (Color, cellAppearance, bool) {
+	if mode.mistakes[position] {
+		return game.board.cells[position], cellMistake, true
+	}
+	if game.board.CellColor(position) != BackgroundColor {
+		return 0, 0, false
+	}
+	if color, ok := mode.target[position]; ok {
+		return color, cellOutline, true
+	}
+	return 0, 0, false
+}
+
+// Puzzles and drills can be retried (see practicer).
+func (mode *puzzleMode) practice() {}
+
+// Whether the puzzle's goal has been reached. Like in sprint mode, lines count as soon as they're completed.
+func (mode *puzzleMode) solved(game *Game) bool {
+	switch mode.puzzle.goal {
+	case goalLines:
+		return game.lines+len(game.clearingRows) >= mode.puzzle.count
+	case goalPerfectClear:
+		return game.clearingRows != nil && len(game.board.cells) == len(game.clearingRows)*width
+	case goalTSpinTriple:
+		return game.tSpin && game.chain == 0 && len(game.clearingRows) == 3
+	case goalSurvive:
+		return game.piecesPlaced >= mode.puzzle.count
+	case goalBuild:
+		return len(mode.mistakes) == 0 && len(game.board.cells) == len(mode.target)
+	}
+	return false
+}
+
+// The number of pieces that the player hasn't placed yet, including the current one.
+func (mode *puzzleMode) piecesLeft(game *Game) int {
+	left := len(game.queue)
+	if game.nextPiece != nil {
+		left++
+	}
+	if game.board.currentPiece != nil {
+		left++
+	}
+	return left
+}
+
+func (mode *puzzleMode) title(game *Game) string {
+	if game.finished {
+		return "SOLVED!"
+	}
+	return "FAILED"
+}
+
+func (mode *puzzleMode) Status(game *Game) []string {
+	name := strings.ToUpper(mode.name[:1]) + mode.name[1:]
+	count := len(puzzlePacks[mode.options.Pack].puzzles)
+	status := []string{
+		fmt.Sprintf("%s %d/%d", name, mode.options.Puzzle, count),
+		mode.puzzle.goalText(),
+		fmt.Sprintf("Pieces %d left", mode.piecesLeft(game)),
+	}
+	if len(mode.mistakes) > 0 {
+		status = append(status, "Retry with 'r'")
+	}
+	return status
+}
+
+func (mode *puzzleMode) Results(game *Game) []string {
+	return []string{
+		mode.puzzle.title,
+		mode.puzzle.goalText(),
+		"",
+		fmt.Sprintf("Pieces  %d", game.piecesPlaced),
+		fmt.Sprintf("Time    %s", FormatMillis(game.TimeSinceFirstInput())),
+	}
+}
+
+// A solved puzzle's result is the time it took. Solved puzzles are the ones with personal bests.
+func (mode *puzzleMode) Result(game *Game) (Result, bool) {
+	millis := game.TimeSinceFirstInput()
+	return Result{Value: millis, LowerIsBetter: true, Display: FormatMillis(millis)}, game.finished
+}
@@ -0,0 +1,206 @@
+package tetris
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Start a game of a puzzle from the built-in pack.
+func newPuzzleGame(t *testing.T, number int) *Game {
+	mode, err := NewMode("puzzle", ModeOptions{Puzzle: number})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewGame(1, mode)
+}
+
+// Play a puzzle, placing each piece by making the given moves with it and then dropping it.
+func playPuzzle(t *testing.T, number int, plan [][]GameEvent) *Game {
+	game := newPuzzleGame(t, number)
+	for _, events := range plan {
+		for _, event := range events {
+			game.Handle(event)
+		}
+		game.Handle(QuickDrop)
+		game.Advance(1000)
+	}
+	game.Advance(1000)
+	return game
+}
+
+// Search for a way to solve a puzzle by placing each of its pieces in every rotation and column, returning
+// the moves for each piece (see playPuzzle), or nil if there isn't one.
+func solvePuzzle(t *testing.T, number, pieces int, plan [][]GameEvent) [][]GameEvent {
+	game := playPuzzle(t, number, plan)
+	if game.Finished() {
+		return plan
+	}
+	if pieces == 0 || game.Over() {
+		return nil
+	}
+	for rotations := 0; rotations < 4; rotations++ {
+		for dx := -5; dx <= 5; dx++ {
+			var events []GameEvent
+			for i := 0; i < rotations; i++ {
+				events = append(events, Rotate)
+			}
+			for i := 0; i < abs(dx); i++ {
+				if dx < 0 {
+					events = append(events, MoveLeft)
+				} else {
+					events = append(events, MoveRight)
+				}
+			}
+			next := append(append([][]GameEvent(nil), plan...), events)
+			if solution := solvePuzzle(t, number, pieces-1, next); solution != nil {
+				return solution
+			}
+		}
+	}
+	return nil
+}
+
+func TestBuiltinPuzzlesSolvable(t *testing.T) {
+	for i, puzzle := range builtinPuzzlePack.puzzles {
+		if puzzle.goal != goalLines && puzzle.goal != goalPerfectClear {
+			continue
+		}
+		solution := solvePuzzle(t, i+1, len(puzzle.pieces), nil)
+		if solution == nil {
+			t.Errorf("couldn't solve puzzle %d (%s)", i+1, puzzle.title)
+			continue
+		}
+		game := playPuzzle(t, i+1, solution)
+		if result, ok := game.mode.Result(game); !ok || !result.LowerIsBetter {
+			t.Errorf("solving puzzle %d (%s) got result %+v, %t", i+1, puzzle.title, result, ok)
+		}
+	}
+}
+
+func TestTSpinTriplePuzzle(t *testing.T) {
+	const number = 5
+	if goal := builtinPuzzlePack.puzzles[number-1].goal; goal != goalTSpinTriple {
+		t.Fatalf("puzzle %d has goal %s", number, puzzleGoalNames[goal])
+	}
+	// Sliding the T under the overhang and rotating it into the slot is a T-spin.
+	game := newPuzzleGame(t, number)
+	for game.board.moveIfPossible(Vector{0, 1}) {
+	}
+	for _, event := range []GameEvent{MoveLeft, Rotate, QuickDrop} {
+		game.Handle(event)
+	}
+	game.Advance(1000)
+	if !game.Finished() || game.Lines() != 3 {
+		t.Errorf("the T-spin triple didn't solve the puzzle (%d lines)\n%s", game.Lines(), dumpBoard(game))
+	}
+	// Just dropping the T in doesn't clear anything.
+	game = playPuzzle(t, number, [][]GameEvent{{MoveLeft}})
+	if game.Finished() || !game.Over() {
+		t.Errorf("dropping the T solved the puzzle\n%s", dumpBoard(game))
+	}
+}
+
+func TestSurvivePuzzle(t *testing.T) {
+	const number = 6
+	puzzle := builtinPuzzlePack.puzzles[number-1]
+	game := newPuzzleGame(t, number)
+	botPlay(game, 60000, 20)
+	if !game.Finished() || game.piecesPlaced != puzzle.count {
+		t.Fatalf("the bot placed %d pieces; want %d\n%s", game.piecesPlaced, puzzle.count, dumpBoard(game))
+	}
+	replayed, err := game.Replay().Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !replayed.Finished() || replayed.clock != game.clock {
+		t.Errorf("the replay doesn't match the game")
+	}
+}
+
+func TestReadPuzzlePackErrors(t *testing.T) {
+	for _, test := range []struct {
+		pack, want string
+	}{
+		{"go-tetris puzzles 2\n", "not a puzzle pack"},
+		{"go-tetris puzzles 1\npack x\ngoal lines 1\n", "before the first 'puzzle' line"},
+		{"go-tetris puzzles 1\npack x\n", "no puzzles"},
+		{"go-tetris puzzles 1\npuzzle a\ngoal lines 1\npieces I\n", "missing 'pack"},
+		{"go-tetris puzzles 1\npack x\npuzzle a\npieces Q\n", "unknown piece"},
+		{"go-tetris puzzles 1\npack x\npuzzle a\npieces I\n", "missing 'goal' line"},
+		{"go-tetris puzzles 1\npack x\npuzzle a\ngoal lines 0\n", "bad count"},
+		{"go-tetris puzzles 1\npack x\npuzzle a\ngoal survive 3\npieces I\n", "fewer pieces"},
+		{"go-tetris puzzles 1\npack x\npuzzle a\ngoal lines 1\npieces I\nrow ###\n", "10 cells"},
+		{"go-tetris puzzles 1\npack x\npuzzle a\ngoal lines 1\npieces I\n" +
+			strings.Repeat("row #########.\n", 15), "more than 14 rows"},
+	} {
+		_, err := ReadPuzzlePack(strings.NewReader(test.pack))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("reading the pack %q gave the error %v; want one about %q", test.pack, err, test.want)
+		}
+	}
+}
+
+func TestLoadPuzzlePack(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "pack.txt")
+	data := "go-tetris puzzles 1\npack testpack\ntitle Tests\n\npuzzle Drop it\ngoal lines 1\npieces I\n" +
+		"row ....######\n"
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pack, err := LoadPuzzlePack(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if puzzlePacks["testpack"] != pack {
+		t.Fatalf("the loaded pack can't be found by name")
+	}
+	mode, err := NewMode("puzzle", ModeOptions{Pack: "testpack", Puzzle: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(1, mode)
+	game.Handle(MoveLeft)
+	game.Handle(MoveLeft)
+	game.Handle(MoveLeft)
+	game.Handle(QuickDrop)
+	game.Advance(1000)
+	if !game.Finished() {
+		t.Errorf("the loaded puzzle wasn't solved\n%s", dumpBoard(game))
+	}
+	if _, err := NewMode("puzzle", ModeOptions{Pack: "testpack", Puzzle: 2}); err == nil {
+		t.Errorf("NewMode accepted a puzzle number past the end of the pack")
+	}
+
+	builtin := strings.Replace(data, "pack testpack", "pack "+builtinPuzzlePack.Name, 1)
+	if err := os.WriteFile(filename, []byte(builtin), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPuzzlePack(filename); err == nil {
+		t.Errorf("a loaded pack replaced the built-in one")
+	}
+}
+
+func TestDrawPuzzleSelect(t *testing.T) {
+	pack := builtinPuzzlePack
+	var modes []Mode
+	for i := range pack.puzzles {
+		mode, err := NewMode("puzzle", ModeOptions{Puzzle: i + 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		modes = append(modes, mode)
+	}
+	best := Record{Result: Result{Display: "0:01.234"}}
+	records := &Records{best: map[string]Record{describeMode(modes[1]): best}}
+	b := NewBuffer(ScreenWidth, ScreenHeight)
+	drawPuzzleSelect(b, pack, modes, records, 4)
+	text := b.Text()
+	for _, want := range []string{pack.Title, pack.puzzles[0].title, pack.puzzles[4].title, "0:01.234"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("the puzzle select screen doesn't show %q:\n%s", want, text)
+		}
+	}
+}
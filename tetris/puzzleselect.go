@@ -0,0 +1,86 @@
+package tetris
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SelectPuzzle shows the level select screen for the puzzle pack of a puzzle or drill mode (see
+// ModeOptions.Pack), starting with the mode's own puzzle selected. It lists the pack's puzzles, marking the
+// ones which have been solved (those with a personal best in records, which may be nil) with their best
+// times. The player picks a puzzle with the arrow keys (or 'j' and 'k') and enter or space, and it's
+// returned as the same mode with the mode's other options. If the player quits instead, the mode is nil.
+func SelectPuzzle(console Console, mode Mode, records *Records) (Mode, error) {
+	options := mode.Options()
+	pack, ok := puzzlePacks[options.Pack]
+	if !ok {
+		return nil, fmt.Errorf("the %s mode doesn't have puzzles", mode.Name())
+	}
+	modes := make([]Mode, len(pack.puzzles))
+	for i := range pack.puzzles {
+		options.Puzzle = i + 1
+		puzzleMode, err := NewMode(mode.Name(), options)
+		if err != nil {
+			return nil, err
+		}
+		modes[i] = puzzleMode
+	}
+	selected := mode.Options().Puzzle - 1
+	for {
+		drawPuzzleSelect(console, pack, modes, records, selected)
+		switch event := console.PollEvent(); {
+		case event.Type == EventError:
+			return nil, event.Err
+		case event.Type != EventKey:
+		case event.Key == KeyArrowUp || event.Ch == 'k':
+			if selected > 0 {
+				selected--
+			}
+		case event.Key == KeyArrowDown || event.Ch == 'j':
+			if selected < len(modes)-1 {
+				selected++
+			}
+		case event.Key == KeyEnter || event.Key == KeySpace:
+			return modes[selected], nil
+		case event.Key == KeyCtrlC || event.Ch == 'q':
+			return nil, nil
+		}
+	}
+}
+
+// Draw the level select screen (see SelectPuzzle) with the puzzle at the given index selected. modes holds
+// the puzzle mode for each of the pack's puzzles.
+func drawPuzzleSelect(screen Screen, pack *PuzzlePack, modes []Mode, records *Records, selected int) {
+	title := pack.Title
+	if title == "" {
+		title = pack.Name
+	}
+	items := make([]string, len(modes))
+	for i := range modes {
+		best := ""
+		if records != nil {
+			if record, ok := records.Best(modes[i]); ok {
+				best = "* " + record.Display
+			}
+		}
+		items[i] = fmt.Sprintf("%2d. %-20s %s", i+1, truncate(pack.puzzles[i].title, 20), best)
+	}
+	puzzle := pack.puzzles[selected]
+	DrawMenu(screen, title, items, selected, []string{
+		"Goal    " + puzzle.goalText(),
+		"Pieces  " + strings.Join(puzzle.pieces, " "),
+		"",
+		"Choose with up/down or 'k'/'j',",
+		"and play with enter or space.",
+		"Quit with ctrl-c or 'q'.",
+	})
+}
+
+// Cut a string down to at most n characters.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
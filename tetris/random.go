@@ -0,0 +1,30 @@
+package tetris
+
+// A small deterministic pseudo-random number generator (splitmix64). The game uses this rather than
+// math/rand so that a game can be reproduced exactly from its seed, and because the entire generator state is
+// a single integer that is trivial to save and restore.
+type Random struct {
+	state uint64
+}
+
+// Create a new generator from a seed.
+func NewRandom(seed int64) *Random {
+	return &Random{uint64(seed)}
+}
+
+// Produce the next 64 random bits.
+func (r *Random) next() uint64 {
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Return a random integer in [0, n). It panics if n <= 0.
+func (r *Random) Intn(n int) int {
+	if n <= 0 {
+		panic("tetris: invalid argument to Intn")
+	}
+	return int(r.next() % uint64(n))
+}
@@ -0,0 +1,83 @@
+package tetris
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Records holds the player's personal best result (see Mode.Result) in each mode. They're kept in a JSON file
+// in the user's config directory. Modes with different options (like a 20 line sprint and a 40 line sprint)
+// have separate records.
+type Records struct {
+	filename string
+	best     map[string]Record
+}
+
+// A Record is a personal best.
+type Record struct {
+	Result
+	Date time.Time
+}
+
+// Find the file where records are kept.
+func recordsFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-tetris", "records.json"), nil
+}
+
+// Load the saved records. If nothing has been saved yet, the records are empty.
+func LoadRecords() (*Records, error) {
+	filename, err := recordsFile()
+	if err != nil {
+		return nil, err
+	}
+	records := &Records{filename: filename, best: make(map[string]Record)}
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &records.best); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Save the records.
+func (records *Records) Save() error {
+	data, err := json.MarshalIndent(records.best, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(records.filename), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(records.filename, data, 0644)
+}
+
+// Find the personal best for a mode, if there is one.
+func (records *Records) Best(mode Mode) (Record, bool) {
+	record, ok := records.best[describeMode(mode)]
+	return record, ok
+}
+
+// Record a finished game, returning whether it's a new personal best.
+func (records *Records) Add(game *Game) bool {
+	result, ok := game.mode.Result(game)
+	if !ok {
+		return false
+	}
+	name := describeMode(game.mode)
+	if best, ok := records.best[name]; ok && !result.betterThan(best.Result) {
+		return false
+	}
+	records.best[name] = Record{result, time.Now()}
+	return true
+}
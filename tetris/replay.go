@@ -0,0 +1,254 @@
+package tetris
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A Replay contains everything needed to reproduce a game exactly: the seed for the piece generator and every
+// input along with the game clock time at which it was made. It also holds the result (score, lines, and
+// time) claimed by whoever produced it, which can be checked by simulating the game again.
+type Replay struct {
+	Seed int64
+	// The game's mode and its options, like "sprint lines=40".
+	Mode string
+	// The fumen the game was set up from, if any (see Game.LoadFumen).
+	Fumen  string
+	Inputs []ReplayInput
+	Score  int
+	Lines  int
+	// The game clock time (in milliseconds) at which the replay ends.
+	Time int
+}
+
+// A single recorded input.
+type ReplayInput struct {
+	Time  int
+	Event GameEvent
+}
+
+// The first line of every replay file.
+const replayHeader = "go-tetris replay 1"
+
+// The longest game that a replay can hold (in milliseconds of game time): a day.
+const maxReplayTime = 24 * 60 * 60 * 1000
+
+// Write a replay in a simple line-based text format:
+//
+//	go-tetris replay 1
+//	seed 1234
+//	mode sprint lines=40
+//	fumen v115@vhAAgH (only for games set up from a fumen)
+//	input 840 left
+//	input 1230 drop
+//	...
+//	score 300
+//	lines 2
+//	time 45678
+func (replay *Replay) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, replayHeader)
+	fmt.Fprintf(bw, "seed %d\n", replay.Seed)
+	fmt.Fprintf(bw, "mode %s\n", replay.Mode)
+	if replay.Fumen != "" {
+		fmt.Fprintf(bw, "fumen %s\n", replay.Fumen)
+	}
+	for _, input := range replay.Inputs {
+		fmt.Fprintf(bw, "input %d %s\n", input.Time, input.Event)
+	}
+	fmt.Fprintf(bw, "score %d\n", replay.Score)
+	fmt.Fprintf(bw, "lines %d\n", replay.Lines)
+	fmt.Fprintf(bw, "time %d\n", replay.Time)
+	return bw.Flush()
+}
+
+// Read a replay in the format produced by Replay.Write.
+func ReadReplay(r io.Reader) (*Replay, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || scanner.Text() != replayHeader {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("not a replay file (missing %q header)", replayHeader)
+	}
+	replay := &Replay{Mode: "classic"}
+	for lineNumber := 2; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if err := replay.parseLine(fields); err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+// Parse a single (non-header) line of a replay file into the replay.
+func (replay *Replay) parseLine(fields []string) error {
+	var err error
+	switch fields[0] {
+	case "input":
+		if len(fields) != 3 {
+			return fmt.Errorf("expected 'input <time> <event>'")
+		}
+		var input ReplayInput
+		if input.Time, err = strconv.Atoi(fields[1]); err != nil {
+			return fmt.Errorf("bad input time %q", fields[1])
+		}
+		var ok bool
+		if input.Event, ok = ParseGameEvent(fields[2]); !ok || !input.Event.IsGameplay() {
+			return fmt.Errorf("bad input event %q", fields[2])
+		}
+		replay.Inputs = append(replay.Inputs, input)
+		return nil
+	case "mode":
+		if len(fields) < 2 {
+			return fmt.Errorf("expected 'mode <name> [<option>=<value> ...]'")
+		}
+		replay.Mode = strings.Join(fields[1:], " ")
+		return nil
+	case "fumen":
+		if len(fields) != 2 {
+			return fmt.Errorf("expected 'fumen <data>'")
+		}
+		replay.Fumen = fields[1]
+		return nil
+	case "seed":
+		if len(fields) == 2 {
+			replay.Seed, err = strconv.ParseInt(fields[1], 10, 64)
+		}
+	case "score":
+		if len(fields) == 2 {
+			replay.Score, err = strconv.Atoi(fields[1])
+		}
+	case "lines":
+		if len(fields) == 2 {
+			replay.Lines, err = strconv.Atoi(fields[1])
+		}
+	case "time":
+		if len(fields) == 2 {
+			replay.Time, err = strconv.Atoi(fields[1])
+		}
+	default:
+		return fmt.Errorf("unknown field %q", fields[0])
+	}
+	if len(fields) != 2 || err != nil {
+		return fmt.Errorf("expected '%s <number>'", fields[0])
+	}
+	return nil
+}
+
+// A ReplayPlayer runs the game described by a replay, applying each of the replay's inputs when the game
+// clock reaches its time.
+type ReplayPlayer struct {
+	replay *Replay
+	game   *Game
+	// The index of the next input to apply.
+	next int
+}
+
+// Check that a replay describes something which could have come from a real game, before playing it back:
+// its numbers are in range, and its inputs are gameplay inputs in order, up to the end of the replay.
+func (replay *Replay) check() error {
+	if replay.Time < 0 || replay.Time > maxReplayTime {
+		return fmt.Errorf("the replay's time (%dms) must be from 0 to %dms", replay.Time, maxReplayTime)
+	}
+	if replay.Score < 0 || replay.Lines < 0 {
+		return fmt.Errorf("the replay's score and lines must not be negative")
+	}
+	previous := 0
+	for i, input := range replay.Inputs {
+		if !input.Event.IsGameplay() {
+			return fmt.Errorf("input %d (%s) isn't a gameplay input", i+1, input.Event)
+		}
+		if input.Time < previous {
+			return fmt.Errorf("input %d (%s at %dms) is earlier than the previous input", i+1, input.Event,
+				input.Time)
+		}
+		if input.Time > replay.Time {
+			return fmt.Errorf("input %d (%s at %dms) comes after the end of the replay at %dms", i+1,
+				input.Event, input.Time, replay.Time)
+		}
+		previous = input.Time
+	}
+	return nil
+}
+
+// Start playing back a replay from the beginning. An error is returned if the game can't be set up as the
+// replay describes (for instance, if its mode is unknown), or if the replay is invalid (see Replay.check).
+func NewReplayPlayer(replay *Replay) (*ReplayPlayer, error) {
+	if err := replay.check(); err != nil {
+		return nil, err
+	}
+	mode, err := parseMode(replay.Mode)
+	if err != nil {
+		return nil, err
+	}
+	game := NewGame(replay.Seed, mode)
+	if replay.Fumen != "" {
+		if err := game.LoadFumen(replay.Fumen); err != nil {
+			return nil, err
+		}
+	}
+	return &ReplayPlayer{replay: replay, game: game}, nil
+}
+
+// The game being played back.
+func (player *ReplayPlayer) Game() *Game {
+	return player.game
+}
+
+// Whether the game clock has reached the end of the replay (or the game is over).
+func (player *ReplayPlayer) Done() bool {
+	return player.game.over || player.game.clock >= player.replay.Time
+}
+
+// Advance the game to the given clock time (or the end of the replay, if that comes first), applying any
+// inputs on the way. An error is returned if the replay contains inputs that couldn't have come from a real
+// game: inputs that are out of order, or that come after the game ended.
+func (player *ReplayPlayer) AdvanceTo(millis int) error {
+	game := player.game
+	for ; player.next < len(player.replay.Inputs); player.next++ {
+		input := player.replay.Inputs[player.next]
+		if input.Time > millis {
+			break
+		}
+		if input.Time < game.clock {
+			return fmt.Errorf("input %d (%s at %dms) is earlier than the previous input", player.next+1,
+				input.Event, input.Time)
+		}
+		game.Advance(input.Time - game.clock)
+		if game.over {
+			return fmt.Errorf("input %d (%s at %dms) comes after the game ended at %dms", player.next+1,
+				input.Event, input.Time, game.clock)
+		}
+		game.Handle(input.Event)
+	}
+	if millis > player.replay.Time {
+		millis = player.replay.Time
+	}
+	if millis > game.clock {
+		game.Advance(millis - game.clock)
+	}
+	return nil
+}
+
+// Run the game described by a replay headlessly (no drawing, and as fast as possible) until the end of the
+// replay. An error is returned if the replay contains inputs that couldn't have come from a real game.
+func (replay *Replay) Simulate() (*Game, error) {
+	player, err := NewReplayPlayer(replay)
+	if err != nil {
+		return nil, err
+	}
+	if err := player.AdvanceTo(replay.Time); err != nil {
+		return nil, err
+	}
+	return player.game, nil
+}
@@ -0,0 +1,168 @@
+package tetris
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A replay saved by the web page (running in the browser as WebAssembly) plays back the same way here.
+func TestBrowserReplay(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "browser.replay"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay, err := ReadReplay(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	game, err := replay.Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Score() != replay.Score || game.Lines() != replay.Lines || game.Clock() != replay.Time {
+		t.Errorf("the replay ends with score %d, %d lines at %dms; want %d, %d at %dms", game.Score(),
+			game.Lines(), game.Clock(), replay.Score, replay.Lines, replay.Time)
+	}
+	var b bytes.Buffer
+	if err := game.Replay().Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), data) {
+		t.Errorf("the game played back from the replay saves\n%s\nwant\n%s", b.Bytes(), data)
+	}
+}
+
+// Games played by the bot in every mode save exactly the replays in testdata/modes.replays, whatever they're
+// built for (this also runs under GOOS=js GOARCH=wasm, as the web page does). Run it with UPDATE_GOLDEN=1 to
+// save them again after a change to the rules.
+func TestReplayGolden(t *testing.T) {
+	var b bytes.Buffer
+	for _, name := range []string{"classic", "sprint", "cheese", "master", "survival", "puzzle"} {
+		mode, err := NewMode(name, ModeOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		game := NewGame(12345, mode)
+		for i := 0; i < 600 && !game.Over(); i++ {
+			game.Advance(50 + i%7)
+			if i%3 == 0 {
+				for _, event := range botMoves(game) {
+					game.Handle(event)
+				}
+			}
+		}
+		if err := game.Replay().Write(&b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join("testdata", "modes.replays")
+	if os.Getenv("UPDATE_GOLDEN") != "" {
+		if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Errorf("the games saved the replays\n%s\nwant\n%s", b.Bytes(), want)
+	}
+}
+
+func TestReadReplayErrors(t *testing.T) {
+	for _, test := range []struct {
+		replay, want string
+	}{
+		{"", "missing"},
+		{"go-tetris replay 2\n", "missing"},
+		{replayHeader + "\nseed x\n", "seed <number>"},
+		{replayHeader + "\nscore 1 2\n", "score <number>"},
+		{replayHeader + "\ninput 100\n", "input <time> <event>"},
+		{replayHeader + "\ninput soon left\n", "bad input time"},
+		{replayHeader + "\ninput 100 jump\n", "bad input event"},
+		{replayHeader + "\ninput 100 pause\n", "bad input event"},
+		{replayHeader + "\nmode\n", "expected 'mode"},
+		{replayHeader + "\nfumen\n", "expected 'fumen"},
+		{replayHeader + "\nlevel 3\n", "unknown field"},
+	} {
+		_, err := ReadReplay(strings.NewReader(test.replay))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("reading %q gave the error %v; want one about %q", test.replay, err, test.want)
+		}
+	}
+}
+
+func TestIllegalReplays(t *testing.T) {
+	for _, test := range []struct {
+		replay Replay
+		want   string
+	}{
+		{Replay{Mode: "classic", Time: -1}, "time"},
+		{Replay{Mode: "classic", Time: maxReplayTime + 1}, "time"},
+		{Replay{Mode: "classic", Time: 1000, Score: -100}, "negative"},
+		{Replay{Mode: "classic", Time: 1000, Inputs: []ReplayInput{{-5, MoveLeft}}}, "earlier"},
+		{Replay{Mode: "classic", Time: 1000, Inputs: []ReplayInput{{500, MoveLeft}, {400, QuickDrop}}},
+			"input 2 (drop at 400ms) is earlier"},
+		{Replay{Mode: "classic", Time: 1000, Inputs: []ReplayInput{{1001, MoveLeft}}}, "after the end"},
+		{Replay{Mode: "classic", Time: 1000, Inputs: []ReplayInput{{10, Pause}}}, "gameplay"},
+		{Replay{Mode: "classic", Time: 1000, Fumen: "115@vhAAgH"}, "not fumen"},
+		{Replay{Mode: "classic", Time: 1000, Fumen: "v115@vhA/gH"}, "doesn't fit"},
+		{Replay{Mode: "sprint lines=-1", Time: 1000}, "negative"},
+	} {
+		if _, err := test.replay.Simulate(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("simulating %+v gave the error %v; want one about %q", test.replay, err, test.want)
+		}
+	}
+
+	// Inputs after the game is over couldn't have been made.
+	replay := Replay{Mode: "classic", Time: 600000}
+	for i := 0; i < 100; i++ {
+		replay.Inputs = append(replay.Inputs, ReplayInput{i * 10, QuickDrop})
+	}
+	if _, err := replay.Simulate(); err == nil || !strings.Contains(err.Error(), "after the game ended") {
+		t.Errorf("simulating drops after the game ended gave the error %v", err)
+	}
+}
+
+// Changing any of the results recorded in a replay is noticed when it's simulated.
+func TestReplayTampering(t *testing.T) {
+	mode, err := NewMode("classic", ModeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(7, mode)
+	for i := 0; i < 300 && !game.Over(); i++ {
+		game.Advance(40)
+		if i%3 == 0 {
+			for _, event := range botMoves(game) {
+				game.Handle(event)
+			}
+		}
+	}
+	replay := game.Replay()
+	if replay.Lines == 0 {
+		t.Fatalf("the bot didn't clear any lines")
+	}
+	for i, tamper := range []func(*Replay){
+		func(replay *Replay) { replay.Score += 100 },
+		func(replay *Replay) { replay.Lines++ },
+		func(replay *Replay) { replay.Seed++ },
+		func(replay *Replay) { replay.Inputs = replay.Inputs[:len(replay.Inputs)/2] },
+	} {
+		tampered := *replay
+		tamper(&tampered)
+		simulated, err := tampered.Simulate()
+		if err == nil && simulated.Score() == tampered.Score && simulated.Lines() == tampered.Lines &&
+			simulated.Clock() == tampered.Time {
+			t.Errorf("tampering %d wasn't noticed", i+1)
+		}
+	}
+	if simulated, err := replay.Simulate(); err != nil || simulated.Score() != replay.Score ||
+		simulated.Lines() != replay.Lines || simulated.Clock() != replay.Time {
+		t.Errorf("the untouched replay doesn't reproduce its results (%v)", err)
+	}
+}
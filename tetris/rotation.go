@@ -0,0 +1,205 @@
+package tetris
+
+// A rotationSystem decides the shapes of the pieces in each of their rotations and where they spawn, and
+// where a piece may go instead (a "kick") when rotating it in place would make it overlap something.
+type rotationSystem struct {
+	pieces func() []Piece
+	// Find the offsets to try, in order, when the current piece has been rotated into a position where it
+	// overlaps something. If none of them work, the rotation fails.
+	kicks func(board *Board) []Vector
+}
+
+var (
+	// The rotations that this game has always had, with no kicks.
+	classicRotation = &rotationSystem{pieces: tetrisPieces, kicks: noKicks}
+	// The Arika rotation system (ARS) from the Tetris: The Grand Master games.
+	arsRotation = &rotationSystem{pieces: arsPieces, kicks: arsKicks}
+	// The Super Rotation System (SRS) from the tetris guideline, which most modern tetris games use.
+	srsRotation = &rotationSystem{pieces: srsPieces, kicks: srsKicks}
+)
+
+func noKicks(board *Board) []Vector {
+	return nil
+}
+
+// The ARS pieces. They spawn flat side up (pointing down) in a 3x3 box (4x4 for the I), and their rotations
+// are aligned to the bottom of the box rather than rotating about its center. Rotation is clockwise.
+func arsPieces() []Piece {
+	spawn := Vector{3, -1}
+	return []Piece{
+		{
+			rotations:       []PieceInstance{{{1, 1}, {2, 1}, {1, 2}, {2, 2}}},
+			initialLocation: spawn, color: ColorYellow, name: "O",
+		},
+		{
+			rotations: []PieceInstance{
+				{{0, 1}, {1, 1}, {1, 2}, {2, 2}},
+				{{2, 0}, {1, 1}, {2, 1}, {1, 2}},
+			},
+			initialLocation: spawn, color: ColorRed, name: "Z",
+		},
+		{
+			rotations: []PieceInstance{
+				{{1, 1}, {2, 1}, {0, 2}, {1, 2}},
+				{{0, 0}, {0, 1}, {1, 1}, {1, 2}},
+			},
+			initialLocation: spawn, color: ColorGreen, name: "S",
+		},
+		{
+			rotations: []PieceInstance{
+				{{0, 1}, {1, 1}, {2, 1}, {1, 2}},
+				{{1, 0}, {0, 1}, {1, 1}, {1, 2}},
+				{{1, 1}, {0, 2}, {1, 2}, {2, 2}},
+				{{1, 0}, {1, 1}, {2, 1}, {1, 2}},
+			},
+			initialLocation: spawn, color: ColorMagenta, name: "T",
+		},
+		{
+			rotations: []PieceInstance{
+				{{0, 1}, {1, 1}, {2, 1}, {0, 2}},
+				{{0, 0}, {1, 0}, {1, 1}, {1, 2}},
+				{{2, 1}, {0, 2}, {1, 2}, {2, 2}},
+				{{1, 0}, {1, 1}, {1, 2}, {2, 2}},
+			},
+			initialLocation: spawn, color: ColorWhite, name: "L",
+		},
+		{
+			rotations: []PieceInstance{
+				{{0, 1}, {1, 1}, {2, 1}, {2, 2}},
+				{{1, 0}, {1, 1}, {0, 2}, {1, 2}},
+				{{0, 1}, {0, 2}, {1, 2}, {2, 2}},
+				{{1, 0}, {2, 0}, {1, 1}, {1, 2}},
+			},
+			initialLocation: spawn, color: ColorBlue, name: "J",
+		},
+		{
+			rotations: []PieceInstance{
+				{{0, 1}, {1, 1}, {2, 1}, {3, 1}},
+				{{2, 0}, {2, 1}, {2, 2}, {2, 3}},
+			},
+			initialLocation: spawn, color: ColorCyan, name: "I",
+		},
+	}
+}
+
+// ARS kicks the piece one column right, or failing that one column left. The I piece never kicks, and the L,
+// J, and T pieces don't kick if the first overlapping cell of their new rotation (reading its 3x3 box from
+// the top left, row by row) is in the middle column: that's the "center column rule", which stops them from
+// rotating into places they couldn't otherwise reach.
+func arsKicks(board *Board) []Vector {
+	switch board.currentPiece.name {
+	case "I":
+		return nil
+	case "L", "J", "T":
+		first := Vector{-1, -1}
+		for _, point := range board.currentPiece.instance() {
+			if !board.overlaps(point.plus(board.currentPosition)) {
+				continue
+			}
+			if first.y < 0 || point.y < first.y || (point.y == first.y && point.x < first.x) {
+				first = point
+			}
+		}
+		if first.x == 1 {
+			return nil
+		}
+	}
+	return []Vector{{1, 0}, {-1, 0}}
+}
+
+// The SRS pieces. They spawn flat side down (pointing up) in a 3x3 box (4x4 for the I, which lies in the
+// second row of its box), and every piece but the O has four rotations about the center of its box. Rotation
+// is clockwise.
+func srsPieces() []Piece {
+	spawn := Vector{3, 0}
+	return []Piece{
+		{
+			rotations:       []PieceInstance{{{0, 0}, {1, 0}, {0, 1}, {1, 1}}},
+			initialLocation: Vector{4, 0}, color: ColorYellow, name: "O",
+		},
+		{
+			rotations: []PieceInstance{
+				{{0, 0}, {1, 0}, {1, 1}, {2, 1}},
+				{{2, 0}, {1, 1}, {2, 1}, {1, 2}},
+				{{0, 1}, {1, 1}, {1, 2}, {2, 2}},
+				{{1, 0}, {0, 1}, {1, 1}, {0, 2}},
+			},
+			initialLocation: spawn, color: ColorRed, name: "Z",
+		},
+		{
+			rotations: []PieceInstance{
+				{{1, 0}, {2, 0}, {0, 1}, {1, 1}},
+				{{1, 0}, {1, 1}, {2, 1}, {2, 2}},
+				{{1, 1}, {2, 1}, {0, 2}, {1, 2}},
+				{{0, 0}, {0, 1}, {1, 1}, {1, 2}},
+			},
+			initialLocation: spawn, color: ColorGreen, name: "S",
+		},
+		{
+			rotations: []PieceInstance{
+				{{1, 0}, {0, 1}, {1, 1}, {2, 1}},
+				{{1, 0}, {1, 1}, {2, 1}, {1, 2}},
+				{{0, 1}, {1, 1}, {2, 1}, {1, 2}},
+				{{1, 0}, {0, 1}, {1, 1}, {1, 2}},
+			},
+			initialLocation: spawn, color: ColorMagenta, name: "T",
+		},
+		{
+			rotations: []PieceInstance{
+				{{2, 0}, {0, 1}, {1, 1}, {2, 1}},
+				{{1, 0}, {1, 1}, {1, 2}, {2, 2}},
+				{{0, 1}, {1, 1}, {2, 1}, {0, 2}},
+				{{0, 0}, {1, 0}, {1, 1}, {1, 2}},
+			},
+			initialLocation: spawn, color: ColorWhite, name: "L",
+		},
+		{
+			rotations: []PieceInstance{
+				{{0, 0}, {0, 1}, {1, 1}, {2, 1}},
+				{{1, 0}, {2, 0}, {1, 1}, {1, 2}},
+				{{0, 1}, {1, 1}, {2, 1}, {2, 2}},
+				{{1, 0}, {1, 1}, {0, 2}, {1, 2}},
+			},
+			initialLocation: spawn, color: ColorBlue, name: "J",
+		},
+		{
+			rotations: []PieceInstance{
+				{{0, 1}, {1, 1}, {2, 1}, {3, 1}},
+				{{2, 0}, {2, 1}, {2, 2}, {2, 3}},
+				{{0, 2}, {1, 2}, {2, 2}, {3, 2}},
+				{{1, 0}, {1, 1}, {1, 2}, {1, 3}},
+			},
+			initialLocation: Vector{3, 0}, color: ColorCyan, name: "I",
+		},
+	}
+}
+
+// The SRS kicks for clockwise rotations, indexed by the rotation being rotated from. These are the standard
+// tables with y flipped to point down, and without the first test (no offset), which Game.Rotate has already
+// tried.
+var (
+	srsKickTable = [][]Vector{
+		{{-1, 0}, {-1, -1}, {0, 2}, {-1, 2}},
+		{{1, 0}, {1, 1}, {0, -2}, {1, -2}},
+		{{1, 0}, {1, -1}, {0, 2}, {1, 2}},
+		{{-1, 0}, {-1, 1}, {0, -2}, {-1, -2}},
+	}
+	srsIKickTable = [][]Vector{
+		{{-2, 0}, {1, 0}, {-2, 1}, {1, -2}},
+		{{-1, 0}, {2, 0}, {-1, -2}, {2, 1}},
+		{{2, 0}, {-1, 0}, {2, -1}, {-1, 2}},
+		{{1, 0}, {-2, 0}, {1, 2}, {-2, -1}},
+	}
+)
+
+func srsKicks(board *Board) []Vector {
+	piece := board.currentPiece
+	if len(piece.rotations) != 4 {
+		return nil
+	}
+	from := (piece.currentRotation + 3) % 4
+	if piece.name == "I" {
+		return srsIKickTable[from]
+	}
+	return srsKickTable[from]
+}
@@ -0,0 +1,151 @@
+package tetris
+
+import (
+	"math"
+)
+
+// A Ruleset holds the details of how pieces behave that modes build on: how they rotate, how fast they fall
+// at each level, and the delays around placing them. Modes choose their ruleset in Setup (see
+// Game.setRuleset).
+type Ruleset struct {
+	rotation *rotationSystem
+	// The drop delay at a level, in milliseconds per row. A delay of 0 means 20G: pieces fall straight to the
+	// bottom.
+	gravity func(level int) int
+	// The delays at a level.
+	timings func(level int) Timings
+	// The number of times that moving or rotating a resting piece can restart its lock delay.
+	lockResets int
+}
+
+// Timings are the delays that a ruleset uses at some level, in milliseconds.
+type Timings struct {
+	// The entry delay (also known as ARE): the time between a piece locking (or completed rows being removed)
+	// and the next piece appearing.
+	Entry int
+	// How long completed rows flash on the screen before they are removed.
+	LineClear int
+	// How long a piece can rest on the stack before it locks. If it's 0, a piece locks as soon as gravity
+	// can't move it down any further.
+	Lock int
+}
+
+// The level at which pieces fall at 20G in the modern ruleset.
+const maxGravityLevel = 20
+
+var (
+	// The original rules of this game: pieces lock as soon as they land, and there are no kicks.
+	classicRuleset = &Ruleset{
+		rotation: classicRotation,
+		gravity:  classicGravity,
+		timings:  fixedTimings(Timings{LineClear: 400}),
+	}
+	// Rules in the style of modern tetris games, with a gravity curve that reaches 20G and a lock delay which
+	// makes that playable.
+	modernRuleset = &Ruleset{
+		rotation:   classicRotation,
+		gravity:    modernGravity,
+		timings:    fixedTimings(Timings{LineClear: 400, Lock: 500}),
+		lockResets: 15,
+	}
+	// Rules in the style of the tetris guideline, which most modern tetris games follow: the modern ruleset
+	// with SRS rotation, whose kicks make moves like T-spins possible.
+	guidelineRuleset = &Ruleset{
+		rotation:   srsRotation,
+		gravity:    modernGravity,
+		timings:    fixedTimings(Timings{LineClear: 400, Lock: 500}),
+		lockResets: 15,
+	}
+	// Rules in the style of the arcade game Tetris: The Grand Master, for master mode. Levels go from 0 to
+	// 999, and the delays get shorter as the level goes up.
+	masterRuleset = &Ruleset{
+		rotation: arsRotation,
+		gravity:  masterGravity,
+		timings:  masterTimings,
+	}
+)
+
+// Make a timings function which gives the same timings at every level.
+func fixedTimings(timings Timings) func(level int) Timings {
+	return func(level int) Timings { return timings }
+}
+
+// The classic gravity curve starts at 800ms per row and decreases by 100ms per level to 200ms.
+func classicGravity(level int) int {
+	delay := 800 - 100*(level-1)
+	if delay < 200 {
+		return 200
+	}
+	return delay
+}
+
+// The modern gravity curve is the one used by most recent tetris games: (0.8 - (level-1)*0.007)^(level-1)
+// seconds per row, which is a second per row at level 1 and gets faster until it's 20G at maxGravityLevel.
+func modernGravity(level int) int {
+	if level >= maxGravityLevel {
+		return 0
+	}
+	if level < 1 {
+		level = 1
+	}
+	seconds := math.Pow(0.8-float64(level-1)*0.007, float64(level-1))
+	delay := int(math.Round(seconds * 1000))
+	if delay < 1 {
+		return 1
+	}
+	return delay
+}
+
+// The length of a frame of the arcade games that the master ruleset is based on, which run at 60 frames per
+// second. Their timings are all given in frames.
+const frameMillis = 1000.0 / 60
+
+// Convert a number of frames to milliseconds.
+func frames(n int) int {
+	return int(math.Round(float64(n) * frameMillis))
+}
+
+// The master gravity table, from Tetris: The Grand Master. Each entry gives the gravity from a level upwards,
+// in 1/256ths of a row per frame; 5120 (20 rows per frame) is 20G. Gravity speeds up in steps, drops back to
+// slow at level 200, and then quickly reaches 20G at level 500.
+var masterGravityTable = []struct{ level, gravity int }{
+	{0, 4}, {30, 6}, {35, 8}, {40, 10}, {50, 12}, {60, 16}, {70, 32}, {80, 48}, {90, 64}, {100, 80}, {120, 96},
+	{140, 112}, {160, 128}, {170, 144}, {200, 4}, {220, 32}, {230, 64}, {233, 96}, {236, 128}, {239, 160},
+	{243, 192}, {247, 224}, {251, 256}, {300, 512}, {330, 768}, {360, 1024}, {400, 1280}, {420, 1024},
+	{450, 768}, {500, 5120},
+}
+
+func masterGravity(level int) int {
+	gravity := masterGravityTable[0].gravity
+	for _, entry := range masterGravityTable {
+		if level >= entry.level {
+			gravity = entry.gravity
+		}
+	}
+	if gravity >= 5120 {
+		return 0
+	}
+	delay := int(math.Round(256 / float64(gravity) * frameMillis))
+	if delay < 1 {
+		return 1
+	}
+	return delay
+}
+
+// The master timings (from Tetris: The Grand Master 2's master mode) get shorter every 100 levels from level
+// 500 on.
+func masterTimings(level int) Timings {
+	switch {
+	case level < 500:
+		return Timings{Entry: frames(25), LineClear: frames(40), Lock: frames(30)}
+	case level < 600:
+		return Timings{Entry: frames(25), LineClear: frames(25), Lock: frames(30)}
+	case level < 700:
+		return Timings{Entry: frames(25), LineClear: frames(16), Lock: frames(30)}
+	case level < 800:
+		return Timings{Entry: frames(16), LineClear: frames(12), Lock: frames(30)}
+	case level < 900:
+		return Timings{Entry: frames(12), LineClear: frames(6), Lock: frames(30)}
+	}
+	return Timings{Entry: frames(12), LineClear: frames(6), Lock: frames(17)}
+}
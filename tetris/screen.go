@@ -0,0 +1,148 @@
+package tetris
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A Screen is a grid of character cells that the game interface is drawn onto. Drawing goes to a Console
+// when playing, or into a Buffer when rendering a game some other way.
+type Screen interface {
+	SetCell(x, y int, ch rune, fg, bg Color)
+	// Show everything that has been drawn since the last Flush.
+	Flush() error
+}
+
+// A Screen which draws onto another one, moved right by dx and down by dy (e.g. to put two games side by
+// side).
+type offsetScreen struct {
+	Screen
+	dx, dy int
+}
+
+func (screen offsetScreen) SetCell(x, y int, ch rune, fg, bg Color) {
+	screen.Screen.SetCell(x+screen.dx, y+screen.dy, ch, fg, bg)
+}
+
+// Make a Screen which draws onto another one, moved right by dx and down by dy.
+func OffsetScreen(screen Screen, dx, dy int) Screen {
+	return offsetScreen{screen, dx, dy}
+}
+
+// The width and height of the screen area used by the game interface.
+var (
+	ScreenWidth  = totalWidth + 4
+	ScreenHeight = totalHeight + 2
+)
+
+// A Cell is a single character cell of a Buffer.
+type Cell struct {
+	Ch     rune
+	Fg, Bg Color
+}
+
+// A Buffer is an in-memory Screen which can be rendered as plain text.
+type Buffer struct {
+	Width, Height int
+	cells         []Cell
+}
+
+// Make a new blank buffer of the given size (ScreenWidth by ScreenHeight holds the game interface).
+func NewBuffer(width, height int) *Buffer {
+	buffer := &Buffer{Width: width, Height: height}
+	buffer.cells = make([]Cell, buffer.Width*buffer.Height)
+	for i := range buffer.cells {
+		buffer.cells[i] = Cell{' ', ColorDefault, ColorDefault}
+	}
+	return buffer
+}
+
+// Set a cell of the buffer. Cells outside of the buffer are ignored.
+func (buffer *Buffer) SetCell(x, y int, ch rune, fg, bg Color) {
+	if x < 0 || x >= buffer.Width || y < 0 || y >= buffer.Height {
+		return
+	}
+	buffer.cells[y*buffer.Width+x] = Cell{ch, fg, bg}
+}
+
+// Blank every cell of the buffer.
+func (buffer *Buffer) Clear(fg, bg Color) {
+	for i := range buffer.cells {
+		buffer.cells[i] = Cell{' ', fg, bg}
+	}
+}
+
+// Make a copy of the buffer, which doesn't change when the buffer does.
+func (buffer *Buffer) Copy() *Buffer {
+	return &Buffer{Width: buffer.Width, Height: buffer.Height, cells: append([]Cell(nil), buffer.cells...)}
+}
+
+// Flush is a no-op for a Buffer; its contents are always up to date.
+func (buffer *Buffer) Flush() error {
+	return nil
+}
+
+// Find the contents of a cell of the buffer.
+func (buffer *Buffer) Cell(x, y int) Cell {
+	return buffer.cells[y*buffer.Width+x]
+}
+
+// Find the cells of the buffer which differ from those of another buffer of the same size (which holds what a
+// display is showing), calling changed for each in turn (row by row) and copying it into the other buffer.
+func (buffer *Buffer) Update(shown *Buffer, changed func(x, y int, cell Cell)) {
+	for i, cell := range buffer.cells {
+		if cell != shown.cells[i] {
+			changed(i%buffer.Width, i/buffer.Width, cell)
+			shown.cells[i] = cell
+		}
+	}
+}
+
+// Render the buffer as plain text without any colors, one line per row.
+func (buffer *Buffer) Text() string {
+	var b strings.Builder
+	for y := 0; y < buffer.Height; y++ {
+		line := make([]rune, buffer.Width)
+		for x := range line {
+			line[x] = buffer.Cell(x, y).Ch
+		}
+		b.WriteString(strings.TrimRight(string(line), " "))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Render the buffer as text with ANSI escape sequences for the colors. Rows are separated by "\r\n" so that
+// the result can be written directly to a terminal in raw mode.
+func (buffer *Buffer) ANSI() string {
+	var b bytes.Buffer
+	for y := 0; y < buffer.Height; y++ {
+		if y > 0 {
+			b.WriteString("\r\n")
+		}
+		var fg, bg Color
+		for x := 0; x < buffer.Width; x++ {
+			cell := buffer.Cell(x, y)
+			if x == 0 || cell.Fg != fg || cell.Bg != bg {
+				fg, bg = cell.Fg, cell.Bg
+				fmt.Fprintf(&b, "\x1b[0;%d;%dm", ansiColor(fg, 30), ansiColor(bg, 40))
+			}
+			b.WriteRune(cell.Ch)
+		}
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
+
+// Find the SGR parameter for a color, given the base for the standard foreground (30) or background
+// (40) colors.
+func ansiColor(color Color, base int) int {
+	switch {
+	case color >= ColorBlack && color <= ColorWhite:
+		return base + int(color-ColorBlack)
+	case color >= ColorDarkGray && color <= ColorLightGray:
+		return base + 60 + int(color-ColorDarkGray)
+	}
+	return base + 9 // default
+}
@@ -0,0 +1,45 @@
+package tetris
+
+import (
+	"fmt"
+)
+
+// In sprint mode the goal is to clear a number of lines (40, normally) as quickly as possible. The timer
+// starts with the player's first input.
+type sprintMode struct {
+	modeBase
+}
+
+func (mode *sprintMode) Update(game *Game) {
+	// The game ends as soon as the last line is completed, rather than after it's finished flashing.
+	if game.lines+len(game.clearingRows) >= mode.options.Lines {
+		game.finish()
+	}
+}
+
+func (mode *sprintMode) Status(game *Game) []string {
+	millis := game.TimeSinceFirstInput()
+	return []string{
+		fmt.Sprintf("Lines  %d/%d", game.lines, mode.options.Lines),
+		fmt.Sprintf("Time   %s", FormatMillis(millis)),
+		fmt.Sprintf("PPS    %s", piecesPerSecond(game.piecesPlaced, millis)),
+	}
+}
+
+func (mode *sprintMode) Results(game *Game) []string {
+	millis := game.TimeSinceFirstInput()
+	results := []string{
+		fmt.Sprintf("Time    %s", FormatMillis(millis)),
+		fmt.Sprintf("Pieces  %d", game.piecesPlaced),
+		fmt.Sprintf("PPS     %s", piecesPerSecond(game.piecesPlaced, millis)),
+	}
+	if !game.finished {
+		results = append([]string{fmt.Sprintf("Lines   %d/%d", game.lines, mode.options.Lines)}, results...)
+	}
+	return results
+}
+
+func (mode *sprintMode) Result(game *Game) (Result, bool) {
+	millis := game.TimeSinceFirstInput()
+	return Result{Value: millis, LowerIsBetter: true, Display: FormatMillis(millis)}, game.finished
+}
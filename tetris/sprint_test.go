@@ -0,0 +1,66 @@
+package tetris
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSprint(t *testing.T) {
+	mode, err := NewMode("sprint", ModeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(2, mode)
+	// The timer doesn't start until the first input.
+	game.Advance(5000)
+	botPlay(game, 600000, 5)
+	if !game.Finished() || game.Lines() < 40 {
+		t.Fatalf("the bot didn't finish the sprint: %d lines\n%s", game.Lines(), dumpBoard(game))
+	}
+	result, ok := mode.Result(game)
+	if !ok || !result.LowerIsBetter {
+		t.Fatalf("got result %+v, %t; want a finished, lower-is-better result", result, ok)
+	}
+	if result.Value != game.TimeSinceFirstInput() || result.Value > game.clock-5000 {
+		t.Errorf("the sprint took %d ms, after %d ms of play (and a 5000 ms wait)", result.Value, game.clock)
+	}
+	results := game.Results()
+	if want := "Time    " + FormatMillis(result.Value); results[0] != want {
+		t.Errorf("the results start with %q; want %q", results[0], want)
+	}
+	for _, line := range results {
+		if strings.HasPrefix(line, "Lines") || strings.HasPrefix(line, "Faults") {
+			t.Errorf("the results of a finished sprint without finesse tracking include %q", line)
+		}
+	}
+
+	replayed, err := game.Replay().Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.clock != game.clock || replayed.Lines() != game.Lines() || !replayed.Finished() {
+		t.Errorf("the replay ended at %d ms with %d lines; want %d ms with %d lines",
+			replayed.clock, replayed.Lines(), game.clock, game.Lines())
+	}
+}
+
+func TestSprintFinesseResults(t *testing.T) {
+	mode, err := NewMode("sprint", ModeOptions{Finesse: FinesseTrack})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(2, mode)
+	game.Advance(100)
+	// Moving the first piece left and back again takes two more inputs than dropping it where it is.
+	for _, event := range []GameEvent{MoveLeft, MoveRight, QuickDrop} {
+		game.Handle(event)
+		game.Advance(10)
+	}
+	botPlay(game, 600000, 5)
+	results := strings.Join(game.Results(), "\n")
+	for _, want := range []string{"Faults  1", "Extra inputs  2"} {
+		if !strings.Contains(results, want) {
+			t.Errorf("the sprint results don't include %q:\n%s", want, results)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package tetris
+
+import (
+	"fmt"
+)
+
+// The time between garbage rows in survival mode starts at survivalStartInterval and shrinks by
+// survivalSpeedup percent with each row, down to survivalMinInterval (all in milliseconds).
+const (
+	survivalStartInterval = 5000
+	survivalMinInterval   = 1000
+	survivalSpeedup       = 4
+)
+
+// In survival mode garbage rows rise from the bottom of the board on a timer which gets faster and faster,
+// and the goal is to last as long as possible. The Messiness option controls how often the holes of the
+// garbage rows move between columns, like in cheese mode.
+type survivalMode struct {
+	modeBase
+	// The column of the hole in the most recently raised garbage row, or -1 if none have been raised yet.
+	hole int
+	// The clock time at which the next garbage row rises, and the time between rows at the moment.
+	nextRise int
+	interval int
+}
+
+func (mode *survivalMode) Setup(game *Game) {
+	mode.interval = survivalStartInterval
+	mode.nextRise = mode.interval
+}
+
+func (mode *survivalMode) Update(game *Game) {
+	if game.clock < mode.nextRise {
+		return
+	}
+	mode.hole = game.garbageHole(mode.hole, mode.options.Messiness)
+	game.raiseGarbage([]int{mode.hole})
+	mode.interval -= mode.interval * survivalSpeedup / 100
+	if mode.interval < survivalMinInterval {
+		mode.interval = survivalMinInterval
+	}
+	mode.nextRise = game.clock + mode.interval
+}
+
+func (mode *survivalMode) Status(game *Game) []string {
+	return []string{
+		fmt.Sprintf("Time   %s", FormatMillis(game.clock)),
+		fmt.Sprintf("Rise   %.1fs", float64(mode.nextRise-game.clock)/1000),
+		fmt.Sprintf("Lines  %d", game.lines),
+	}
+}
+
+func (mode *survivalMode) Results(game *Game) []string {
+	return []string{
+		fmt.Sprintf("Time    %s", FormatMillis(game.clock)),
+		fmt.Sprintf("Lines   %d", game.lines),
+		fmt.Sprintf("Dug     %d", game.garbageCleared),
+		fmt.Sprintf("Pieces  %d", game.piecesPlaced),
+	}
+}
+
+// Survival games always end by topping out, so they all count for personal bests.
+func (mode *survivalMode) Result(game *Game) (Result, bool) {
+	return Result{Value: game.clock, Display: FormatMillis(game.clock)}, true
+}
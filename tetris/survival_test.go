@@ -0,0 +1,94 @@
+package tetris
+
+import (
+	"testing"
+)
+
+func TestSurvival(t *testing.T) {
+	mode, err := NewMode("survival", ModeOptions{Messiness: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(3, mode)
+	survival := mode.(*survivalMode)
+	interval := survivalStartInterval
+	for rises := 0; rises < 40; {
+		nextRise := survival.nextRise
+		game.Advance(1)
+		if game.Over() {
+			t.Fatalf("the player topped out after %d garbage rows", rises)
+		}
+		if survival.nextRise == nextRise {
+			continue
+		}
+		rises++
+		// Keep the stack from reaching the top.
+		game.board.cells = make(ColorMap)
+		interval -= interval * survivalSpeedup / 100
+		if interval < survivalMinInterval {
+			interval = survivalMinInterval
+		}
+		if survival.nextRise-game.clock != interval {
+			t.Fatalf("garbage row %d rises %d ms after the last one; want %d",
+				rises, survival.nextRise-game.clock, interval)
+		}
+	}
+	// Without clearing any lines, the player eventually tops out.
+	game.Advance(600000)
+	if !game.Over() {
+		t.Fatalf("the game isn't over")
+	}
+	if result, ok := mode.Result(game); !ok || result.Value != game.clock {
+		t.Errorf("got result %+v, %t; want the time survived, %d", result, ok, game.clock)
+	}
+}
+
+func TestSurvivalBot(t *testing.T) {
+	mode, err := NewMode("survival", ModeOptions{Messiness: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(1, mode)
+	botPlay(game, 600000, 5)
+	if !game.Over() || game.garbageCleared == 0 {
+		t.Fatalf("the bot dug %d garbage rows in %d ms\n%s", game.garbageCleared, game.clock, dumpBoard(game))
+	}
+	replayed, err := game.Replay().Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !replayed.Over() || replayed.clock != game.clock || replayed.garbageCleared != game.garbageCleared {
+		t.Errorf("the replay doesn't match the game")
+	}
+}
+
+func TestRaiseGarbageMovesPiece(t *testing.T) {
+	mode, err := NewMode("classic", ModeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(3, mode)
+	board := game.board
+	board.moveIfPossible(Vector{0, 1})
+	board.moveIfPossible(Vector{0, 1})
+	y := board.currentPosition.y
+	// The piece moves up with the stack, even when the garbage doesn't reach it.
+	game.raiseGarbage([]int{0})
+	if board.currentPosition.y != y-1 || game.Over() {
+		t.Fatalf("after 1 garbage row the piece is at row %d; want %d", board.currentPosition.y, y-1)
+	}
+	game.raiseGarbage([]int{0})
+	if board.currentPosition.y != y-2 || game.Over() {
+		t.Fatalf("after 2 garbage rows the piece is at row %d; want %d", board.currentPosition.y, y-2)
+	}
+	// At the top of the board it stays put until the stack reaches it.
+	for !game.Over() {
+		game.raiseGarbage([]int{0})
+		if board.currentPosition.y != 0 {
+			t.Fatalf("the piece is at row %d; want 0", board.currentPosition.y)
+		}
+	}
+	if !board.currentPieceInCollision() {
+		t.Fatalf("the player topped out before the stack reached the piece\n%s", dumpBoard(game))
+	}
+}
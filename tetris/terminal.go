@@ -0,0 +1,206 @@
+package tetris
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A Terminal is a Screen for a terminal at the other end of an io.ReadWriter (like an SSH session's PTY),
+// rather than the one that termbox manages. It draws by writing ANSI escape sequences, and reads key presses
+// from the terminal's input.
+type Terminal struct {
+	rw io.ReadWriter
+	// The cells drawn since the last Flush, and those which the terminal is showing (or nil if it needs to be
+	// cleared and drawn again from scratch). Both are the size of the terminal. started is whether the
+	// terminal has been set up for drawing (see Close).
+	mu          sync.Mutex
+	back, front *Buffer
+	started     bool
+	// The key presses read from the terminal's input, and resizes.
+	*consoleEvents
+}
+
+// Make a Terminal for a terminal of the given size in cells, and start reading its input. The terminal should
+// be in raw mode (as an SSH client puts its own terminal in when it asks for a PTY).
+func NewTerminal(rw io.ReadWriter, width, height int) *Terminal {
+	terminal := &Terminal{
+		rw:            rw,
+		back:          NewBuffer(width, height),
+		consoleEvents: newConsoleEvents(),
+	}
+	go terminal.read()
+	return terminal
+}
+
+// Set a cell. Cells outside of the terminal are ignored.
+func (terminal *Terminal) SetCell(x, y int, ch rune, fg, bg Color) {
+	terminal.mu.Lock()
+	terminal.back.SetCell(x, y, ch, fg, bg)
+	terminal.mu.Unlock()
+}
+
+// Blank every cell of the terminal, until the next Flush.
+func (terminal *Terminal) Clear(fg, bg Color) {
+	terminal.mu.Lock()
+	terminal.back.Clear(fg, bg)
+	terminal.mu.Unlock()
+}
+
+// Write the cells which have changed since the last Flush to the terminal.
+func (terminal *Terminal) Flush() error {
+	terminal.mu.Lock()
+	defer terminal.mu.Unlock()
+	return terminal.flush()
+}
+
+func (terminal *Terminal) flush() error {
+	var b bytes.Buffer
+	if !terminal.started {
+		// Switch to the alternate screen (so that the terminal's contents come back afterwards, like with
+		// termbox), and hide the cursor.
+		b.WriteString("\x1b[?1049h\x1b[?25l")
+		terminal.started = true
+	}
+	if terminal.front == nil {
+		b.WriteString("\x1b[0m\x1b[2J")
+		terminal.front = NewBuffer(terminal.back.Width, terminal.back.Height)
+	}
+	// Only the cells that have changed are written, moving the cursor to them when they aren't next to the
+	// last one, and changing colors when they differ from the last one's.
+	cursorX, cursorY := -1, -1
+	colored := false
+	var fg, bg Color
+	terminal.back.Update(terminal.front, func(x, y int, cell Cell) {
+		if x != cursorX || y != cursorY {
+			fmt.Fprintf(&b, "\x1b[%d;%dH", y+1, x+1)
+		}
+		if !colored || cell.Fg != fg || cell.Bg != bg {
+			fg, bg, colored = cell.Fg, cell.Bg, true
+			fmt.Fprintf(&b, "\x1b[0;%d;%dm", ansiColor(fg, 30), ansiColor(bg, 40))
+		}
+		b.WriteRune(cell.Ch)
+		cursorX, cursorY = x+1, y
+	})
+	if colored {
+		b.WriteString("\x1b[0m")
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	_, err := terminal.rw.Write(b.Bytes())
+	return err
+}
+
+// Change the size of the terminal (e.g. when an SSH client's window changes size). Everything drawn so far
+// is drawn again at the new size straight away, and PollEvent returns an EventResize so that the game can
+// redraw itself.
+func (terminal *Terminal) Resize(width, height int) {
+	terminal.mu.Lock()
+	old := terminal.back
+	if width == old.Width && height == old.Height {
+		terminal.mu.Unlock()
+		return
+	}
+	terminal.back = NewBuffer(width, height)
+	for y := 0; y < old.Height; y++ {
+		for x := 0; x < old.Width; x++ {
+			cell := old.Cell(x, y)
+			terminal.back.SetCell(x, y, cell.Ch, cell.Fg, cell.Bg)
+		}
+	}
+	terminal.front = nil
+	terminal.flush()
+	terminal.mu.Unlock()
+	terminal.signal(Event{Type: EventResize})
+}
+
+// Put the terminal back the way it was found, and stop reading its input. It doesn't close the io.ReadWriter.
+func (terminal *Terminal) Close() error {
+	terminal.consoleEvents.close()
+	terminal.mu.Lock()
+	defer terminal.mu.Unlock()
+	if !terminal.started {
+		return nil
+	}
+	terminal.started = false
+	_, err := io.WriteString(terminal.rw, "\x1b[0m\x1b[2J\x1b[H\x1b[?25h\x1b[?1049l")
+	return err
+}
+
+// Read key presses from the terminal's input until it ends or the terminal is closed.
+func (terminal *Terminal) read() {
+	input := bufio.NewReader(terminal.rw)
+	for {
+		event, err := readKey(input)
+		if err != nil {
+			terminal.end(err)
+			return
+		}
+		if event.Type != EventNone && !terminal.key(event) {
+			terminal.end(errors.New("the terminal was closed"))
+			return
+		}
+	}
+}
+
+// Decode the next key press from a terminal's input: a character, a control key, or the escape sequence for
+// an arrow key. Other escape sequences are skipped, and give an event of type EventNone.
+func readKey(input *bufio.Reader) (Event, error) {
+	r, _, err := input.ReadRune()
+	if err != nil {
+		return Event{}, err
+	}
+	event := Event{Type: EventKey}
+	switch {
+	case r == 0x1b && input.Buffered() > 0:
+		return readEscapeSequence(input)
+	case r == ' ':
+		event.Key = KeySpace
+	case r == '\n':
+		event.Key = KeyEnter
+	case r < ' ' || r == 0x7f:
+		// The keys for control characters (like ctrl-c, enter, and escape) are the characters themselves.
+		event.Key = Key(r)
+	default:
+		event.Ch = r
+	}
+	return event, nil
+}
+
+// Decode the rest of an escape sequence, after the escape character.
+func readEscapeSequence(input *bufio.Reader) (Event, error) {
+	introducer, err := input.ReadByte()
+	if err != nil {
+		return Event{}, err
+	}
+	if introducer != '[' && introducer != 'O' {
+		// An alt-modified key, which isn't used.
+		return Event{Type: EventNone}, nil
+	}
+	// Control sequences end with a byte from '@' to '~', after any parameters. Arrow keys are sent as CSI
+	// sequences ("\x1b[A"), or SS3 sequences ("\x1bOA") in application cursor mode.
+	var final byte
+	for i := 0; i < 16; i++ {
+		if final, err = input.ReadByte(); err != nil {
+			return Event{}, err
+		}
+		if final >= '@' && final <= '~' {
+			break
+		}
+	}
+	keys := map[byte]Key{
+		'A': KeyArrowUp,
+		'B': KeyArrowDown,
+		'C': KeyArrowRight,
+		'D': KeyArrowLeft,
+	}
+	key, ok := keys[final]
+	if !ok {
+		return Event{Type: EventNone}, nil
+	}
+	return Event{Type: EventKey, Key: key}, nil
+}
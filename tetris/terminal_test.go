@@ -0,0 +1,166 @@
+package tetris
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// A terminalConn is the connection to a terminal in a test: the terminal's input comes from a reader, and its
+// output is kept.
+type terminalConn struct {
+	input  io.Reader
+	output bytes.Buffer
+}
+
+func (conn *terminalConn) Read(p []byte) (int, error) {
+	return conn.input.Read(p)
+}
+
+func (conn *terminalConn) Write(p []byte) (int, error) {
+	return conn.output.Write(p)
+}
+
+// The escape sequences that a Terminal writes which move the cursor or clear the screen. Others (like
+// colors) don't change what's shown.
+var terminalSequence = regexp.MustCompile(`^\x1b\[([0-9;?]*)([A-Za-z])`)
+
+// Work out what a terminal of some size shows after the output that a Terminal wrote to it, line by line
+// (without the trailing spaces).
+func terminalLines(output string, width, height int) []string {
+	screen := make([][]rune, height)
+	clear := func() {
+		for y := range screen {
+			screen[y] = []rune(strings.Repeat(" ", width))
+		}
+	}
+	clear()
+	x, y := 0, 0
+	for output != "" {
+		if match := terminalSequence.FindStringSubmatch(output); match != nil {
+			output = output[len(match[0]):]
+			switch match[2] {
+			case "H":
+				x, y = 0, 0
+				if position := strings.Split(match[1], ";"); len(position) == 2 {
+					y, _ = strconv.Atoi(position[0])
+					x, _ = strconv.Atoi(position[1])
+					x, y = x-1, y-1
+				}
+			case "J":
+				clear()
+			}
+			continue
+		}
+		r := []rune(output)[0]
+		output = output[len(string(r)):]
+		if x < width && y < height {
+			screen[y][x] = r
+		}
+		x++
+	}
+	lines := make([]string, height)
+	for y, line := range screen {
+		lines[y] = strings.TrimRight(string(line), " ")
+	}
+	return lines
+}
+
+// The lines of a Buffer, cut down (or blanked out) to a size (without the trailing spaces).
+func bufferLines(buffer *Buffer, width, height int) []string {
+	text := strings.Split(buffer.Text(), "\n")
+	lines := make([]string, height)
+	for y := 0; y < height && y < buffer.Height; y++ {
+		line := []rune(text[y])
+		if len(line) > width {
+			line = line[:width]
+		}
+		lines[y] = strings.TrimRight(string(line), " ")
+	}
+	return lines
+}
+
+func TestTerminalDraws(t *testing.T) {
+	// The input never ends (until the test does), so that it doesn't race with the resize event.
+	input, typing := io.Pipe()
+	defer typing.Close()
+	conn := &terminalConn{input: input}
+	terminal := NewTerminal(conn, 100, 50)
+	buffer := NewBuffer(ScreenWidth, ScreenHeight)
+	mode, err := NewMode("sprint", ModeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(1, mode)
+	drawStaticBoardParts(terminal)
+	drawStaticBoardParts(buffer)
+	for i := 0; i < 30; i++ {
+		game.Advance(100)
+		game.Handle(MoveLeft)
+		if i%5 == 0 {
+			game.Handle(QuickDrop)
+		}
+		game.DrawDynamic(terminal, false)
+		game.DrawDynamic(buffer, false)
+	}
+	got := terminalLines(conn.output.String(), 100, 50)
+	want := bufferLines(buffer, 100, 50)
+	for y := range want {
+		if got[y] != want[y] {
+			t.Fatalf("line %d of the terminal is %q; want %q", y, got[y], want[y])
+		}
+	}
+
+	// Nothing is written when nothing has changed.
+	conn.output.Reset()
+	game.DrawDynamic(terminal, false)
+	if conn.output.Len() != 0 {
+		t.Errorf("the terminal was sent %q when nothing changed", conn.output.String())
+	}
+
+	// After a resize, the screen is drawn again from scratch at the new size.
+	terminal.Resize(40, 20)
+	if event := terminal.PollEvent(); event.Type != EventResize {
+		t.Errorf("after a resize, the event is %+v; want a resize", event)
+	}
+	got = terminalLines(conn.output.String(), 40, 20)
+	want = bufferLines(buffer, 40, 20)
+	for y := range want {
+		if got[y] != want[y] {
+			t.Fatalf("line %d of the resized terminal is %q; want %q", y, got[y], want[y])
+		}
+	}
+
+	conn.output.Reset()
+	terminal.Close()
+	if output := conn.output.String(); !strings.HasSuffix(output, "\x1b[?25h\x1b[?1049l") {
+		t.Errorf("closing the terminal sent %q; want it to show the cursor and leave the alternate screen",
+			output)
+	}
+}
+
+func TestTerminalKeys(t *testing.T) {
+	input := "h\x1b[A\x1bOB\x1b[5~\x1bx \r\n\x03\x7f"
+	terminal := NewTerminal(&terminalConn{input: strings.NewReader(input)}, 80, 24)
+	want := []Event{
+		{Type: EventKey, Ch: 'h'},
+		{Type: EventKey, Key: KeyArrowUp},
+		{Type: EventKey, Key: KeyArrowDown},
+		{Type: EventKey, Key: KeySpace},
+		{Type: EventKey, Key: KeyEnter},
+		{Type: EventKey, Key: KeyEnter},
+		{Type: EventKey, Key: KeyCtrlC},
+		{Type: EventKey, Key: Key(0x7f)},
+	}
+	for i, want := range want {
+		if event := terminal.PollEvent(); event != want {
+			t.Errorf("event %d is %+v; want %+v", i+1, event, want)
+		}
+	}
+	if event := terminal.PollEvent(); event.Type != EventError || event.Err != io.EOF {
+		t.Errorf("at the end of the input, the event is %+v; want an EOF error", event)
+	}
+}
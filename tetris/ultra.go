@@ -0,0 +1,60 @@
+package tetris
+
+import (
+	"fmt"
+)
+
+// The drop delay in ultra mode, which stays the same for the whole game (it's the speed that the classic mode
+// starts at).
+const ultraDropDelay = 800
+
+// In ultra mode the goal is to score as many points as possible before time runs out (after 3 minutes,
+// normally). The speed doesn't increase, and the countdown starts straight away. Pausing stops the countdown
+// along with the rest of the game.
+type ultraMode struct {
+	modeBase
+}
+
+func (mode *ultraMode) dropDelay(game *Game) int {
+	return ultraDropDelay
+}
+
+func (mode *ultraMode) Update(game *Game) {
+	if game.clock >= mode.options.TimeLimit {
+		game.finish()
+	}
+}
+
+// The time left before the game ends, in milliseconds.
+func (mode *ultraMode) remaining(game *Game) int {
+	remaining := mode.options.TimeLimit - game.clock
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+func (mode *ultraMode) Status(game *Game) []string {
+	return []string{
+		fmt.Sprintf("Left   %s", FormatMillis(mode.remaining(game))),
+		fmt.Sprintf("Lines  %d", game.lines),
+		fmt.Sprintf("PPS    %s", piecesPerSecond(game.piecesPlaced, game.clock)),
+	}
+}
+
+func (mode *ultraMode) Results(game *Game) []string {
+	results := []string{
+		fmt.Sprintf("Score   %d", game.score),
+		fmt.Sprintf("Lines   %d", game.lines),
+		fmt.Sprintf("Pieces  %d", game.piecesPlaced),
+		fmt.Sprintf("PPS     %s", piecesPerSecond(game.piecesPlaced, game.clock)),
+	}
+	if !game.finished {
+		results = append(results, fmt.Sprintf("Time    %s", FormatMillis(game.clock)))
+	}
+	return results
+}
+
+func (mode *ultraMode) Result(game *Game) (Result, bool) {
+	return Result{Value: game.score, Display: fmt.Sprint(game.score)}, game.finished
+}
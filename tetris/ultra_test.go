@@ -0,0 +1,52 @@
+package tetris
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUltra(t *testing.T) {
+	mode, err := NewMode("ultra", ModeOptions{TimeLimit: 30000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(5, mode)
+	// Time spent paused doesn't count.
+	game.PauseToggle()
+	game.Advance(5000)
+	game.PauseToggle()
+	botPlay(game, 600000, 50)
+	if !game.Finished() {
+		t.Fatalf("the game isn't finished after %d ms", game.clock)
+	}
+	if game.clock != 30000 {
+		t.Errorf("the game finished after %d ms; want 30000", game.clock)
+	}
+	if game.dropDelayMillis != ultraDropDelay {
+		t.Errorf("the drop delay is %d ms; want %d", game.dropDelayMillis, ultraDropDelay)
+	}
+	if game.Lines() == 0 {
+		t.Errorf("the bot didn't clear any lines\n%s", dumpBoard(game))
+	}
+	result, ok := mode.Result(game)
+	if !ok || result.Value != game.Score() || result.LowerIsBetter {
+		t.Errorf("got result %+v, %t; want the score, %d", result, ok, game.Score())
+	}
+
+	var b strings.Builder
+	if err := game.Replay().Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	replay, err := ReadReplay(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := replay.Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !replayed.Finished() || replayed.clock != game.clock || replayed.Score() != game.Score() {
+		t.Errorf("the replay ended at %d ms with %d points; want %d ms with %d points",
+			replayed.clock, replayed.Score(), game.clock, game.Score())
+	}
+}
@@ -14,3 +14,8 @@ func (first Vector) plus(second Vector) Vector {
 func (first Vector) equals(second Vector) bool {
 	return first.x == second.x && first.y == second.y
 }
+
+// Multiply a vector by a scalar.
+func (v Vector) times(k int) Vector {
+	return Vector{v.x * k, v.y * k}
+}
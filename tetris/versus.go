@@ -0,0 +1,466 @@
+package tetris
+
+import (
+	"fmt"
+	"strings"
+)
+
+// How long garbage waits after it's sent before it can rise into the other player's board, in milliseconds.
+const versusGarbageDelay = 500
+
+// A Match is a versus match between two or more players, each with their own Game. Clearing lines sends
+// garbage to another player, following the guideline's attack table (see attackTable): more for clearing more
+// lines at once, T-spins, combos, back-to-back clears, and perfect clears. Garbage waits in a queue for a
+// moment before it can rise, and an attack cancels the garbage waiting for the attacker first (see
+// garbageQueue). Queued garbage rises when the player places a piece without clearing anything. Players who
+// top out are knocked out (and whoever last sent them garbage gets the KO), and the last player left wins.
+//
+// With more than two players, each player sends their garbage to a target chosen by their targeting strategy
+// (see targeting), which they can change during the match.
+//
+// Like a Game, a Match is deterministic: time only passes when Advance is called, and every game (and its
+// garbage) is completely determined by the match's seed and the inputs given to each player.
+type Match struct {
+	options ModeOptions
+	games   []*Game
+	modes   []*versusMode
+	// The random number generator for choosing targets at random.
+	rng *Random
+	// The total number of pieces placed, for noticing when targets need choosing again.
+	placed int
+	over   bool
+	// The index of the player who won, or -1 if nobody has (yet, or because the last players topped out at
+	// once).
+	winner int
+}
+
+// The most players a match can have.
+const MaxPlayers = 16
+
+// Start a new versus match between some number of players (from 2 to MaxPlayers). Every player gets the same
+// sequence of pieces, from the seed, and plays with the given options (only Stack, Flash, ClearGravity, and
+// Finesse matter).
+func NewMatch(seed int64, players int, options ModeOptions) *Match {
+	match := &Match{options: options, rng: NewRandom(seed - 1), winner: -1}
+	names := make([]string, players)
+	for player := range names {
+		names[player] = fmt.Sprintf("Player %d", player+1)
+		mode := newVersusMode(player, seed, options)
+		mode.names = names
+		match.modes = append(match.modes, mode)
+		match.games = append(match.games, NewGame(seed, mode))
+	}
+	match.retarget()
+	return match
+}
+
+// Name the players, for their status and results (by default they're "Player 1", "Player 2", and so on).
+func (match *Match) SetNames(names []string) {
+	copy(match.modes[0].names, names)
+}
+
+// The number of players in the match.
+func (match *Match) Players() int {
+	return len(match.games)
+}
+
+// The game of one of the players (from 0).
+func (match *Match) Game(player int) *Game {
+	return match.games[player]
+}
+
+// Whether the match is over.
+func (match *Match) Over() bool {
+	return match.over
+}
+
+// The player who won the match, or -1 if nobody has won (yet, or because the last players topped out at
+// once).
+func (match *Match) Winner() int {
+	return match.winner
+}
+
+// The place that a player finished in (1 for the winner), or 0 if they're still in the match.
+func (match *Match) Place(player int) int {
+	return match.modes[player].place
+}
+
+// The player that a player is sending garbage to, or -1 if there's nobody left.
+func (match *Match) Target(player int) int {
+	return match.modes[player].target
+}
+
+// Advance every game by some number of milliseconds, passing garbage between them as it's sent.
+func (match *Match) Advance(millis int) {
+	for i := 0; i < millis && !match.over; i++ {
+		for _, game := range match.games {
+			game.Advance(1)
+		}
+		match.update()
+	}
+}
+
+// Apply an event to one of the players: a gameplay event for their game, Target to change their targeting
+// strategy, or Forfeit to leave the match.
+func (match *Match) Handle(player int, event GameEvent) {
+	if match.over {
+		return
+	}
+	switch event {
+	case Target:
+		if mode := match.modes[player]; mode.place == 0 {
+			mode.targeting = (mode.targeting + 1) % targeting(len(targetingNames))
+			match.games[player].message = fmt.Sprintf("Targeting %s", mode.targeting)
+			match.retarget()
+		}
+	case Forfeit:
+		match.forfeit(player)
+	default:
+		match.games[player].Handle(event)
+		match.update()
+	}
+}
+
+// Whether an event can be given to a player in a match (see Match.Handle).
+func (event GameEvent) IsMatchInput() bool {
+	return event.IsGameplay() || event == Target || event == Forfeit
+}
+
+// Pause or unpause every game.
+func (match *Match) PauseToggle() {
+	for _, game := range match.games {
+		game.PauseToggle()
+	}
+}
+
+// Knock a player out of the match because they've left it.
+func (match *Match) forfeit(loser int) {
+	mode := match.modes[loser]
+	if match.over || mode.place > 0 {
+		return
+	}
+	mode.forfeited = true
+	match.games[loser].over = true
+	match.update()
+}
+
+// End the match without a winner, e.g. because the players' simulations of it disagree (see Lockstep).
+func (match *Match) Abandon() {
+	match.over = true
+	for player, game := range match.games {
+		match.modes[player].abandoned = true
+		game.over = true
+	}
+}
+
+// Deliver the garbage that each player has sent to their target, knock out anybody who has topped out, and
+// end the match if there's at most one player left.
+func (match *Match) update() {
+	changed := false
+	for player, mode := range match.modes {
+		if mode.outgoing == 0 {
+			continue
+		}
+		if target := mode.target; target >= 0 {
+			match.modes[target].garbage.receive(mode.outgoing, match.games[target].clock)
+			match.modes[target].lastAttacker = player
+		}
+		mode.outgoing = 0
+		if mode.targeting == targetRandom {
+			// Pick somebody new for the next attack.
+			mode.target = -1
+		}
+		changed = true
+	}
+	placed := 0
+	for _, game := range match.games {
+		placed += game.piecesPlaced
+	}
+	if placed != match.placed {
+		match.placed = placed
+		changed = true
+	}
+
+	var knockedOut []int
+	for player, game := range match.games {
+		if game.over && match.modes[player].place == 0 {
+			knockedOut = append(knockedOut, player)
+		}
+	}
+	if len(knockedOut) == 0 {
+		if changed {
+			match.retarget()
+		}
+		return
+	}
+	// Players knocked out at the same time share the best place between them.
+	left := match.playersLeft() - len(knockedOut)
+	for _, player := range knockedOut {
+		mode := match.modes[player]
+		mode.place = left + 1
+		if attacker := mode.lastAttacker; attacker >= 0 && !mode.forfeited {
+			match.modes[attacker].kos++
+		}
+	}
+	if left > 1 {
+		match.retarget()
+		return
+	}
+	match.over = true
+	for player, mode := range match.modes {
+		if mode.place == 0 {
+			mode.place = 1
+			match.winner = player
+			match.games[player].finish()
+		}
+	}
+	for _, game := range match.games {
+		game.over = true
+	}
+}
+
+// The number of players who haven't been knocked out.
+func (match *Match) playersLeft() int {
+	left := 0
+	for _, mode := range match.modes {
+		if mode.place == 0 {
+			left++
+		}
+	}
+	return left
+}
+
+// A targeting strategy chooses who a player sends their garbage to, out of the players left in the match.
+type targeting int
+
+const (
+	// Anybody, chosen again after each attack.
+	targetRandom targeting = iota
+	// Whoever last sent garbage to this player (or anybody, if they're gone).
+	targetAttackers
+	// Whoever has the highest stack, and so is closest to being knocked out.
+	targetKOs
+	// Whoever is being targeted by the fewest other players.
+	targetEven
+)
+
+var targetingNames = []string{"random", "attackers", "KOs", "even"}
+
+func (t targeting) String() string {
+	return targetingNames[t]
+}
+
+// Choose each player's target according to their targeting strategy, in order of the players.
+func (match *Match) retarget() {
+	for player, mode := range match.modes {
+		if mode.place == 0 {
+			mode.target = match.chooseTarget(player)
+		}
+	}
+}
+
+// Choose a target for a player. Strategies which find several equally good targets keep the current one if
+// it's among them, or otherwise take the first.
+func (match *Match) chooseTarget(player int) int {
+	mode := match.modes[player]
+	var opponents []int
+	current := -1
+	for opponent, other := range match.modes {
+		if opponent != player && other.place == 0 {
+			opponents = append(opponents, opponent)
+			if opponent == mode.target {
+				current = opponent
+			}
+		}
+	}
+	if len(opponents) == 0 {
+		return -1
+	}
+	// Pick the opponent with the best score, preferring the current target on a tie.
+	best := func(score func(opponent int) int) int {
+		target := current
+		for _, opponent := range opponents {
+			if target < 0 || score(opponent) > score(target) {
+				target = opponent
+			}
+		}
+		return target
+	}
+	switch mode.targeting {
+	case targetAttackers:
+		if attacker := mode.lastAttacker; attacker >= 0 && match.modes[attacker].place == 0 {
+			return attacker
+		}
+	case targetKOs:
+		return best(func(opponent int) int {
+			return match.games[opponent].board.stackHeight()
+		})
+	case targetEven:
+		targeted := make([]int, len(match.modes))
+		for other, otherMode := range match.modes {
+			if other != player && otherMode.place == 0 && otherMode.target >= 0 {
+				targeted[otherMode.target]++
+			}
+		}
+		return best(func(opponent int) int {
+			return -targeted[opponent]
+		})
+	}
+	if current >= 0 {
+		return current
+	}
+	return opponents[match.rng.Intn(len(opponents))]
+}
+
+// The mode of each player's game in a versus match. It isn't one of the modes that can be chosen with
+// NewMode: matches make their own.
+type versusMode struct {
+	modeBase
+	player int
+	// The random number generator for the holes in the garbage that this player receives. It's separate from
+	// the game's, so that garbage doesn't change the sequence of pieces.
+	rng      *Random
+	attacker attacker
+	garbage  garbageQueue
+	// The number of pieces placed when the game was last checked for line clears.
+	placed int
+	// Garbage sent by this player which the match hasn't delivered yet.
+	outgoing int
+	// The total numbers of garbage rows sent, cancelled, and received.
+	sent, cancelled, received int
+	// Whether the match ended without a winner (see Match.Abandon), and whether this player left it.
+	abandoned, forfeited bool
+	// The names of every player in the match (shared by all of their modes).
+	names []string
+	// How this player chooses who to send garbage to, the player they're sending it to (or -1 if there's
+	// nobody left), and the last player who sent garbage to them (or -1 if nobody has).
+	targeting    targeting
+	target       int
+	lastAttacker int
+	// The number of players knocked out while this player had last sent them garbage.
+	kos int
+	// The place this player finished in (1 for the winner), or 0 if they're still in the match.
+	place int
+}
+
+// Make the mode for one of the players (from 0) in a match with the given seed. Only the options which work
+// with every mode are kept (see ModeOptions).
+func newVersusMode(player int, seed int64, options ModeOptions) *versusMode {
+	return &versusMode{
+		modeBase: modeBase{"versus", ModeOptions{
+			Stack:        options.Stack,
+			Flash:        options.Flash,
+			ClearGravity: options.ClearGravity,
+			Finesse:      options.Finesse,
+		}},
+		player:       player,
+		rng:          NewRandom(seed + int64(player) + 1),
+		attacker:     attacker{table: guidelineAttackTable},
+		garbage:      garbageQueue{delay: versusGarbageDelay},
+		target:       -1,
+		lastAttacker: -1,
+	}
+}
+
+func (mode *versusMode) Setup(game *Game) {
+	game.setRuleset(guidelineRuleset)
+}
+
+// When a piece locks, the attack for any lines that it cleared cancels queued garbage, and the rest is sent
+// to the player's target. If it didn't clear any, the queued garbage which is ready rises, each attack's rows
+// with a hole in the same column.
+func (mode *versusMode) Update(game *Game) {
+	if game.piecesPlaced == mode.placed {
+		return
+	}
+	mode.placed = game.piecesPlaced
+	lastClear := game.lastClear()
+	rows, name := mode.attacker.attack(lastClear)
+	if rows > 0 {
+		game.message = fmt.Sprintf("%s%s: %d", strings.ToUpper(name[:1]), name[1:], rows)
+	}
+	if lastClear.lines > 0 {
+		left := mode.garbage.cancel(rows)
+		mode.cancelled += rows - left
+		mode.outgoing += left
+		mode.sent += left
+		return
+	}
+	for _, rows := range mode.garbage.take(game.clock) {
+		hole := mode.rng.Intn(width)
+		holes := make([]int, rows)
+		for i := range holes {
+			holes[i] = hole
+		}
+		mode.received += rows
+		game.raiseGarbage(holes)
+	}
+}
+
+func (mode *versusMode) incomingGarbage(game *Game) (ready, waiting int) {
+	return mode.garbage.count(game.clock)
+}
+
+func (mode *versusMode) title(game *Game) string {
+	switch {
+	case game.finished:
+		return "WINNER"
+	case mode.abandoned:
+		return "NO CONTEST"
+	case mode.forfeited:
+		return "FORFEITED"
+	case len(mode.names) > 2:
+		return "KNOCKED OUT"
+	}
+	return "TOPPED OUT"
+}
+
+func (mode *versusMode) Status(game *Game) []string {
+	var streak []string
+	if mode.attacker.combo > 1 {
+		streak = append(streak, fmt.Sprintf("Combo %d", mode.attacker.combo-1))
+	}
+	if mode.attacker.backToBack {
+		streak = append(streak, "B2B")
+	}
+	if len(mode.names) > 2 {
+		// With more players, there's less room for lines than for who this player is attacking.
+		target := "nobody"
+		if mode.target >= 0 {
+			target = mode.names[mode.target]
+		}
+		return []string{
+			fmt.Sprintf("Sent %d  KOs %d", mode.sent, mode.kos),
+			fmt.Sprintf("→ %s", target),
+			fmt.Sprintf("Aim %s", mode.targeting),
+			strings.Join(streak, "  "),
+		}
+	}
+	return []string{
+		fmt.Sprintf("Lines  %d", game.lines),
+		fmt.Sprintf("Sent   %d", mode.sent),
+		strings.Join(streak, "  "),
+	}
+}
+
+func (mode *versusMode) Results(game *Game) []string {
+	results := []string{mode.names[mode.player], ""}
+	if len(mode.names) > 2 && mode.place > 0 {
+		results = append(results,
+			fmt.Sprintf("Place      %d of %d", mode.place, len(mode.names)),
+			fmt.Sprintf("KOs        %d", mode.kos))
+	}
+	return append(results,
+		fmt.Sprintf("Lines      %d", game.lines),
+		fmt.Sprintf("Sent       %d", mode.sent),
+		fmt.Sprintf("Cancelled  %d", mode.cancelled),
+		fmt.Sprintf("Received   %d", mode.received),
+		fmt.Sprintf("Pieces     %d", game.piecesPlaced),
+		fmt.Sprintf("Time       %s", FormatMillis(game.clock)),
+	)
+}
+
+// Versus matches don't count for personal bests.
+func (mode *versusMode) Result(game *Game) (Result, bool) {
+	return Result{}, false
+}
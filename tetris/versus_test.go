@@ -0,0 +1,53 @@
+package tetris
+
+import (
+	"testing"
+)
+
+func TestVersusMatch(t *testing.T) {
+	match := NewMatch(7, 2, ModeOptions{})
+	first, second := match.Game(0), match.Game(1)
+	if first.board.currentPiece.name != second.board.currentPiece.name ||
+		first.nextPiece.name != second.nextPiece.name {
+		t.Fatalf("the players got different pieces")
+	}
+	// The first player plays with the bot, and the second just drops everything.
+	for i := 0; i < 2000 && !match.Over(); i++ {
+		if first.board.currentPiece != nil && first.clearingRows == nil {
+			for _, event := range botMoves(first) {
+				match.Handle(0, event)
+			}
+		}
+		if i%10 == 0 {
+			match.Handle(1, QuickDrop)
+		}
+		match.Advance(300)
+	}
+	if !match.Over() || match.Winner() != 0 {
+		t.Fatalf("the match ended with winner %d (over: %t); want 0", match.Winner(), match.Over())
+	}
+	if match.modes[0].sent == 0 || match.modes[1].received == 0 {
+		t.Errorf("player 1 sent %d rows of garbage and player 2 received %d", match.modes[0].sent,
+			match.modes[1].received)
+	}
+	if result, ok := match.modes[0].Result(first); ok {
+		t.Errorf("a versus game counts for personal bests: %+v", result)
+	}
+}
+
+func TestVersusForfeit(t *testing.T) {
+	match := NewMatch(7, 2, ModeOptions{})
+	match.Advance(1000)
+	match.Handle(0, Forfeit)
+	if !match.Over() || match.Winner() != 1 {
+		t.Fatalf("after player 1 forfeited, the winner is %d (over: %t); want 1", match.Winner(),
+			match.Over())
+	}
+	// Nothing changes once the match is over.
+	clock := match.Game(1).clock
+	match.Handle(1, QuickDrop)
+	match.Advance(1000)
+	if match.Game(1).clock != clock || match.Game(1).piecesPlaced != 0 {
+		t.Errorf("the game went on after the match was over")
+	}
+}
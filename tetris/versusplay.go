@@ -0,0 +1,188 @@
+package tetris
+
+import (
+	"time"
+)
+
+// The controls listed below each player's board in a versus match.
+var versusControls = [][]string{
+	{"Player 1 controls:",
+		"",
+		"Move left       'a'",
+		"Move right      'd'",
+		"Move down       's'",
+		"Rotate piece    'w'",
+		"Quick drop      space",
+		"",
+		"Pause/Resume    'p'",
+		"Rematch         'r' (at the end)",
+		"Quit            ctrl-c or 'q'",
+	},
+	{"Player 2 controls:",
+		"",
+		"Move left       left arrow",
+		"Move right      right arrow",
+		"Move down       down arrow",
+		"Rotate piece    up arrow",
+		"Quick drop      enter",
+	},
+}
+
+// An input during a versus match: a gameplay event for one of the players, or an event for the match as a
+// whole (like Pause or Quit) if player is -1.
+type versusEvent struct {
+	player int
+	event  GameEvent
+}
+
+// Start running the match, with the two players' games side by side, until the players quit. After each
+// match, they can start a rematch (with new pieces).
+func (match *Match) Start(console Console) {
+	events := make(chan versusEvent, 100)
+	go func() {
+		for {
+			event := versusUserEvent(console.PollEvent())
+			events <- event
+			if event.event == Quit {
+				return
+			}
+		}
+	}()
+	for match.play(console, events) {
+		*match = *NewMatch(time.Now().UnixNano(), len(match.games), match.options)
+	}
+}
+
+// The part of the screen that a player's game is drawn on.
+func (match *Match) screen(screen Screen, player int) Screen {
+	return offsetScreen{screen, player * ScreenWidth, 0}
+}
+
+// Draw both players' games. If static is true, the static parts of the interface are drawn too.
+func (match *Match) draw(screen Screen, static bool) {
+	for player, game := range match.games {
+		panel := match.screen(screen, player)
+		if static {
+			DrawStaticParts(panel, versusControls[player])
+		}
+		if game.paused {
+			game.DrawPauseScreen(panel)
+		} else {
+			game.DrawDynamic(panel, false)
+		}
+	}
+}
+
+// Play the match until it's over, then show the results until the players quit. Returns true if they asked
+// for a rematch.
+func (match *Match) play(screen Screen, events <-chan versusEvent) bool {
+	match.draw(screen, true)
+
+	ticker := time.NewTicker(FrameDelay)
+	defer ticker.Stop()
+
+	// As in Game.play, the match is advanced by the wall time that has passed, excluding any time spent
+	// paused.
+	lastAdvance := time.Now()
+	advance := func() {
+		elapsed := time.Since(lastAdvance) / time.Millisecond
+		lastAdvance = lastAdvance.Add(elapsed * time.Millisecond)
+		match.Advance(int(elapsed))
+	}
+	paused := false
+	for !match.over {
+		select {
+		case input := <-events:
+			advance()
+			switch input.event {
+			case Quit:
+				return false
+			case Pause:
+				paused = !paused
+				match.PauseToggle()
+				lastAdvance = time.Now()
+				match.draw(screen, true)
+			case Redraw:
+				match.draw(screen, true)
+			default:
+				if input.player >= 0 {
+					match.Handle(input.player, input.event)
+				}
+			}
+			if paused {
+				continue
+			}
+		case <-ticker.C:
+			if paused {
+				continue
+			}
+			advance()
+		}
+		match.draw(screen, false)
+	}
+	match.draw(screen, false)
+	for player, game := range match.games {
+		game.DrawGameOver(match.screen(screen, player), "", "Press 'r' for a rematch")
+	}
+	for input := range events {
+		switch input.event {
+		case Quit:
+			return false
+		case Retry:
+			return true
+		}
+	}
+	return false
+}
+
+// Find the versusEvent for a console's event, which can be an input from either player.
+func versusUserEvent(event Event) versusEvent {
+	switch event.Type {
+	// Player 1: 'a', 'd', 's', and 'w' to move and rotate, and space to drop
+	// Player 2: arrow keys, and enter to drop
+	// Pause: 'p'
+	// Rematch: 'r'
+	// Exit: 'q' or ctrl-c.
+	case EventKey:
+		if event.Ch == 0 { // A special key combo was pressed
+			switch event.Key {
+			case KeyCtrlC:
+				return versusEvent{-1, Quit}
+			case KeySpace:
+				return versusEvent{0, QuickDrop}
+			case KeyArrowLeft:
+				return versusEvent{1, MoveLeft}
+			case KeyArrowUp:
+				return versusEvent{1, Rotate}
+			case KeyArrowRight:
+				return versusEvent{1, MoveRight}
+			case KeyArrowDown:
+				return versusEvent{1, MoveDown}
+			case KeyEnter:
+				return versusEvent{1, QuickDrop}
+			}
+		} else {
+			switch event.Ch {
+			case 'p':
+				return versusEvent{-1, Pause}
+			case 'r':
+				return versusEvent{-1, Retry}
+			case 'q':
+				return versusEvent{-1, Quit}
+			case 'a':
+				return versusEvent{0, MoveLeft}
+			case 'w':
+				return versusEvent{0, Rotate}
+			case 'd':
+				return versusEvent{0, MoveRight}
+			case 's':
+				return versusEvent{0, MoveDown}
+			}
+		}
+	case EventResize:
+		return versusEvent{-1, Redraw}
+	case EventError:
+		return versusEvent{-1, Quit}
+	}
+	return versusEvent{-1, Redraw} // Should never be reached
+}
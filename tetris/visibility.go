@@ -0,0 +1,94 @@
+package tetris
+
+import (
+	"fmt"
+)
+
+// StackVisibility is how the blocks of pieces are shown once they've locked, for challenge variants of the
+// modes where the player has to remember what the stack looks like.
+type StackVisibility int
+
+const (
+	// Locked blocks are always shown.
+	StackVisible StackVisibility = iota
+	// Locked blocks fade out a few seconds after they lock.
+	StackFading
+	// Locked blocks disappear as soon as they lock.
+	StackInvisible
+)
+
+var stackVisibilityNames = map[StackVisibility]string{
+	StackVisible:   "visible",
+	StackFading:    "fading",
+	StackInvisible: "invisible",
+}
+
+func (visibility StackVisibility) String() string {
+	if name, ok := stackVisibilityNames[visibility]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Find the stack visibility with the given name (as returned by StackVisibility.String).
+func ParseStackVisibility(name string) (StackVisibility, error) {
+	for visibility, visibilityName := range stackVisibilityNames {
+		if visibilityName == name {
+			return visibility, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown stack visibility %q", name)
+}
+
+// How long locked blocks stay visible with StackFading, and how long the outline flash lasts (see
+// ModeOptions.Flash), in milliseconds.
+const (
+	fadeMillis      = 4000
+	lockFlashMillis = 250
+)
+
+// How a board cell is drawn.
+type cellAppearance int
+
+const (
+	cellSolid cellAppearance = iota
+	cellOutline
+	cellHidden
+	// A block which is marked as being in the wrong place.
+	cellMistake
+)
+
+// Modes can implement cellMarker to override how some board cells are drawn, returning ok as false for the
+// cells that they leave alone.
+type cellMarker interface {
+	markCell(game *Game, position Vector) (color Color, appearance cellAppearance, ok bool)
+}
+
+// Find the color of a board cell and how it should be drawn, given the mode's stack visibility (or the mode's
+// own markings; see cellMarker). Only blocks locked by pieces are ever hidden (not garbage), and everything
+// is shown once the game is over.
+func (game *Game) cellAppearance(position Vector) (Color, cellAppearance) {
+	if marker, ok := game.mode.(cellMarker); ok {
+		if color, appearance, ok := marker.markCell(game, position); ok {
+			return color, appearance
+		}
+	}
+	color := game.board.CellColor(position)
+	options := game.mode.Options()
+	lockTime, ok := game.board.lockTimes[position]
+	if !ok || game.over || options.Stack == StackVisible {
+		return color, cellSolid
+	}
+	hideAfter := 0
+	if options.Stack == StackFading {
+		hideAfter = fadeMillis
+	}
+	age := game.clock - lockTime
+	switch {
+	case age < hideAfter:
+		return color, cellSolid
+	case options.Flash && age < hideAfter+lockFlashMillis:
+		return color, cellOutline
+	}
+	return BackgroundColor, cellHidden
+}
@@ -0,0 +1,92 @@
+package tetris
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCellAppearance(t *testing.T) {
+	for _, test := range []struct {
+		options ModeOptions
+		// The appearance of a block locked at time 0, at each of these clock times.
+		times []int
+		want  []cellAppearance
+	}{
+		{ModeOptions{}, []int{0, 10000}, []cellAppearance{cellSolid, cellSolid}},
+		{ModeOptions{Stack: StackInvisible}, []int{0, 10000}, []cellAppearance{cellHidden, cellHidden}},
+		{ModeOptions{Stack: StackInvisible, Flash: true}, []int{0, lockFlashMillis - 1, lockFlashMillis},
+			[]cellAppearance{cellOutline, cellOutline, cellHidden}},
+		{ModeOptions{Stack: StackFading}, []int{0, fadeMillis - 1, fadeMillis},
+			[]cellAppearance{cellSolid, cellSolid, cellHidden}},
+		{ModeOptions{Stack: StackFading, Flash: true},
+			[]int{fadeMillis - 1, fadeMillis, fadeMillis + lockFlashMillis},
+			[]cellAppearance{cellSolid, cellOutline, cellHidden}},
+	} {
+		mode, err := NewMode("sprint", test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		game := NewGame(1, mode)
+		locked, garbage := Vector{0, height - 1}, Vector{1, height - 1}
+		game.board.cells[locked] = ColorRed
+		game.board.lockTimes[locked] = 0
+		game.board.cells[garbage] = garbageColor
+		for i, clock := range test.times {
+			game.clock = clock
+			if _, appearance := game.cellAppearance(locked); appearance != test.want[i] {
+				t.Errorf("%s: at %d ms, a locked block has appearance %d; want %d",
+					describeMode(mode), clock, appearance, test.want[i])
+			}
+			if _, appearance := game.cellAppearance(garbage); appearance != cellSolid {
+				t.Errorf("%s: at %d ms, garbage isn't shown", describeMode(mode), clock)
+			}
+		}
+		// Everything is shown once the game is over.
+		game.over = true
+		if _, appearance := game.cellAppearance(locked); appearance != cellSolid {
+			t.Errorf("%s: a locked block isn't shown once the game is over", describeMode(mode))
+		}
+	}
+}
+
+func TestStackVisibilityNames(t *testing.T) {
+	for visibility := range stackVisibilityNames {
+		parsed, err := ParseStackVisibility(visibility.String())
+		if err != nil || parsed != visibility {
+			t.Errorf("ParseStackVisibility(%q) = %v, %v", visibility.String(), parsed, err)
+		}
+	}
+	if _, err := ParseStackVisibility("translucent"); err == nil {
+		t.Errorf("ParseStackVisibility accepted an unknown name")
+	}
+}
+
+func TestInvisibleReplay(t *testing.T) {
+	mode, err := NewMode("sprint", ModeOptions{Stack: StackFading, Flash: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(5, mode)
+	botPlay(game, 3000, 30)
+	var b strings.Builder
+	if err := game.Replay().Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	replay, err := ReadReplay(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Mode != describeMode(mode) {
+		t.Fatalf("the replay's mode is %q; want %q", replay.Mode, describeMode(mode))
+	}
+	replayed, err := replay.Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for position, lockTime := range game.board.lockTimes {
+		if replayed.board.lockTimes[position] != lockTime {
+			t.Errorf("the block at %v locked at %d ms in the game, but %d ms in the replay",
+				position, lockTime, replayed.board.lockTimes[position])
+		}
+	}
+}
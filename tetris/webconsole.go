@@ -0,0 +1,132 @@
+package tetris
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"time"
+	"unicode/utf8"
+)
+
+// How long sending an update to a web page can take before the player is given up on.
+const webWriteTimeout = 10 * time.Second
+
+// A WebConn is a WebSocket connection to a web page, such as a *websocket.Conn from
+// github.com/gorilla/websocket. Updates are sent to the page as JSON, and the page sends key presses as text
+// messages.
+type WebConn interface {
+	WriteJSON(v interface{}) error
+	ReadMessage() (messageType int, p []byte, err error)
+	SetWriteDeadline(t time.Time) error
+}
+
+// A WebConsole is a Console in a web page, connected by a WebSocket (like the page that the netplay package's
+// WebHandler serves). Its screen is only used by the goroutine playing the game.
+type WebConsole struct {
+	conn WebConn
+	// The cells drawn since the last Flush, and those which the page is showing.
+	back, front *Buffer
+	// The key presses read from the page.
+	*consoleEvents
+}
+
+// An update to the screen in a web page, sent as JSON: the size of the screen in cells, and the cells which
+// have changed, each as [x, y, character, foreground color, background color] (with CSS colors).
+type webUpdate struct {
+	Width  int             `json:"width"`
+	Height int             `json:"height"`
+	Cells  [][]interface{} `json:"cells"`
+}
+
+// Make a Console which draws in a web page, and start reading the key presses that the page sends (see
+// webKeyEvent).
+func NewWebConsole(conn WebConn) *WebConsole {
+	console := &WebConsole{
+		conn:          conn,
+		back:          NewBuffer(ScreenWidth, ScreenHeight),
+		front:         NewBuffer(ScreenWidth, ScreenHeight),
+		consoleEvents: newConsoleEvents(),
+	}
+	go console.read()
+	return console
+}
+
+func (console *WebConsole) SetCell(x, y int, ch rune, fg, bg Color) {
+	console.back.SetCell(x, y, ch, fg, bg)
+}
+
+func (console *WebConsole) Clear(fg, bg Color) {
+	console.back.Clear(fg, bg)
+}
+
+// Send the cells which have changed since the last Flush to the page.
+func (console *WebConsole) Flush() error {
+	update := webUpdate{Width: console.back.Width, Height: console.back.Height}
+	console.back.Update(console.front, func(x, y int, cell Cell) {
+		fg := webColor(cell.Fg, imageTextColor)
+		bg := webColor(cell.Bg, imageColors[BackgroundColor])
+		update.Cells = append(update.Cells, []interface{}{x, y, string(cell.Ch), fg, bg})
+	})
+	if len(update.Cells) == 0 {
+		return nil
+	}
+	console.conn.SetWriteDeadline(time.Now().Add(webWriteTimeout))
+	return console.conn.WriteJSON(update)
+}
+
+// Read the key presses sent by the page until the connection is closed (or the console is).
+func (console *WebConsole) read() {
+	for {
+		_, message, err := console.conn.ReadMessage()
+		if err != nil {
+			console.end(err)
+			return
+		}
+		if event, ok := webKeyEvent(string(message)); ok && !console.key(event) {
+			console.end(errors.New("the game is over"))
+			return
+		}
+	}
+}
+
+// Stop reading key presses, once the game is over. It doesn't close the connection.
+func (console *WebConsole) Close() {
+	console.consoleEvents.close()
+}
+
+// The keys that a web page sends by name (see webKeyEvent).
+var webKeys = map[string]Key{
+	"ArrowLeft":  KeyArrowLeft,
+	"ArrowRight": KeyArrowRight,
+	"ArrowUp":    KeyArrowUp,
+	"ArrowDown":  KeyArrowDown,
+	" ":          KeySpace,
+	"Enter":      KeyEnter,
+	"ctrl-c":     KeyCtrlC,
+	"ctrl-r":     KeyCtrlR,
+}
+
+// Find the terminal event for a key pressed in a web page. The page sends each key as the name that the
+// browser gives it ("ArrowLeft", "q", and so on), with "ctrl-" in front of control keys. Returns false for
+// other keys (like "Shift"), which are ignored.
+func webKeyEvent(name string) (Event, bool) {
+	if key, ok := webKeys[name]; ok {
+		return Event{Type: EventKey, Key: key}, true
+	}
+	if ch, size := utf8.DecodeRuneInString(name); size == len(name) && ch > ' ' && ch != utf8.RuneError {
+		return Event{Type: EventKey, Ch: ch}, true
+	}
+	return Event{}, false
+}
+
+// Find the CSS color for a Color, using the same colors as images of games (see imageColors).
+func webColor(attribute Color, defaultColor color.Color) string {
+	c, ok := imageColors[attribute]
+	if attribute == ColorDefault {
+		c = defaultColor
+	} else if !ok {
+		c = imageColors[ColorWhite]
+	}
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
@@ -0,0 +1,25 @@
+package tetris
+
+import (
+	"testing"
+)
+
+func TestWebKeyEvent(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want Event
+		ok   bool
+	}{
+		{"ArrowLeft", Event{Type: EventKey, Key: KeyArrowLeft}, true},
+		{" ", Event{Type: EventKey, Key: KeySpace}, true},
+		{"ctrl-c", Event{Type: EventKey, Key: KeyCtrlC}, true},
+		{"q", Event{Type: EventKey, Ch: 'q'}, true},
+		{"é", Event{Type: EventKey, Ch: 'é'}, true},
+		{"Shift", Event{}, false},
+		{"ctrl-x", Event{}, false},
+	} {
+		if event, ok := webKeyEvent(test.name); event != test.want || ok != test.ok {
+			t.Errorf("the key %q gave %+v, %t; want %+v, %t", test.name, event, ok, test.want, test.ok)
+		}
+	}
+}
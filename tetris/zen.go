@@ -0,0 +1,45 @@
+package tetris
+
+import (
+	"fmt"
+)
+
+// The drop delay in zen mode, which stays the same for the whole game.
+const zenDropDelay = 1000
+
+// Zen mode is for practice: pieces fall slowly and the speed never goes up, there's no goal, and the player
+// can undo any number of pieces (and redo them), or start over with the same pieces. Games in zen mode don't
+// count for personal bests.
+type zenMode struct {
+	modeBase
+}
+
+func (mode *zenMode) dropDelay(game *Game) int {
+	return zenDropDelay
+}
+
+// The player can undo and redo pieces in zen mode, and start over.
+func (mode *zenMode) rewind()   {}
+func (mode *zenMode) practice() {}
+
+func (mode *zenMode) Status(game *Game) []string {
+	return []string{
+		fmt.Sprintf("Lines  %d", game.lines),
+		fmt.Sprintf("Pieces %d", game.piecesPlaced),
+		"Undo 'u' Redo ^R",
+	}
+}
+
+func (mode *zenMode) Results(game *Game) []string {
+	return []string{
+		fmt.Sprintf("Score   %d", game.score),
+		fmt.Sprintf("Lines   %d", game.lines),
+		fmt.Sprintf("Pieces  %d", game.piecesPlaced),
+		fmt.Sprintf("Undos   %d", game.undos),
+		fmt.Sprintf("Time    %s", FormatMillis(game.clock)),
+	}
+}
+
+func (mode *zenMode) Result(game *Game) (Result, bool) {
+	return Result{}, false
+}
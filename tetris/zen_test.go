@@ -0,0 +1,89 @@
+package tetris
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Describe the parts of a game which undoing and redoing pieces restore.
+func zenState(game *Game) string {
+	return fmt.Sprint(game.piecesPlaced, game.score, game.lines, game.board.cells,
+		game.board.currentPiece.name, game.nextPiece.name, game.rng.state)
+}
+
+func TestZenUndo(t *testing.T) {
+	mode, err := NewMode("zen", ModeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(7, mode)
+	if game.dropDelayMillis != zenDropDelay {
+		t.Errorf("the drop delay is %d ms; want %d", game.dropDelayMillis, zenDropDelay)
+	}
+	var states []string
+	for i := 0; i < 5; i++ {
+		states = append(states, zenState(game))
+		game.Handle(MoveLeft)
+		game.Handle(QuickDrop)
+		game.Advance(500)
+	}
+	states = append(states, zenState(game))
+
+	game.Handle(Undo)
+	game.Handle(Undo)
+	if state := zenState(game); state != states[3] {
+		t.Fatalf("after undoing 2 pieces the game is at\n%s\nwant\n%s", state, states[3])
+	}
+	game.Handle(Redo)
+	if state := zenState(game); state != states[4] {
+		t.Fatalf("after redoing a piece the game is at\n%s\nwant\n%s", state, states[4])
+	}
+	// Placing a different piece forgets the pieces that were undone.
+	game.Handle(Undo)
+	game.Handle(Rotate)
+	game.Handle(QuickDrop)
+	game.Advance(500)
+	placed := zenState(game)
+	game.Handle(Redo)
+	if state := zenState(game); state != placed || len(game.future) != 0 {
+		t.Fatalf("a piece was redone after placing a new one")
+	}
+	for i := 0; i < 10; i++ {
+		game.Handle(Undo)
+	}
+	if state := zenState(game); state != states[0] {
+		t.Fatalf("after undoing everything the game is at\n%s\nwant\n%s", state, states[0])
+	}
+	if game.undos != 7 {
+		t.Errorf("the game counted %d undos; want 7", game.undos)
+	}
+	if _, ok := mode.Result(game); ok {
+		t.Errorf("a zen game counts for personal bests")
+	}
+
+	// Replays include the undos.
+	game.Advance(300)
+	final := zenState(game)
+	replayed, err := game.Replay().Simulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := zenState(replayed); state != final {
+		t.Errorf("the replay ended at\n%s\nwant\n%s", state, final)
+	}
+}
+
+func TestUndoOutsideZen(t *testing.T) {
+	mode, err := NewMode("sprint", ModeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGame(7, mode)
+	game.Handle(QuickDrop)
+	game.Advance(500)
+	before := zenState(game)
+	game.Handle(Undo)
+	if state := zenState(game); state != before {
+		t.Errorf("a piece was undone in sprint mode")
+	}
+}
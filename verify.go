@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"os"
+	"time"
+)
+
+// The verify command re-simulates a replay and checks that it produces the score, lines, and time that it
+// claims to (or that are given on the command line), or for a replay of a network match, the winner. It exits
+// with a non-zero status if they don't match, or if the replay is invalid.
+func verify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-tetris verify [flags] replay-file")
+		flags.PrintDefaults()
+	}
+	claimedScore := flags.Int("score", -1, "The claimed score (defaults to the score recorded in the replay)")
+	claimedLines := flags.Int("lines", -1, "The claimed number of lines (defaults to the replay's)")
+	claimedTime := flags.Duration("time", -1, "The claimed game time (defaults to the replay's)")
+	claimedWinner := flags.Int("winner", -1,
+		"The claimed winner of a match, counting players from 1, or 0 for nobody (defaults to the replay's)")
+	packFile := flags.String("pack", "", "Load the puzzle pack in this file, for replays of its puzzles")
+	flags.Parse(args)
+	if *packFile != "" {
+		loadPuzzlePack(*packFile)
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	// Replays are checked before they're simulated, but whatever is in one, it's only ever reported as
+	// invalid.
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid replay:", err)
+			os.Exit(1)
+		}
+	}()
+	if data, err := os.ReadFile(flags.Arg(0)); err == nil && tetris.IsMatchReplay(data) {
+		verifyMatch(data, *claimedWinner)
+		return
+	}
+
+	replay, err := loadReplay(flags.Arg(0))
+	if err == nil {
+		// This checks the whole replay, and that the game can be set up from it, before it's simulated.
+		_, err = tetris.NewReplayPlayer(replay)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid replay:", err)
+		os.Exit(1)
+	}
+	if *claimedScore < 0 {
+		*claimedScore = replay.Score
+	}
+	if *claimedLines < 0 {
+		*claimedLines = replay.Lines
+	}
+	if *claimedTime < 0 {
+		*claimedTime = time.Duration(replay.Time) * time.Millisecond
+	}
+
+	game, err := replay.Simulate()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Illegal input:", err)
+		os.Exit(1)
+	}
+
+	ok := true
+	check := func(name string, actual, claimed interface{}) {
+		status := "ok"
+		if actual != claimed {
+			status = fmt.Sprintf("MISMATCH (claimed %v)", claimed)
+			ok = false
+		}
+		fmt.Printf("%-7s %-12v %s\n", name, actual, status)
+	}
+	check("score", game.Score(), *claimedScore)
+	check("lines", game.Lines(), *claimedLines)
+	check("time", time.Duration(game.Clock())*time.Millisecond, *claimedTime)
+	fmt.Printf("%-7s %v\n", "pieces", game.PiecesPlaced())
+	fmt.Printf("%-7s %v\n", "over", game.Over())
+	fmt.Printf("%-7s %v\n", "mode", replay.Mode)
+	for _, line := range game.Results() {
+		fmt.Printf("        %s\n", line)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// Re-simulate a replay of a network match, checking that it has the claimed winner (counting players from 1,
+// or 0 for nobody, and by default the winner recorded in the replay), and exiting if it doesn't.
+func verifyMatch(data []byte, claimedWinner int) {
+	replay, err := tetris.ReadMatchReplay(bytes.NewReader(data))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid replay:", err)
+		os.Exit(1)
+	}
+	if claimedWinner < 0 {
+		claimedWinner = replay.Winner + 1
+	}
+	match, err := replay.Simulate()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Illegal input:", err)
+		os.Exit(1)
+	}
+
+	status := "ok"
+	if match.Winner()+1 != claimedWinner {
+		status = fmt.Sprintf("MISMATCH (claimed %d)", claimedWinner)
+	}
+	fmt.Printf("%-7s %-12v %s\n", "winner", match.Winner()+1, status)
+	fmt.Printf("%-7s %v\n", "time", time.Duration(match.Game(0).Clock())*time.Millisecond)
+	fmt.Printf("%-7s %v\n", "over", match.Over())
+	for player := 0; player < replay.Players; player++ {
+		game := match.Game(player)
+		fmt.Printf("player %d: score %d, lines %d, pieces %d\n", player+1, game.Score(), game.Lines(),
+			game.PiecesPlaced())
+	}
+	if status != "ok" {
+		os.Exit(1)
+	}
+}
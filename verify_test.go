@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Run the verify command (in a copy of the test binary, since it exits), as if by "go-tetris verify", when
+// the test is started by runVerify.
+func TestVerifyCommand(t *testing.T) {
+	args := os.Getenv("GO_TETRIS_VERIFY")
+	if args == "" {
+		return
+	}
+	verify(strings.Split(args, "\n"))
+	os.Exit(0)
+}
+
+// Save a replay and run the verify command on it with some flags, returning what it printed and its exit
+// status.
+func runVerify(t *testing.T, replay string, flags ...string) (string, int) {
+	path := filepath.Join(t.TempDir(), "game.replay")
+	if err := os.WriteFile(path, []byte(replay), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestVerifyCommand$")
+	cmd.Env = append(os.Environ(), "GO_TETRIS_VERIFY="+strings.Join(append(flags, path), "\n"))
+	output, err := cmd.CombinedOutput()
+	if exit, ok := err.(*exec.ExitError); ok {
+		return string(output), exit.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(output), 0
+}
+
+// A replay of a short game in which pieces are moved a little and dropped, along with its score.
+func droppedReplay(t *testing.T) (string, int) {
+	mode, err := tetris.NewMode("classic", tetris.ModeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := tetris.NewGame(1, mode)
+	for i := 0; i < 10; i++ {
+		game.Advance(300)
+		if i%2 == 0 {
+			game.Handle(tetris.MoveLeft)
+		}
+		game.Handle(tetris.QuickDrop)
+	}
+	var b strings.Builder
+	if err := game.Replay().Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String(), game.Score()
+}
+
+func TestVerify(t *testing.T) {
+	replay, score := droppedReplay(t)
+	if output, status := runVerify(t, replay); status != 0 || strings.Contains(output, "MISMATCH") {
+		t.Errorf("verifying an honest replay exited with %d:\n%s", status, output)
+	}
+	if output, status := runVerify(t, replay, "-lines", "0", "-score", fmt.Sprint(score)); status != 0 {
+		t.Errorf("verifying the replay's real score and lines exited with %d:\n%s", status, output)
+	}
+
+	// The first input moved to the end, after the later ones.
+	var moved []string
+	first := ""
+	for _, line := range strings.Split(replay, "\n") {
+		if strings.HasPrefix(line, "input ") && first == "" {
+			first = line
+			continue
+		}
+		if strings.HasPrefix(line, "score ") {
+			moved = append(moved, first)
+		}
+		moved = append(moved, line)
+	}
+	scoreLine := fmt.Sprintf("score %d", score)
+	for _, test := range []struct {
+		what, replay string
+		flags        []string
+		want         string
+	}{
+		{"a changed score", strings.Replace(replay, scoreLine, fmt.Sprint("score ", score+500), 1), nil,
+			"MISMATCH"},
+		{"changed lines", strings.Replace(replay, "lines 0", "lines 1", 1), nil, "MISMATCH"},
+		{"a claimed score", replay, []string{"-score", fmt.Sprint(score + 1)},
+			fmt.Sprintf("MISMATCH (claimed %d)", score+1)},
+		{"inputs out of order", strings.Join(moved, "\n"), nil, "is earlier than the previous"},
+		{"an input after the end", strings.Replace(replay, "\nscore", "\ninput 99999 drop\nscore", 1), nil,
+			"Invalid replay: input"},
+		{"an interface event", strings.Replace(replay, "\nscore", "\ninput 100 pause\nscore", 1), nil,
+			"Invalid replay: line"},
+		{"a negative time", strings.Replace(replay, "\ntime ", "\ntime -", 1), nil, "Invalid replay"},
+		{"a broken fumen", strings.Replace(replay, "\nscore",
+			"\nfumen v115@vhGRQYHAvHsQYJ4AvKsQ4GPDvEMQ8AvG4AvHqvKyAvC0AvF4AvI4Bv\nscore", 1), nil,
+			"Invalid replay: invalid character in fumen comment"},
+		{"an unknown mode", strings.Replace(replay, "mode classic", "mode tetris99", 1), nil,
+			"Invalid replay: unknown mode"},
+		{"not a replay", "hello\n", nil, "Invalid replay: not a replay file"},
+	} {
+		output, status := runVerify(t, test.replay, test.flags...)
+		if status != 1 || !strings.Contains(output, test.want) {
+			t.Errorf("verifying a replay with %s exited with %d:\n%s\nwant 1, with %q", test.what, status,
+				output, test.want)
+		}
+	}
+}
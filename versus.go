@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"github.com/cespare/go-tetris/tetris/netplay"
+	"github.com/nsf/termbox-go"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The port that network matches are hosted on, unless another one is given.
+const defaultPort = "4411"
+
+// The versus command plays a local two-player match, with both boards side by side in the same terminal.
+func versus(args []string) {
+	flags := flag.NewFlagSet("versus", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-tetris versus [flags]")
+		flags.PrintDefaults()
+	}
+	matchOptions := versusFlags(flags)
+	spectators := spectatorFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	match := tetris.NewMatch(time.Now().UnixNano(), 2, matchOptions())
+
+	console, stopBroadcast := spectators(termboxConsole{})
+	if err := termbox.Init(); err != nil {
+		panic(err)
+	}
+	match.Start(console)
+	termbox.Close()
+	stopBroadcast()
+	fmt.Println("Bye!")
+}
+
+// The host command waits for another player to join (see join), and then plays a versus match against them
+// over the network.
+func host(args []string) {
+	flags := flag.NewFlagSet("host", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-tetris host [flags]")
+		flags.PrintDefaults()
+	}
+	address := flags.String("addr", ":"+defaultPort, "The address to listen on")
+	matchOptions := versusFlags(flags)
+	announce := announceFlags(flags)
+	spectators := spectatorFlags(flags)
+	saveReplays := matchReplayFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	options := matchOptions()
+
+	listener, err := net.Listen("tcp", *address)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	console, stopBroadcast := spectators(termboxConsole{})
+	stopAnnouncing := announce(func() netplay.LANGame {
+		return netplay.LANGame{Addr: listener.Addr().String(), Players: 1, Size: 2, Options: options}
+	})
+	fmt.Printf("Waiting for another player to join on %s...\n", listener.Addr())
+	conn, err := listener.Accept()
+	stopAnnouncing()
+	listener.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	match, err := netplay.HostMatch(conn, time.Now().UnixNano(), options)
+	if err != nil {
+		conn.Close()
+		fmt.Fprintln(os.Stderr, "Couldn't start the match:", err)
+		os.Exit(1)
+	}
+	playNetMatch(match, console, stopBroadcast)
+	saveReplays(match.Replay())
+}
+
+// The join command connects to a player who is hosting a match (see host) and plays it. The host chooses the
+// match's options. Without an address, it lists the games on the local network to choose from (see joinLAN).
+func join(args []string) {
+	flags := flag.NewFlagSet("join", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-tetris join [flags] [host[:port]]")
+		flags.PrintDefaults()
+	}
+	name := flags.String("name", os.Getenv("USER"), "The name to play under on a server chosen from the list")
+	lanPort := flags.Int("lan-port", netplay.LANPort,
+		"The UDP port that local network games are announced on")
+	list := flags.Bool("list", false, "Print the games on the local network, instead of choosing one to join")
+	spectators := spectatorFlags(flags)
+	saveReplays := matchReplayFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() > 1 || *list && flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if flags.NArg() == 0 {
+		joinLAN(playerName(*name), *lanPort, *list, spectators, saveReplays)
+		return
+	}
+	address := flags.Arg(0)
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultPort)
+	}
+
+	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	match, err := netplay.JoinMatch(conn)
+	if err != nil {
+		conn.Close()
+		fmt.Fprintln(os.Stderr, "Couldn't join the match:", err)
+		os.Exit(1)
+	}
+	console, stopBroadcast := spectators(termboxConsole{})
+	playNetMatch(match, console, stopBroadcast)
+	saveReplays(match.Replay())
+}
+
+// Play a network match in the terminal (or a broadcast of it) once the players have agreed on it, stopping
+// the broadcast (see spectators) once it's over.
+func playNetMatch(match *netplay.NetMatch, console tetris.Console, stopBroadcast func()) {
+	if err := termbox.Init(); err != nil {
+		panic(err)
+	}
+	match.Start(console)
+	termbox.Close()
+	stopBroadcast()
+	fmt.Println("Bye!")
+}
+
+// Add the flag for saving replays of network matches (see tetris.MatchReplay) to a flag set, returning a
+// function which saves them (if the flag says to) once they're over, exiting if it can't. When there's more
+// than one match, as there can be on a server, they're numbered: "match.replay" is saved as "match-1.replay",
+// "match-2.replay", and so on.
+func matchReplayFlag(flags *flag.FlagSet) func(replays ...*tetris.MatchReplay) {
+	filename := flags.String("replay", "", "Save a replay of the match to this file")
+	return func(replays ...*tetris.MatchReplay) {
+		if *filename == "" {
+			return
+		}
+		for i, replay := range replays {
+			name := *filename
+			if len(replays) > 1 {
+				ext := filepath.Ext(name)
+				name = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i+1, ext)
+			}
+			if err := saveMatchReplay(name, replay); err != nil {
+				fmt.Fprintln(os.Stderr, "Error saving replay:", err)
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+func saveMatchReplay(filename string, replay *tetris.MatchReplay) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := replay.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Add the flags for a versus match's options to a flag set, returning a function which reads the options once
+// the flags have been parsed (exiting if any of them are invalid).
+func versusFlags(flags *flag.FlagSet) func() tetris.ModeOptions {
+	stack := flags.String("stack", "visible", "How locked blocks are shown: visible, fading, or invisible")
+	flash := flags.Bool("flash", false,
+		"Briefly outline blocks when they disappear (with -stack fading or invisible)")
+	clearGravity := flags.String("clear-gravity", "naive",
+		"How blocks fall after a line clear: naive, sticky (connected blocks fall together), or cascade")
+	return func() tetris.ModeOptions {
+		stackVisibility, err := tetris.ParseStackVisibility(*stack)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		gravity, err := tetris.ParseClearGravity(*clearGravity)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return tetris.ModeOptions{Stack: stackVisibility, Flash: *flash, ClearGravity: gravity}
+	}
+}
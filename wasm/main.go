@@ -0,0 +1,61 @@
+//go:build js && wasm
+
+// This is go-tetris for browsers, built with WebAssembly. The whole game runs in the page (see index.html),
+// drawn on a canvas, and a replay of the game can be downloaded when it's over. Build it with:
+//
+//	GOOS=js GOARCH=wasm go build -o wasm/go-tetris.wasm ./wasm
+package main
+
+import (
+	"bytes"
+	"github.com/cespare/go-tetris/tetris"
+	"net/url"
+	"strings"
+	"syscall/js"
+	"time"
+)
+
+func main() {
+	document := js.Global().Get("document")
+	status := document.Call("getElementById", "status")
+
+	// The mode is chosen with the page's query string (e.g. "?mode=sprint"), which the menu of modes sets.
+	search := js.Global().Get("location").Get("search").String()
+	query, _ := url.ParseQuery(strings.TrimPrefix(search, "?"))
+	name := query.Get("mode")
+	if name == "" {
+		name = "classic"
+	}
+	modes := document.Call("getElementById", "mode")
+	for _, modeName := range tetris.ModeNames() {
+		option := document.Call("createElement", "option")
+		option.Set("textContent", modeName)
+		option.Set("selected", modeName == name)
+		modes.Call("appendChild", option)
+	}
+	mode, err := tetris.NewMode(name, tetris.ModeOptions{})
+	if err != nil {
+		status.Set("textContent", "Error: "+err.Error())
+		return
+	}
+
+	canvas := tetris.NewCanvas(document.Call("getElementById", "screen"))
+	status.Set("textContent", "")
+	game := tetris.NewGame(time.Now().UnixNano(), mode)
+	game.Start(canvas, tetris.PlayOptions{})
+	canvas.Close()
+
+	// The replay is the same as the terminal version's, so it can be verified or exported by go-tetris.
+	var replay bytes.Buffer
+	if err := game.Replay().Write(&replay); err != nil {
+		status.Set("textContent", "Error saving the replay: "+err.Error())
+		return
+	}
+	data := js.Global().Get("Uint8Array").New(replay.Len())
+	js.CopyBytesToJS(data, replay.Bytes())
+	blob := js.Global().Get("Blob").New([]interface{}{data}, map[string]interface{}{"type": "text/plain"})
+	link := document.Call("getElementById", "replay")
+	link.Set("href", js.Global().Get("URL").Call("createObjectURL", blob))
+	link.Get("style").Set("display", "")
+	status.Set("textContent", "The game is over. Reload the page to play again.")
+}
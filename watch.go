@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"github.com/cespare/go-tetris/tetris/netplay"
+	"github.com/nsf/termbox-go"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Add the flags for letting spectators watch a game (see netplay.Broadcast) to a flag set, returning a
+// function which starts listening for them (if the flags say to) once they've been parsed, exiting if it
+// can't. The function returns the console to play on, which shows the game to the spectators as well, and
+// another function which says goodbye to them once the game is over.
+func spectatorFlags(flags *flag.FlagSet) func(console tetris.Console) (tetris.Console, func()) {
+	address := flags.String("spectators", "",
+		fmt.Sprintf("Let spectators watch the game on this address (e.g. :%d) with 'go-tetris watch'",
+			netplay.SpectatorPort))
+	delay := flags.Duration("spectator-delay", 0,
+		fmt.Sprintf("How far behind the game spectators are kept (at most %s)", netplay.MaxSpectatorDelay))
+	return func(console tetris.Console) (tetris.Console, func()) {
+		if *address == "" {
+			return console, func() {}
+		}
+		broadcast, err := netplay.NewBroadcast(console, *delay)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -spectator-delay:", err)
+			os.Exit(2)
+		}
+		listener, err := net.Listen("tcp", *address)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Couldn't listen for spectators:", err)
+			os.Exit(1)
+		}
+		go broadcast.Serve(listener)
+		return broadcast, func() {
+			listener.Close()
+			broadcast.Close()
+		}
+	}
+}
+
+// The watch command connects to a game that's being shown to spectators (see spectatorFlags) and shows it.
+func watch(args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-tetris watch host[:port]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	address := flags.Arg(0)
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, strconv.Itoa(netplay.SpectatorPort))
+	}
+
+	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	viewer, err := netplay.WatchBroadcast(conn)
+	if err != nil {
+		conn.Close()
+		fmt.Fprintln(os.Stderr, "Couldn't watch the game:", err)
+		os.Exit(1)
+	}
+
+	if err := termbox.Init(); err != nil {
+		panic(err)
+	}
+	viewer.Start(termboxConsole{})
+	termbox.Close()
+	fmt.Println("Bye!")
+}
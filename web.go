@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/cespare/go-tetris/tetris"
+	"github.com/cespare/go-tetris/tetris/netplay"
+	"log"
+	"net"
+	"net/http"
+	"os"
+)
+
+// The serve-web command serves a web page for playing games in a browser (see netplay.WebHandler), until it's
+// killed.
+func serveWeb(args []string) {
+	flags := flag.NewFlagSet("serve-web", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-tetris serve-web [flags]")
+		flags.PrintDefaults()
+	}
+	address := flags.String("addr", ":8080", "The address to listen on")
+	games := flags.Int("games", 100, "The most games that are played at once")
+	gameMode := modeFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() != 0 || *games < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	// Check the mode's flags before anybody connects.
+	gameMode()
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	newMode := func() tetris.Mode {
+		mode, _ := gameMode()
+		return mode
+	}
+	listener, err := net.Listen("tcp", *address)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logger.Printf("Serving on http://%s/", listener.Addr())
+	logger.Fatal(http.Serve(listener, netplay.WebHandler(newMode, *games, logger)))
+}